@@ -23,7 +23,8 @@ type fitbitToken struct {
 }
 
 type FitbitClient struct {
-	client *http.Client
+	client      *http.Client
+	tokenSource oauth2.TokenSource
 }
 
 const expiryFmt = "2006-01-02T15:04:05Z07:00"
@@ -48,16 +49,7 @@ func NewFitbitClient() FitbitClient {
 	}
 	go http.ListenAndServe(":8080", srv)
 
-	conf := &oauth2.Config{
-		ClientID:     os.Getenv("FITBIT_CLIENT_ID"),
-		ClientSecret: os.Getenv("FITBIT_CLIENT_SECRET"),
-		Scopes:       []string{"activity", "profile", "sleep", "nutrition", "weight", "sleep"},
-		RedirectURL:  redirectUrl,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://www.fitbit.com/oauth2/authorize",
-			TokenURL: "https://api.fitbit.com/oauth2/token",
-		},
-	}
+	conf := oauthConfig(redirectUrl)
 	ctx := context.Background()
 	token, err := loadToken()
 
@@ -86,8 +78,49 @@ func NewFitbitClient() FitbitClient {
 		saveToken(token)
 	}
 
-	fitbitClient := conf.Client(ctx, token)
-	return FitbitClient{fitbitClient}
+	return newClient(conf, token)
+}
+
+// NewSavedTokenClient returns a client for the token saved by an earlier
+// interactive login with NewFitbitClient, without prompting. Fitbit refresh
+// tokens can only be used once, so RefreshToken should be called regularly to
+// keep the saved token current.
+func NewSavedTokenClient() (FitbitClient, error) {
+	token, err := loadToken()
+	if err != nil {
+		return FitbitClient{}, fmt.Errorf("error loading fitbit token: %w", err)
+	}
+	return newClient(oauthConfig(""), token), nil
+}
+
+func oauthConfig(redirectUrl string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     os.Getenv("FITBIT_CLIENT_ID"),
+		ClientSecret: os.Getenv("FITBIT_CLIENT_SECRET"),
+		Scopes:       []string{"activity", "profile", "sleep", "nutrition", "weight", "sleep"},
+		RedirectURL:  redirectUrl,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://www.fitbit.com/oauth2/authorize",
+			TokenURL: "https://api.fitbit.com/oauth2/token",
+		},
+	}
+}
+
+func newClient(conf *oauth2.Config, token *oauth2.Token) FitbitClient {
+	ctx := context.Background()
+	ts := oauth2.ReuseTokenSource(token, conf.TokenSource(ctx, token))
+	return FitbitClient{oauth2.NewClient(ctx, ts), ts}
+}
+
+// RefreshToken refreshes the access token if it has expired and saves the
+// current token, so a restart picks up a refresh token that is still valid.
+func (f FitbitClient) RefreshToken() error {
+	token, err := f.tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("error refreshing fitbit token: %w", err)
+	}
+	saveToken(token)
+	return nil
 }
 
 func loadToken() (*oauth2.Token, error) {
@@ -211,3 +244,63 @@ func (f FitbitClient) GetFitbitWeight() (WeightResponse, error) {
 
 	return weightResponse, err
 }
+
+const dateFmt = "2006-01-02"
+
+// getJSON fetches a Fitbit API path and decodes the response into out.
+func (f FitbitClient) getJSON(path string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, "https://api.fitbit.com"+path, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create fitbit request: %w", err)
+	}
+	req.Header.Add("accept-language", "en_US")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request to fitbit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		slog.Error("error code calling fitbit", "path", path, "statusCode", resp.StatusCode, "respBody", body)
+		return fmt.Errorf("error code calling fitbit: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding fitbit response: %w", err)
+	}
+	return nil
+}
+
+// GetActivitySeries returns the daily values of an activity resource, such as
+// steps or minutesVeryActive, from start to end inclusive.
+func (f FitbitClient) GetActivitySeries(resource string, start, end time.Time) ([]SeriesPoint, error) {
+	series := make(map[string][]SeriesPoint)
+	path := fmt.Sprintf("/1/user/-/activities/%s/date/%s/%s.json", resource, start.Format(dateFmt), end.Format(dateFmt))
+	if err := f.getJSON(path, &series); err != nil {
+		return nil, err
+	}
+	return series["activities-"+resource], nil
+}
+
+// GetWeightLog returns the weight logged from start to end inclusive, at most
+// 31 days.
+func (f FitbitClient) GetWeightLog(start, end time.Time) ([]WeightRecord, error) {
+	var weight WeightResponse
+	path := fmt.Sprintf("/1/user/-/body/log/weight/date/%s/%s.json", start.Format(dateFmt), end.Format(dateFmt))
+	if err := f.getJSON(path, &weight); err != nil {
+		return nil, err
+	}
+	return weight.WeightRecords, nil
+}
+
+// GetSleep returns the sleep logged from start to end inclusive, at most 100
+// days.
+func (f FitbitClient) GetSleep(start, end time.Time) ([]SleepLog, error) {
+	var sleep SleepResponse
+	path := fmt.Sprintf("/1.2/user/-/sleep/date/%s/%s.json", start.Format(dateFmt), end.Format(dateFmt))
+	if err := f.getJSON(path, &sleep); err != nil {
+		return nil, err
+	}
+	return sleep.Sleep, nil
+}
@@ -29,3 +29,20 @@ type FitbitGoals struct {
 	ActiveMinutes int `json:"activeMinutes"`
 	Steps         int `json:"steps"`
 }
+
+// SeriesPoint is one day of an activity time series. Fitbit sends the value
+// as a string.
+type SeriesPoint struct {
+	DateTime string `json:"dateTime"`
+	Value    string `json:"value"`
+}
+
+type SleepResponse struct {
+	Sleep []SleepLog `json:"sleep"`
+}
+
+type SleepLog struct {
+	DateOfSleep   string `json:"dateOfSleep"`
+	MinutesAsleep int    `json:"minutesAsleep"`
+	IsMainSleep   bool   `json:"isMainSleep"`
+}
@@ -1,7 +1,9 @@
 package habitica
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,6 +18,9 @@ type HabiticaClient struct {
 
 const habUrl = "https://habitica.com/api/v3"
 
+// ErrNotFound is returned when Habitica has no task with the requested id.
+var ErrNotFound = errors.New("habitica task not found")
+
 func NewHabiticaClient(apiUser, apiKey string) HabiticaClient {
 	return HabiticaClient{apiUser: apiUser, apiKey: apiKey, client: http.DefaultClient}
 }
@@ -32,9 +37,19 @@ func (h *HabiticaClient) habiticaRequest(method, path string, body io.Reader) (*
 }
 
 func (h *HabiticaClient) ScoreDaily(dailyId string) error {
+	return h.scoreTask(dailyId, "up")
+}
+
+// UnscoreTask scores a task down, which unchecks a daily or todo and counts
+// against a habit.
+func (h *HabiticaClient) UnscoreTask(taskId string) error {
+	return h.scoreTask(taskId, "down")
+}
+
+func (h *HabiticaClient) scoreTask(taskId, direction string) error {
 	req, err := h.habiticaRequest(
 		http.MethodPost,
-		fmt.Sprintf("%s/tasks/%s/score/up", habUrl, dailyId),
+		fmt.Sprintf("%s/tasks/%s/score/%s", habUrl, taskId, direction),
 		nil,
 	)
 	if err != nil {
@@ -44,6 +59,7 @@ func (h *HabiticaClient) ScoreDaily(dailyId string) error {
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
 		slog.Error("error calling habitica api", "code", resp.StatusCode, "resp", respBody, "url", req.URL)
@@ -108,3 +124,108 @@ func (h *HabiticaClient) GetDailys() ([]Daily, error) {
 	}
 	return habResp.Data, nil
 }
+
+func (h *HabiticaClient) GetTask(taskId string) (Task, error) {
+	req, err := h.habiticaRequest(
+		http.MethodGet,
+		fmt.Sprintf("%s/tasks/%s", habUrl, taskId),
+		nil,
+	)
+	if err != nil {
+		return Task{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Task{}, fmt.Errorf("unable to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Task{}, fmt.Errorf("%w: %s", ErrNotFound, taskId)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		slog.Error("error calling habitica api", "code", resp.StatusCode, "resp", respBody, "url", req.URL)
+		return Task{}, fmt.Errorf("got non-200 status code: %d", resp.StatusCode)
+	}
+
+	var taskResp TaskResponse
+	err = json.NewDecoder(resp.Body).Decode(&taskResp)
+	if err != nil {
+		return Task{}, fmt.Errorf("error decoding response: %w", err)
+	}
+	return taskResp.Data, nil
+}
+
+// CreateTodo adds a todo with the given text and returns it.
+func (h *HabiticaClient) CreateTodo(text string) (Task, error) {
+	body, err := json.Marshal(map[string]string{"type": "todo", "text": text})
+	if err != nil {
+		return Task{}, fmt.Errorf("unable to encode todo: %w", err)
+	}
+	req, err := h.habiticaRequest(http.MethodPost, fmt.Sprintf("%s/tasks/user", habUrl), bytes.NewReader(body))
+	if err != nil {
+		return Task{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var taskResp TaskResponse
+	if err := h.doTaskRequest(req, http.StatusCreated, &taskResp); err != nil {
+		return Task{}, err
+	}
+	return taskResp.Data, nil
+}
+
+// UpdateTaskText changes the text of a task.
+func (h *HabiticaClient) UpdateTaskText(taskId, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("unable to encode task: %w", err)
+	}
+	req, err := h.habiticaRequest(http.MethodPut, fmt.Sprintf("%s/tasks/%s", habUrl, taskId), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return h.doTaskRequest(req, http.StatusOK, nil)
+}
+
+// DeleteTask deletes a task. Deleting a task that is already gone succeeds.
+func (h *HabiticaClient) DeleteTask(taskId string) error {
+	req, err := h.habiticaRequest(http.MethodDelete, fmt.Sprintf("%s/tasks/%s", habUrl, taskId), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	err = h.doTaskRequest(req, http.StatusOK, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// doTaskRequest performs a request about a task, decoding the response into
+// out when it isn't nil.
+func (h *HabiticaClient) doTaskRequest(req *http.Request, wantStatus int, out any) error {
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	if resp.StatusCode != wantStatus {
+		respBody, _ := io.ReadAll(resp.Body)
+		slog.Error("error calling habitica api", "code", resp.StatusCode, "resp", respBody, "url", req.URL)
+		return fmt.Errorf("got non-%d status code: %d", wantStatus, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package habitica
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // generic struct for habitica responses, compose into different types
@@ -40,17 +42,62 @@ type Habit struct {
 	CounterUp   int    `json:"counterUp"`
 	CounterDown int    `json:"counterDown"`
 	Frequency   string `json:"frequency"`
+	// History has an entry per day the habit was scored; Habitica merges
+	// older entries
+	History []HabitHistory `json:"history"`
 	Task
 }
 
+type HabitHistory struct {
+	Date       HistoryTime `json:"date"`
+	ScoredUp   int         `json:"scoredUp"`
+	ScoredDown int         `json:"scoredDown"`
+}
+
 type Daily struct {
 	Completed bool   `json:"completed"`
 	Repeat    Repeat `json:"repeat"`
 	IsDue     bool   `json:"isDue"`
 	Streak    int    `json:"streak"`
+	// History has an entry per day Habitica's cron ran for the daily
+	History []DailyHistory `json:"history"`
 	Task
 }
 
+type DailyHistory struct {
+	Date      HistoryTime `json:"date"`
+	IsDue     bool        `json:"isDue"`
+	Completed bool        `json:"completed"`
+}
+
+// HistoryTime is a task history date. Habitica sends milliseconds since the
+// epoch, and a date string in some older entries.
+type HistoryTime struct {
+	time.Time
+}
+
+func (t *HistoryTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err == nil {
+		t.Time = time.UnixMilli(int64(ms))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid history date %s: %w", data, err)
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		// an unreadable date leaves the entry at the zero time
+		return nil
+	}
+	t.Time = parsed
+	return nil
+}
+
 type Repeat struct {
 	Mon bool `json:"m"`
 	Tue bool `json:"t"`
@@ -71,6 +118,25 @@ type DailysResponse struct {
 	HabiticaResponse
 }
 
-func (h *Habit) ParseGoal() (int, error) {
-	return strconv.Atoi(strings.Trim(h.Notes, "Goal: "))
+type TaskResponse struct {
+	Data Task `json:"data"`
+	HabiticaResponse
+}
+
+// ParseGoal reads a goal written in the habit's notes as a line such as
+// "Goal: 8" or "Goal: 8 glasses", returning the target and unit.
+func (h *Habit) ParseGoal() (int, string, error) {
+	for _, line := range strings.Split(h.Notes, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < len("goal:") || !strings.EqualFold(line[:len("goal:")], "goal:") {
+			continue
+		}
+		target, unit, _ := strings.Cut(strings.TrimSpace(line[len("goal:"):]), " ")
+		n, err := strconv.Atoi(target)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid goal %q in notes of %s: %w", line, h.Text, err)
+		}
+		return n, strings.TrimSpace(unit), nil
+	}
+	return 0, "", fmt.Errorf("no goal in notes of %s", h.Text)
 }
@@ -1,5 +1,7 @@
 package todoist
 
+import "encoding/json"
+
 type TaskResp struct {
 	Tasks []Task `json:"results"`
 }
@@ -24,6 +26,23 @@ type Task struct {
 	// Duration *Duration `json:"duration,omitempty"`
 }
 
+// NewTask is the body for creating a task.
+type NewTask struct {
+	Content   string `json:"content"`
+	ProjectId string `json:"project_id,omitempty"`
+}
+
+type ProjectResp struct {
+	Projects []Project `json:"results"`
+}
+
+type Project struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ParentId   string `json:"parent_id"`
+	IsArchived bool   `json:"is_archived"`
+}
+
 type Due struct {
 	String      string  `json:"string"`
 	Date        *string `json:"date"`
@@ -70,3 +89,10 @@ type ProjectColors struct {
 type Goals struct {
 	DailyGoal int `json:"daily_goal"`
 }
+
+// CompletedTasksResp is a page of tasks completed in a date range. Items have
+// the same shape as the event data of item webhooks.
+type CompletedTasksResp struct {
+	Items      []json.RawMessage `json:"items"`
+	NextCursor *string           `json:"next_cursor"`
+}
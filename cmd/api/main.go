@@ -1,11 +1,48 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/database"
+	"misc/internal/models"
 	"misc/internal/server"
+	"misc/internal/services"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrate(os.Args[2:])
+		case "replay":
+			err = runReplay(os.Args[2:])
+		case "simulate":
+			err = runSimulate(os.Args[2:])
+		case "goals":
+			err = runGoals(os.Args[2:])
+		case "snapshots":
+			err = runSnapshots(os.Args[2:])
+		case "tokens":
+			err = runTokens(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	server := server.NewServer()
 
@@ -14,3 +51,272 @@ func main() {
 		panic(fmt.Sprintf("cannot start server: %s", err))
 	}
 }
+
+// runMigrate handles `api migrate [status|up|down [n]]`.
+func runMigrate(args []string) error {
+	db := database.Open()
+	defer db.Close()
+
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		applied, err := db.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps: %q", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := db.MigrateDown(steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected status, up or down", cmd)
+	}
+}
+
+// runReplay handles `api replay failed` and `api replay <event id>...`,
+// reprocessing journaled webhook events through the automation services.
+func runReplay(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: api replay failed | <event id>...")
+	}
+
+	db := database.New()
+	defer db.Close()
+	habClient := habitica.NewHabiticaClient(
+		os.Getenv("HABITICA_API_USER"),
+		os.Getenv("HABITICA_API_KEY"),
+	)
+	todoistService := services.NewTodoistService(
+		todoist.NewClient(os.Getenv("TODOIST_API_KEY")),
+		todoist.NewSyncClient(os.Getenv("TODOIST_API_KEY")),
+	)
+	webhooks := server.NewWebhookService(db, &habClient, &todoistService)
+
+	var events []models.Event
+	var err error
+	if args[0] == "failed" {
+		events, err = webhooks.ReplayFailed()
+	} else {
+		ids := make([]int64, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid event id: %q", arg)
+			}
+			ids = append(ids, id)
+		}
+		events, err = webhooks.Replay(ids)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSOURCE\tTYPE\tSTATUS\tERROR")
+	for _, e := range events {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.Id, e.Source, e.EventType, e.Status, e.Error)
+	}
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// runSimulate handles `api simulate <todoist|habitica> [payload file]`,
+// reading the webhook payload from stdin when no file is given and printing
+// the automations it runs and the calls they would make.
+func runSimulate(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: api simulate todoist|habitica [payload file]")
+	}
+
+	var payload []byte
+	var err error
+	if len(args) == 2 {
+		payload, err = os.ReadFile(args[1])
+	} else {
+		payload, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading payload: %w", err)
+	}
+
+	db := database.New()
+	defer db.Close()
+	sim, err := services.NewSimulator(db).Simulate(args[0], payload)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s: %s\n", sim.Source, sim.EventType, sim.Status)
+	if sim.Error != "" {
+		fmt.Printf("error: %s\n", sim.Error)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nAUTOMATION\tNAME\tOUTCOME\tERROR")
+	for _, r := range sim.Automations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Key, r.Name, r.Outcome, r.Error)
+	}
+	fmt.Fprintln(w, "\nCALL\tTASK\tCONTENT")
+	for _, c := range sim.Calls {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Kind, c.TaskId, c.Content)
+	}
+	return w.Flush()
+}
+
+// runGoals handles `api goals [list|import]`, import creating goals from
+// "Goal: N" lines in Habitica habit notes.
+func runGoals(args []string) error {
+	cmd := "list"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	var goals []models.HabitGoal
+	var err error
+	switch cmd {
+	case "list":
+		goals, err = db.ListHabitGoals()
+	case "import":
+		habClient := habitica.NewHabiticaClient(
+			os.Getenv("HABITICA_API_USER"),
+			os.Getenv("HABITICA_API_KEY"),
+		)
+		goals, err = services.ImportHabitGoals(db, &habClient)
+	default:
+		return errors.New("usage: api goals [list|import]")
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HABIT\tTARGET\tPERIOD\tUNIT")
+	for _, g := range goals {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", g.HabitId, g.Target, g.Period, g.Unit)
+	}
+	return w.Flush()
+}
+
+// runSnapshots handles `api snapshots now` and `api snapshots backfill <from>
+// [to]`, dates as YYYY-MM-DD and to defaulting to yesterday.
+func runSnapshots(args []string) error {
+	usage := errors.New("usage: api snapshots now | backfill <from> [to]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	db := database.New()
+	defer db.Close()
+	snapshots := server.NewSnapshotter(db)
+
+	switch args[0] {
+	case "now":
+		return snapshots.Snapshot(context.Background())
+	case "backfill":
+		if len(args) < 2 || len(args) > 3 {
+			return usage
+		}
+		from, err := time.ParseInLocation(models.SnapshotDateFormat, args[1], time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid from date %q", args[1])
+		}
+		to := time.Now().AddDate(0, 0, -1)
+		if len(args) == 3 {
+			if to, err = time.ParseInLocation(models.SnapshotDateFormat, args[2], time.Local); err != nil {
+				return fmt.Errorf("invalid to date %q", args[2])
+			}
+		}
+		filled, err := snapshots.Backfill(context.Background(), from, to)
+		fmt.Printf("filled %d values\n", filled)
+		return err
+	}
+	return usage
+}
+
+// runTokens handles `api tokens [list]`, `api tokens create <name>
+// <scope,...>` and `api tokens delete <id>`.
+func runTokens(args []string) error {
+	usage := errors.New("usage: api tokens [list] | create <name> <widget|admin,...> | delete <id>")
+	cmd := "list"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	switch cmd {
+	case "list":
+		tokens, err := db.ListAPITokens()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tLAST USED")
+		for _, t := range tokens {
+			lastUsed := "never"
+			if t.LastUsedAt != nil {
+				lastUsed = t.LastUsedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", t.Id, t.Name, strings.Join(t.Scopes, ","), t.CreatedAt.Local().Format(time.DateTime), lastUsed)
+		}
+		return w.Flush()
+	case "create":
+		if len(args) != 3 {
+			return usage
+		}
+		_, secret, err := services.NewAuthService(db, "").CreateToken(args[1], strings.Split(args[2], ","))
+		if err != nil {
+			return err
+		}
+		fmt.Println(secret)
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return usage
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid token id: %q", args[1])
+		}
+		return db.DeleteAPIToken(id)
+	}
+	return usage
+}
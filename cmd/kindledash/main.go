@@ -8,6 +8,9 @@ import (
 	"log/slog"
 	"misc/clients/habitica"
 	"misc/clients/todoist"
+	"misc/internal/database"
+	"misc/internal/models"
+	"misc/internal/services"
 	"net/http"
 	"os"
 	"os/signal"
@@ -37,6 +40,9 @@ const (
 )
 
 func main() {
+	if os.Getenv("DB_URL") != "" {
+		goalStore = database.Open()
+	}
 	if len(os.Args) > 1 && os.Args[1] == "test" {
 		f, _ := tea.LogToFile("test.log", "")
 		defer f.Close()
@@ -109,7 +115,7 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 
 type model struct {
 	fitbitClient  *http.Client
-	habClient     habitica.HabiticaClient
+	habClient     services.HabiticaTaskRepository
 	todoistClient *todoist.TodoistRestClient
 	width         int
 	height        int
@@ -117,12 +123,23 @@ type model struct {
 	quitStyle     lipgloss.Style
 	dailys        []habitica.Daily
 	habs          []habitica.Habit
+	goals         map[string]models.HabitGoal
 	chores        []todoist.Task
 	hygiene       []todoist.Task
 	activity      ActivityResponse
 	err           error
 }
 
+// cache is shared by every session, so several kindles refreshing at once
+// make one set of Habitica calls.
+var cache = services.NewCache()
+
+// goalStore is the api server's database, opened once when DB_URL is set, to
+// read habit goals from. Without it the habits show only their counts.
+var goalStore interface {
+	ListHabitGoals() ([]models.HabitGoal, error)
+}
+
 func newModel(width, height int, renderer *lipgloss.Renderer) model {
 	fitbitClient := createFitbitClient()
 	habClient := habitica.NewHabiticaClient(
@@ -135,7 +152,7 @@ func newModel(width, height int, renderer *lipgloss.Renderer) model {
 
 	m := model{
 		fitbitClient:  fitbitClient,
-		habClient:     habClient,
+		habClient:     services.NewCachedHabitica(&habClient, cache, services.DefaultCacheTTLs.Habitica),
 		todoistClient: todoistClient,
 		width:         width,
 		height:        height,
@@ -163,6 +180,18 @@ func (m model) updateState() (model, error) {
 	}
 	m.habs = habs
 
+	// goals are optional, without them the habits show only their counts
+	m.goals = make(map[string]models.HabitGoal)
+	if goalStore != nil {
+		goals, err := goalStore.ListHabitGoals()
+		if err != nil {
+			slog.Warn("error getting habit goals", "err", err)
+		}
+		for _, g := range goals {
+			m.goals[g.HabitId] = g
+		}
+	}
+
 	chores, err := m.updateChores()
 	if err != nil {
 		slog.Error("error updating chores", "err", err)
@@ -371,7 +400,11 @@ func (m model) View() string {
 
 	habRows := make([][]string, 0)
 	for _, h := range m.habs {
-		habRows = append(habRows, []string{h.Text, fmt.Sprintf("%d", h.CounterUp)})
+		count := fmt.Sprintf("%d", h.CounterUp)
+		if g, ok := m.goals[h.ID]; ok {
+			count = fmt.Sprintf("%d/%d", h.CounterUp, g.Target)
+		}
+		habRows = append(habRows, []string{h.Text, count})
 	}
 	habitTable := table.New().Border(lipgloss.HiddenBorder()).Rows(habRows...).Render()
 
@@ -0,0 +1,10 @@
+// Fires an "activity" event on the body for each webhook and Habitica or
+// Todoist call streamed from /events/stream, for htmx to refresh on.
+(function () {
+  var stream = new EventSource("/events/stream");
+  ["webhook", "call"].forEach(function (kind) {
+    stream.addEventListener(kind, function () {
+      htmx.trigger(document.body, "activity");
+    });
+  });
+})();
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"misc/internal/models"
+)
+
+// ChartSize is the size of a chart in pixels.
+type ChartSize struct {
+	Width  int
+	Height int
+}
+
+var DefaultChartSize = ChartSize{Width: 300, Height: 80}
+
+// DashboardCharts are the charts shown below the dashboard.
+var DashboardCharts = []string{"steps", "weight", "water", "todoist"}
+
+func chartURL(name string) string {
+	return "/charts/" + name + ".svg"
+}
+
+// chartTop leaves room above the plot for the title.
+const chartTop = 16
+
+type chartBar struct {
+	X, Y, Width, Height float64
+	Reached             bool
+	// GoalY is where the day's goal is drawn, if it had one
+	GoalY *float64
+}
+
+type chartXY struct {
+	X, Y float64
+}
+
+// dayIndex is how many days into the chart a point's date is.
+func dayIndex(chart models.Chart, date string) int {
+	from, err := time.Parse(models.SnapshotDateFormat, chart.From)
+	if err != nil {
+		return 0
+	}
+	day, err := time.Parse(models.SnapshotDateFormat, date)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(day.Sub(from).Hours() / 24))
+}
+
+// chartBars lays out a bar per day with a value, scaled from 0 to the highest
+// value or goal.
+func chartBars(chart models.Chart, size ChartSize) []chartBar {
+	plot := float64(size.Height - chartTop)
+	slot := float64(size.Width) / float64(max(chart.Days, 1))
+	gap := 0.0
+	if slot > 3 {
+		gap = 1
+	}
+
+	top := 0.0
+	for _, p := range chart.Points {
+		top = max(top, p.Value)
+		if p.Goal != nil {
+			top = max(top, *p.Goal)
+		}
+	}
+	if top == 0 {
+		top = 1
+	}
+
+	bars := make([]chartBar, 0, len(chart.Points))
+	for _, p := range chart.Points {
+		height := p.Value / top * plot
+		bar := chartBar{
+			X:      float64(dayIndex(chart, p.Date)) * slot,
+			Y:      float64(size.Height) - height,
+			Width:  slot - gap,
+			Height: height,
+		}
+		if p.Goal != nil {
+			goalY := float64(size.Height) - *p.Goal/top*plot
+			bar.GoalY = &goalY
+			bar.Reached = p.Value >= *p.Goal
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// sparkline lays out the points of a line scaled between the lowest and
+// highest values, inset so the line and its end dot aren't clipped.
+func sparkline(chart models.Chart, size ChartSize) []chartXY {
+	const inset = 3
+	if len(chart.Points) == 0 {
+		return nil
+	}
+	lo, hi := chart.Points[0].Value, chart.Points[0].Value
+	for _, p := range chart.Points {
+		lo, hi = min(lo, p.Value), max(hi, p.Value)
+	}
+	span := hi - lo
+	if span == 0 {
+		span = 1
+	}
+
+	width := float64(size.Width - 2*inset)
+	plot := float64(size.Height - chartTop - 2*inset)
+	step := width / float64(max(chart.Days-1, 1))
+	line := make([]chartXY, 0, len(chart.Points))
+	for _, p := range chart.Points {
+		line = append(line, chartXY{
+			X: inset + float64(dayIndex(chart, p.Date))*step,
+			Y: float64(size.Height-inset) - (p.Value-lo)/span*plot,
+		})
+	}
+	return line
+}
+
+func sparklinePoints(line []chartXY) string {
+	points := make([]string, 0, len(line))
+	for _, p := range line {
+		points = append(points, coord(p.X)+","+coord(p.Y))
+	}
+	return strings.Join(points, " ")
+}
+
+func coord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func barFill(bar chartBar) string {
+	if bar.Reached || bar.GoalY == nil {
+		return "#000"
+	}
+	return "#999"
+}
+
+// latestValue labels the chart with its most recent value.
+func latestValue(chart models.Chart) string {
+	if len(chart.Points) == 0 {
+		return "no data"
+	}
+	value := chart.Points[len(chart.Points)-1].Value
+	label := strconv.FormatFloat(value, 'f', 1, 64)
+	if value == math.Trunc(value) {
+		label = strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	if chart.Unit != "" {
+		label += " " + chart.Unit
+	}
+	return label
+}
+
+func viewBox(size ChartSize) string {
+	return fmt.Sprintf("0 0 %d %d", size.Width, size.Height)
+}
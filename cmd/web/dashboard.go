@@ -0,0 +1,51 @@
+package web
+
+import (
+	"fmt"
+	"misc/internal/models"
+)
+
+// DashboardURL serves the dashboard's content on its own, for refreshes.
+const DashboardURL = "/web/dashboard"
+
+// DashboardRefresh polls for fresh content, and refreshes soon after activity
+// is streamed, waiting for a burst of it to settle.
+const DashboardRefresh = "every 30s, activity from:body delay:1s"
+
+func dailyCheckURL(daily models.DashboardDaily) string {
+	action := "check"
+	if daily.Completed {
+		action = "uncheck"
+	}
+	return fmt.Sprintf("/web/dailies/%s/%s", daily.Id, action)
+}
+
+func taskCloseURL(task models.DashboardTask) string {
+	return fmt.Sprintf("/web/tasks/%s/close", task.Id)
+}
+
+// habitCount shows a habit's count against its goal, if it has one.
+func habitCount(habit models.DashboardHabit) string {
+	if habit.Goal == nil {
+		return fmt.Sprint(habit.Count)
+	}
+	count := fmt.Sprintf("%d / %d", habit.Count, habit.Goal.Target)
+	if habit.Goal.Unit != "" {
+		count += " " + habit.Goal.Unit
+	}
+	if habit.Goal.Period == models.GoalPeriodWeekly {
+		count += " this week"
+	}
+	return count
+}
+
+// failedSources are the dashboard sources that didn't load.
+func failedSources(dash models.Dashboard) []models.WidgetSourceStatus {
+	failed := make([]models.WidgetSourceStatus, 0)
+	for _, s := range dash.Sources {
+		if s.Status != models.WidgetSourceOK {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
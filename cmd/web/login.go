@@ -0,0 +1,9 @@
+package web
+
+// LoginForm is the web UI login page's state. Next is where to go after
+// logging in.
+type LoginForm struct {
+	Next    string
+	Error   string
+	Enabled bool
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"fmt"
+	"misc/internal/models"
+	"net/url"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// Rule kinds, matching the /api/rules/{kind} paths.
+const (
+	HabitRuleKind   = "habits"
+	TextRuleKind    = "text"
+	ProjectRuleKind = "projects"
+)
+
+// Picker sources served by /rules/{kind}/options.
+const (
+	HabitOptions   = "habits"
+	DailyOptions   = "dailys"
+	TaskOptions    = "tasks"
+	ProjectOptions = "projects"
+)
+
+// CounterOptions, MatchTypeOptions and OperatorOptions fill the rule forms'
+// fixed selects.
+var (
+	CounterOptions = []Option{
+		{models.HabitCounterUp, "Up scores"},
+		{models.HabitCounterNet, "Net (up minus down)"},
+	}
+	MatchTypeOptions = []Option{
+		{models.TextMatchPrefix, "Starts with"},
+		{models.TextMatchContains, "Contains"},
+		{models.TextMatchRegex, "Regular expression"},
+	}
+	OperatorOptions = []Option{
+		{models.RuleOperatorAnd, "All conditions (and)"},
+		{models.RuleOperatorOr, "Any condition (or)"},
+	}
+)
+
+// RuleForm is the state of a create or edit form for one rule kind.
+type RuleForm struct {
+	Kind   string
+	Id     int64
+	Values map[string]string
+	Error  string
+}
+
+// Option is a single entry in a picker select.
+type Option struct {
+	Value string
+	Label string
+}
+
+func (f RuleForm) Title() string {
+	action := "New"
+	if f.Id != 0 {
+		action = "Edit"
+	}
+	return fmt.Sprintf("%s %s", action, kindTitle(f.Kind))
+}
+
+func (f RuleForm) Action() string {
+	if f.Id != 0 {
+		return fmt.Sprintf("/rules/%s/%d", f.Kind, f.Id)
+	}
+	return fmt.Sprintf("/rules/%s", f.Kind)
+}
+
+func (f RuleForm) ValidateURL() string {
+	return ValidateURL(f.Kind)
+}
+
+// ValidateURL is the inline validation endpoint for a rule kind.
+func ValidateURL(kind string) string {
+	return fmt.Sprintf("/rules/%s/validate", kind)
+}
+
+func kindTitle(kind string) string {
+	switch kind {
+	case HabitRuleKind:
+		return "min habit rule"
+	case TextRuleKind:
+		return "Todoist text rule"
+	case ProjectRuleKind:
+		return "Todoist project rule"
+	}
+	return "rule"
+}
+
+func optionsURL(kind, source, name, selected string) string {
+	v := url.Values{"source": {source}, "name": {name}, "selected": {selected}}
+	return fmt.Sprintf("/rules/%s/options?%s", kind, v.Encode())
+}
+
+func ruleURL(kind string, id int64) string {
+	return fmt.Sprintf("/rules/%s/%d", kind, id)
+}
+
+func editURL(kind string, id int64) templ.SafeURL {
+	return templ.URL(fmt.Sprintf("/rules/%s/%d/edit", kind, id))
+}
+
+func newURL(kind string) templ.SafeURL {
+	return templ.URL(fmt.Sprintf("/rules/%s/new", kind))
+}
+
+// textRuleSummary describes a text rule's conditions for the rules table.
+func textRuleSummary(rule models.TodoistHabiticaTextRule) string {
+	conditions := make([]string, 0, len(rule.Labels)+2)
+	if rule.Rule != "" {
+		conditions = append(conditions, fmt.Sprintf("%s %q", rule.MatchType, rule.Rule))
+	}
+	for _, label := range rule.Labels {
+		conditions = append(conditions, "@"+label)
+	}
+	if rule.SectionId != "" {
+		conditions = append(conditions, "section "+rule.SectionId)
+	}
+	return strings.Join(conditions, " "+rule.Operator+" ")
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+	"time"
+)
+
+func (s *service) RecordAudit(entry models.AuditEntry) error {
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now().UTC()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO audit_log (occurredAt, action, source, remoteAddr, path, reason)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.OccurredAt, entry.Action, entry.Source, entry.RemoteAddr, entry.Path, entry.Reason,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns the most recent audit entries, newest first.
+func (s *service) ListAuditEntries(limit int) ([]models.AuditEntry, error) {
+	entries := make([]models.AuditEntry, 0)
+	rows, err := s.db.Query(
+		`SELECT id, occurredAt, action, source, remoteAddr, path, reason
+		FROM audit_log ORDER BY occurredAt DESC, id DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return entries, fmt.Errorf("error listing audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		err := rows.Scan(&e.Id, &e.OccurredAt, &e.Action, &e.Source, &e.RemoteAddr, &e.Path, &e.Reason)
+		if err != nil {
+			return entries, fmt.Errorf("error scanning audit row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+	"strings"
+	"time"
+)
+
+const apiTokenColumns = `id, name, scopes, createdAt, lastUsedAt`
+
+func scanAPIToken(row interface{ Scan(...any) error }) (models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	err := row.Scan(&t.Id, &t.Name, &scopes, &t.CreatedAt, &t.LastUsedAt)
+	t.Scopes = strings.Split(scopes, ",")
+	return t, err
+}
+
+// CreateAPIToken stores a token under the hash of its secret.
+func (s *service) CreateAPIToken(t models.APIToken, tokenHash string) (models.APIToken, error) {
+	if err := t.Validate(); err != nil {
+		return t, err
+	}
+	t.CreatedAt = time.Now().UTC()
+	res, err := s.db.Exec(
+		`INSERT INTO api_tokens (name, tokenHash, scopes, createdAt) VALUES (?, ?, ?, ?)`,
+		t.Name, tokenHash, strings.Join(t.Scopes, ","), t.CreatedAt,
+	)
+	if err != nil {
+		return t, fmt.Errorf("error creating api token %s: %w", t.Name, translateError(err))
+	}
+	t.Id, err = res.LastInsertId()
+	if err != nil {
+		return t, fmt.Errorf("error reading api token id: %w", err)
+	}
+	return t, nil
+}
+
+func (s *service) ListAPITokens() ([]models.APIToken, error) {
+	tokens := make([]models.APIToken, 0)
+	rows, err := s.db.Query(`SELECT ` + apiTokenColumns + ` FROM api_tokens ORDER BY name`)
+	if err != nil {
+		return tokens, fmt.Errorf("error listing api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return tokens, fmt.Errorf("error scanning api token row: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *service) FindAPIToken(tokenHash string) (models.APIToken, error) {
+	row := s.db.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE tokenHash = ?`, tokenHash)
+	t, err := scanAPIToken(row)
+	if err != nil {
+		return t, fmt.Errorf("error retrieving api token: %w", translateError(err))
+	}
+	return t, nil
+}
+
+func (s *service) TouchAPIToken(id int64, usedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE api_tokens SET lastUsedAt = ? WHERE id = ?`, usedAt, id); err != nil {
+		return fmt.Errorf("error recording use of api token %d: %w", id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteAPIToken(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting api token %d: %w", id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error deleting api token %d: %w", id, err)
+	}
+	return nil
+}
+
+// CreateSession stores a session under the hash of its secret, dropping
+// expired sessions.
+func (s *service) CreateSession(sessionHash string, session models.Session) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting session creation: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE expiresAt <= ?`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("error purging expired sessions: %w", err)
+	}
+	_, err = tx.Exec(
+		`INSERT INTO sessions (sessionHash, scopes, createdAt, expiresAt) VALUES (?, ?, ?, ?)`,
+		sessionHash, strings.Join(session.Scopes, ","), session.CreatedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating session: %w", err)
+	}
+	return tx.Commit()
+}
+
+// FindSession returns the session with the hash, unless it has expired.
+func (s *service) FindSession(sessionHash string) (models.Session, error) {
+	var session models.Session
+	var scopes string
+	err := s.db.QueryRow(
+		`SELECT scopes, createdAt, expiresAt FROM sessions WHERE sessionHash = ? AND expiresAt > ?`,
+		sessionHash, time.Now().UTC(),
+	).Scan(&scopes, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return session, fmt.Errorf("error retrieving session: %w", translateError(err))
+	}
+	session.Scopes = strings.Split(scopes, ",")
+	return session, nil
+}
+
+func (s *service) DeleteSession(sessionHash string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE sessionHash = ?`, sessionHash); err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"misc/internal/models"
+	"strings"
+	"time"
+)
+
+const automationColumns = `id, source, name, enabled, triggerKind, triggerEvent, triggerSubject, matchMode,
+	conditions, actions, groupName, priority, stop, fallback, once`
+
+func scanAutomation(row interface{ Scan(...any) error }) (models.Automation, error) {
+	var a models.Automation
+	var conditions, actions string
+	err := row.Scan(
+		&a.Id, &a.Source, &a.Name, &a.Enabled, &a.Trigger.Kind, &a.Trigger.Event, &a.Trigger.Subject, &a.Match,
+		&conditions, &actions, &a.Group, &a.Priority, &a.Stop, &a.Fallback, &a.Once,
+	)
+	if err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal([]byte(conditions), &a.Conditions); err != nil {
+		return a, fmt.Errorf("error decoding conditions of automation %d: %w", a.Id, err)
+	}
+	if err := json.Unmarshal([]byte(actions), &a.Actions); err != nil {
+		return a, fmt.Errorf("error decoding actions of automation %d: %w", a.Id, err)
+	}
+	return a, nil
+}
+
+// encodeAutomation returns the JSON stored for an automation's conditions and
+// actions.
+func encodeAutomation(a models.Automation) (string, string, error) {
+	conditions, err := encodeJSON(a.Conditions)
+	if err != nil {
+		return "", "", fmt.Errorf("error encoding conditions: %w", err)
+	}
+	actions, err := encodeJSON(a.Actions)
+	if err != nil {
+		return "", "", fmt.Errorf("error encoding actions: %w", err)
+	}
+	return conditions, actions, nil
+}
+
+// encodeJSON encodes v the way SQLite's JSON functions write it, leaving &, <
+// and > unescaped, so the unique indexes on the stored JSON match rows written
+// by the migrations.
+func encodeJSON(v any) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// ListAutomations lists every automation, including the ones stored for the
+// min habit, text and project rules.
+func (s *service) ListAutomations() ([]models.Automation, error) {
+	return s.queryAutomations(`SELECT ` + automationColumns + ` FROM automations ORDER BY id`)
+}
+
+func (s *service) queryAutomations(query string, args ...any) ([]models.Automation, error) {
+	automations := make([]models.Automation, 0)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return automations, fmt.Errorf("error listing automations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAutomation(rows)
+		if err != nil {
+			return automations, fmt.Errorf("error scanning automation row: %w", err)
+		}
+		automations = append(automations, a)
+	}
+	return automations, rows.Err()
+}
+
+func (s *service) GetAutomation(id int64) (models.Automation, error) {
+	row := s.db.QueryRow(`SELECT `+automationColumns+` FROM automations WHERE id = ?`, id)
+	a, err := scanAutomation(row)
+	if err != nil {
+		return a, fmt.Errorf("error retrieving automation %d: %w", id, translateError(err))
+	}
+	return a, nil
+}
+
+// getAutomation retrieves an automation only if it was created as source.
+func (s *service) getAutomation(source string, id int64) (models.Automation, error) {
+	row := s.db.QueryRow(`SELECT `+automationColumns+` FROM automations WHERE id = ? AND source = ?`, id, source)
+	a, err := scanAutomation(row)
+	if err != nil {
+		return a, fmt.Errorf("error retrieving %s automation %d: %w", source, id, translateError(err))
+	}
+	return a, nil
+}
+
+func (s *service) CreateAutomation(a models.Automation) (models.Automation, error) {
+	a.SetDefaults()
+	if err := a.Validate(); err != nil {
+		return a, err
+	}
+	conditions, actions, err := encodeAutomation(a)
+	if err != nil {
+		return a, err
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO automations (source, name, enabled, triggerKind, triggerEvent, triggerSubject, matchMode,
+		conditions, actions, groupName, priority, stop, fallback, once)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Source, a.Name, a.Enabled, a.Trigger.Kind, a.Trigger.Event, a.Trigger.Subject, a.Match,
+		conditions, actions, a.Group, a.Priority, a.Stop, a.Fallback, a.Once,
+	)
+	if err != nil {
+		return a, fmt.Errorf("error creating automation: %w", translateError(err))
+	}
+	a.Id, err = res.LastInsertId()
+	if err != nil {
+		return a, fmt.Errorf("error reading automation id: %w", err)
+	}
+	return a, nil
+}
+
+// UpdateAutomation rewrites an automation of the same source; rules can't be
+// turned into automations or other rules.
+func (s *service) UpdateAutomation(a models.Automation) error {
+	a.SetDefaults()
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	conditions, actions, err := encodeAutomation(a)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(
+		`UPDATE automations SET name = ?, enabled = ?, triggerKind = ?, triggerEvent = ?, triggerSubject = ?,
+		matchMode = ?, conditions = ?, actions = ?, groupName = ?, priority = ?, stop = ?, fallback = ?, once = ?
+		WHERE id = ? AND source = ?`,
+		a.Name, a.Enabled, a.Trigger.Kind, a.Trigger.Event, a.Trigger.Subject,
+		a.Match, conditions, actions, a.Group, a.Priority, a.Stop, a.Fallback, a.Once, a.Id, a.Source,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating automation %d: %w", a.Id, translateError(err))
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error updating automation %d: %w", a.Id, err)
+	}
+	return nil
+}
+
+// DeleteAutomation deletes an automation managed at /api/automations.
+func (s *service) DeleteAutomation(id int64) error {
+	return s.deleteAutomation(models.AutomationSourceStored, id)
+}
+
+func (s *service) deleteAutomation(source string, id int64) error {
+	res, err := s.db.Exec(`DELETE FROM automations WHERE id = ? AND source = ?`, id, source)
+	if err != nil {
+		return fmt.Errorf("error deleting %s automation %d: %w", source, id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error deleting %s automation %d: %w", source, id, err)
+	}
+	return s.clearFirings(models.Automation{Source: source, Id: id}.Key())
+}
+
+// MarkAutomationFired records that the automation identified by key fired on
+// day. It returns false if it had already fired that day.
+func (s *service) MarkAutomationFired(key, day string) (bool, error) {
+	res, err := s.db.Exec(
+		`INSERT OR IGNORE INTO automation_firings (automationKey, day, firedAt) VALUES (?, ?, ?)`,
+		key, day, time.Now().UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error marking automation %s fired: %w", key, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error marking automation %s fired: %w", key, err)
+	}
+	return n == 1, nil
+}
+
+// ClearAutomationFired forgets that the automation fired on day. It returns
+// false if it hadn't fired that day.
+func (s *service) ClearAutomationFired(key, day string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM automation_firings WHERE automationKey = ? AND day = ?`, key, day)
+	if err != nil {
+		return false, fmt.Errorf("error clearing automation %s firing: %w", key, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error clearing automation %s firing: %w", key, err)
+	}
+	return n == 1, nil
+}
+
+// HasAutomationFired reports whether the automation fired on day.
+func (s *service) HasAutomationFired(key, day string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM automation_firings WHERE automationKey = ? AND day = ?`,
+		key, day,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("error checking automation %s firing: %w", key, err)
+	}
+	return n > 0, nil
+}
+
+func (s *service) clearFirings(key string) error {
+	if _, err := s.db.Exec(`DELETE FROM automation_firings WHERE automationKey = ?`, key); err != nil {
+		return fmt.Errorf("error deleting firings of automation %s: %w", key, err)
+	}
+	return nil
+}
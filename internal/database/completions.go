@@ -0,0 +1,71 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+	"time"
+)
+
+// RecordCompletion links a Todoist completion to the Habitica tasks it scored.
+// The links share a timestamp so they can be undone together.
+func (s *service) RecordCompletion(todoistTaskId string, habiticaTaskIds []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error recording completion: %w", err)
+	}
+	defer tx.Rollback()
+
+	scoredAt := time.Now().UTC()
+	for _, habiticaTaskId := range habiticaTaskIds {
+		_, err := tx.Exec(
+			`INSERT INTO todoist_completions (todoistTaskId, habiticaTaskId, scoredAt) VALUES (?, ?, ?)`,
+			todoistTaskId, habiticaTaskId, scoredAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error recording completion: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLatestCompletions returns the links recorded by the most recent
+// completion of a Todoist task that hasn't been undone yet. It returns an
+// empty slice if there is none.
+func (s *service) GetLatestCompletions(todoistTaskId string) ([]models.TodoistCompletion, error) {
+	completions := make([]models.TodoistCompletion, 0)
+	rows, err := s.db.Query(
+		`SELECT id, todoistTaskId, habiticaTaskId, scoredAt FROM todoist_completions
+		WHERE todoistTaskId = ? AND undoneAt IS NULL AND scoredAt = (
+			SELECT MAX(scoredAt) FROM todoist_completions
+			WHERE todoistTaskId = ? AND undoneAt IS NULL
+		)`,
+		todoistTaskId, todoistTaskId,
+	)
+	if err != nil {
+		return completions, fmt.Errorf("error querying completions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.TodoistCompletion
+		if err := rows.Scan(&c.Id, &c.TodoistTaskId, &c.HabiticaTaskId, &c.ScoredAt); err != nil {
+			return completions, fmt.Errorf("error scanning completion row: %w", err)
+		}
+		completions = append(completions, c)
+	}
+	return completions, rows.Err()
+}
+
+func (s *service) MarkCompletionUndone(id int64) error {
+	res, err := s.db.Exec(
+		`UPDATE todoist_completions SET undoneAt = ? WHERE id = ?`,
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("error marking completion %d undone: %w", id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error marking completion %d undone: %w", id, err)
+	}
+	return nil
+}
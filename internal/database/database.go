@@ -24,15 +24,101 @@ type Service interface {
 	// It returns an error if the connection cannot be closed.
 	Close() error
 
+	// Init applies any pending schema migrations.
 	Init() error
 
-	GetHabitRule(string) (*models.HabiticaHabitRule, error)
+	// MigrationStatus lists every known migration and whether it is applied.
+	MigrationStatus() ([]MigrationStatus, error)
+	// MigrateUp applies all pending migrations.
+	MigrateUp() ([]Migration, error)
+	// MigrateDown rolls back up to the given number of applied migrations.
+	MigrateDown(int) ([]Migration, error)
+
 	GetTodoistHabiticaTextRules() ([]models.TodoistHabiticaTextRule, error)
-	GetTodoistHabiticaProjectRule(string) (models.TodoistHabiticaProjectRule, error)
+
+	ListHabitRules() ([]models.HabiticaHabitRule, error)
+	GetHabitRuleById(int64) (models.HabiticaHabitRule, error)
+	CreateHabitRule(models.HabiticaHabitRule) (models.HabiticaHabitRule, error)
+	UpdateHabitRule(models.HabiticaHabitRule) error
+	DeleteHabitRule(int64) error
+
+	GetTodoistHabiticaTextRuleById(int64) (models.TodoistHabiticaTextRule, error)
+	CreateTodoistHabiticaTextRule(models.TodoistHabiticaTextRule) (models.TodoistHabiticaTextRule, error)
+	UpdateTodoistHabiticaTextRule(models.TodoistHabiticaTextRule) error
+	DeleteTodoistHabiticaTextRule(int64) error
+
+	ListTodoistHabiticaProjectRules() ([]models.TodoistHabiticaProjectRule, error)
+	GetTodoistHabiticaProjectRuleById(int64) (models.TodoistHabiticaProjectRule, error)
+	CreateTodoistHabiticaProjectRule(models.TodoistHabiticaProjectRule) (models.TodoistHabiticaProjectRule, error)
+	UpdateTodoistHabiticaProjectRule(models.TodoistHabiticaProjectRule) error
+	DeleteTodoistHabiticaProjectRule(int64) error
+
+	ListAutomations() ([]models.Automation, error)
+	GetAutomation(int64) (models.Automation, error)
+	CreateAutomation(models.Automation) (models.Automation, error)
+	UpdateAutomation(models.Automation) error
+	DeleteAutomation(int64) error
+	MarkAutomationFired(string, string) (bool, error)
+	ClearAutomationFired(string, string) (bool, error)
+	HasAutomationFired(string, string) (bool, error)
+
+	CreateEvent(models.Event) (models.Event, error)
+	GetEvent(int64) (models.Event, error)
+	ListEvents(models.EventFilter) ([]models.Event, error)
+	UpdateEventResult(models.Event) error
+
+	ClaimDelivery(string, string, int64, time.Duration) (bool, error)
+
+	RecordCompletion(string, []string) error
+	GetLatestCompletions(string) ([]models.TodoistCompletion, error)
+	MarkCompletionUndone(int64) error
+
+	ListHabitGoals() ([]models.HabitGoal, error)
+	GetHabitGoal(string) (models.HabitGoal, error)
+	SaveHabitGoal(models.HabitGoal) error
+	DeleteHabitGoal(string) error
+
+	SaveSnapshots([]models.Snapshot) error
+	FillSnapshots([]models.Snapshot) (int, error)
+	ListSnapshots(models.SnapshotFilter) ([]models.Snapshot, error)
+
+	ListWidgetMetrics(string) ([]models.WidgetMetric, error)
+	GetWidgetMetric(int64) (models.WidgetMetric, error)
+	CreateWidgetMetric(models.WidgetMetric) (models.WidgetMetric, error)
+	UpdateWidgetMetric(models.WidgetMetric) error
+	DeleteWidgetMetric(int64) error
+
+	ListTodoSyncProjects() ([]models.TodoSyncProject, error)
+	IsTodoSyncProject(string) (bool, error)
+	AddTodoSyncProject(string) (models.TodoSyncProject, error)
+	RemoveTodoSyncProject(string) error
+	ListTodoLinks() ([]models.TodoLink, error)
+	FindTodoLinkByTodoist(string) (*models.TodoLink, error)
+	FindTodoLinkByHabitica(string) (*models.TodoLink, error)
+	CreateTodoLink(models.TodoLink) (models.TodoLink, error)
+	UpdateTodoLink(models.TodoLink) error
+	DeleteTodoLink(int64) error
+
+	ListJobStates() ([]models.JobState, error)
+	GetJobState(string) (models.JobState, error)
+	SaveJobState(models.JobState) error
+
+	RecordAudit(models.AuditEntry) error
+	ListAuditEntries(int) ([]models.AuditEntry, error)
+
+	CreateAPIToken(models.APIToken, string) (models.APIToken, error)
+	ListAPITokens() ([]models.APIToken, error)
+	FindAPIToken(string) (models.APIToken, error)
+	TouchAPIToken(int64, time.Time) error
+	DeleteAPIToken(int64) error
+	CreateSession(string, models.Session) error
+	FindSession(string) (models.Session, error)
+	DeleteSession(string) error
 }
 
 type service struct {
-	db *sql.DB
+	db  *sql.DB
+	url string
 }
 
 var (
@@ -40,27 +126,42 @@ var (
 	dbInstance *service
 )
 
-func New() Service {
+// Open returns the shared database connection without touching the schema.
+func Open() Service {
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
+	dbInstance = openURL(dburl)
+	return dbInstance
+}
 
-	db, err := sql.Open("sqlite3", dburl)
+// OpenURL returns a connection to the database at url, separate from the
+// shared one, without touching the schema.
+func OpenURL(url string) Service {
+	return openURL(url)
+}
+
+func openURL(url string) *service {
+	db, err := sql.Open("sqlite3", url)
 	if err != nil {
 		// This will not be a connection error, but a DSN parse error or
 		// another initialization error.
 		log.Fatal(err)
 	}
-
-	dbInstance = &service{
-		db: db,
+	return &service{
+		db:  db,
+		url: url,
 	}
+}
 
-	if err := dbInstance.Init(); err != nil {
+// New returns the shared database connection with all migrations applied.
+func New() Service {
+	s := Open()
+	if err := s.Init(); err != nil {
 		log.Fatal(err)
 	}
-	return dbInstance
+	return s
 }
 
 // Health checks the health of the database connection by pinging the database.
@@ -119,104 +220,33 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", dburl)
+	log.Printf("Disconnected from database: %s", s.url)
 	return s.db.Close()
 }
 
-// Create initial tables in the database
+// Init brings the schema up to date by applying any pending migrations.
 func (s *service) Init() error {
-	_, err := s.db.Exec(
-		`CREATE TABLE IF NOT EXISTS HabiticaHabitRule (
-			id INTEGER PRIMARY KEY,
-			name TEXT,
-			habitId TEXT,
-			dailyId TEXT,
-			minScore INTEGER
-		)`,
-	)
+	applied, err := s.MigrateUp()
 	if err != nil {
 		return fmt.Errorf("error initializing database: %w", err)
 	}
-
-	_, err = s.db.Exec(
-		`CREATE TABLE IF NOT EXISTS TodoistHabitTextRule (
-			id INTEGER PRIMARY KEY,
-			name TEXT,
-			rule TEXT,
-			habitId TEXT
-		)`,
-	)
-	if err != nil {
-		return fmt.Errorf("error initializing database: %w", err)
-	}
-
-	_, err = s.db.Exec(
-		`CREATE TABLE IF NOT EXISTS TodoistHabitProjectRule (
-			id INTEGER PRIMARY KEY,
-			name TEXT,
-			todoistProjectId TEXT,
-			habitId TEXT
-		)`,
-	)
-
-	if err != nil {
-		return fmt.Errorf("error initializing database: %w", err)
-	}
-
-	_, err = s.db.Exec(
-		`CREATE TABLE IF NOT EXISTS TodoistHabitTextRule (
-			id INTEGER PRIMARY KEY,
-			name TEXT,
-			ruleText TEXT,
-			habitId TEXT
-		)`,
-	)
-
-	if err != nil {
-		return fmt.Errorf("error initializing database: %w", err)
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
 	}
 	return nil
 }
 
-func (s *service) GetHabitRule(habitId string) (*models.HabiticaHabitRule, error) {
-	var rule models.HabiticaHabitRule
-	row := s.db.QueryRow(
-		`SELECT habitId, dailyId, minScore FROM HabiticaHabitRule WHERE habitId = ?`,
-		habitId,
-	)
-	if err := row.Scan(&rule.HabitId, &rule.DailyId, &rule.MinScore); err != nil {
-		return nil, fmt.Errorf("error retrieving habit rule: %w", err)
-	}
-	return &rule, nil
-}
-
 func (s *service) GetTodoistHabiticaTextRules() ([]models.TodoistHabiticaTextRule, error) {
 	rules := make([]models.TodoistHabiticaTextRule, 0)
-	rows, err := s.db.Query(`SELECT name, rule, habitId FROM TodoistHabitTextRule;`)
+	automations, err := s.queryAutomations(
+		`SELECT `+automationColumns+` FROM automations WHERE source = ? ORDER BY priority DESC, id`,
+		models.AutomationSourceText,
+	)
 	if err != nil {
-		return rules, fmt.Errorf("error creating text rule query: %w", err)
+		return rules, fmt.Errorf("error listing text rules: %w", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var rule models.TodoistHabiticaTextRule
-		err := rows.Scan(&rule.Name, &rule.Rule, &rule.HabitId)
-		if err != nil {
-			return rules, fmt.Errorf("error scanning text rule row: %w", err)
-		}
-		rules = append(rules, rule)
+	for _, a := range automations {
+		rules = append(rules, a.TextRule())
 	}
 	return rules, nil
 }
-
-func (s *service) GetTodoistHabiticaProjectRule(projectId string) (models.TodoistHabiticaProjectRule, error) {
-	var rule models.TodoistHabiticaProjectRule
-	row := s.db.QueryRow(
-		`SELECT name, todoistProjectId, habitId FROM TodoistHabitProjectRule WHERE todoistProjectId = ?`,
-		projectId,
-	)
-	if err := row.Scan(&rule.Name, &rule.ProjectId, &rule.HabitId); err != nil {
-		return rule, fmt.Errorf("error retrieving todoist project rule: %w", err)
-	}
-	return rule, nil
-}
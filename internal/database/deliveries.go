@@ -0,0 +1,42 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClaimDelivery records that the webhook delivery identified by key is being
+// processed as eventId. It returns false if the key was already claimed and
+// has not yet expired, meaning the delivery is a retry of one already seen.
+// Expired keys are purged as part of the claim.
+func (s *service) ClaimDelivery(key, source string, eventId int64, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting delivery claim: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE expiresAt <= ?`, now); err != nil {
+		return false, fmt.Errorf("error purging expired deliveries: %w", err)
+	}
+
+	res, err := tx.Exec(
+		`INSERT OR IGNORE INTO webhook_deliveries (deliveryKey, source, eventId, receivedAt, expiresAt)
+		VALUES (?, ?, ?, ?, ?)`,
+		key, source, eventId, now, now.Add(ttl),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error claiming delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error claiming delivery: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing delivery claim: %w", err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"misc/internal/models"
+	"strings"
+	"time"
+)
+
+const eventColumns = `id, source, deliveryKey, eventType, payload, receivedAt, status, error, attempts, processedAt`
+
+func scanEvent(row interface{ Scan(...any) error }) (models.Event, error) {
+	var event models.Event
+	var processedAt sql.NullTime
+	err := row.Scan(
+		&event.Id, &event.Source, &event.DeliveryKey, &event.EventType, &event.Payload, &event.ReceivedAt,
+		&event.Status, &event.Error, &event.Attempts, &processedAt,
+	)
+	if processedAt.Valid {
+		event.ProcessedAt = &processedAt.Time
+	}
+	return event, err
+}
+
+// CreateEvent records an inbound webhook in the journal.
+func (s *service) CreateEvent(event models.Event) (models.Event, error) {
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = time.Now().UTC()
+	}
+	if event.Status == "" {
+		event.Status = models.EventStatusPending
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO events (source, deliveryKey, eventType, payload, receivedAt, status)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		event.Source, event.DeliveryKey, event.EventType, event.Payload, event.ReceivedAt, event.Status,
+	)
+	if err != nil {
+		return event, fmt.Errorf("error creating event: %w", err)
+	}
+	event.Id, err = res.LastInsertId()
+	if err != nil {
+		return event, fmt.Errorf("error reading event id: %w", err)
+	}
+	return event, nil
+}
+
+func (s *service) GetEvent(id int64) (models.Event, error) {
+	row := s.db.QueryRow(`SELECT `+eventColumns+` FROM events WHERE id = ?`, id)
+	event, err := scanEvent(row)
+	if err != nil {
+		return event, fmt.Errorf("error retrieving event %d: %w", id, translateError(err))
+	}
+	return event, nil
+}
+
+// ListEvents returns journal entries matching filter, newest first.
+func (s *service) ListEvents(filter models.EventFilter) ([]models.Event, error) {
+	var where []string
+	var args []any
+	if filter.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.EventType != "" {
+		where = append(where, "eventType = ?")
+		args = append(args, filter.EventType)
+	}
+	if filter.Subject != "" {
+		// malformed payloads are journaled too, and json_extract fails on them
+		where = append(where, `CASE WHEN json_valid(payload) THEN
+			COALESCE(json_extract(payload, '$.event_data.id'), json_extract(payload, '$.task.id')) END = ?`)
+		args = append(args, filter.Subject)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "receivedAt >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY receivedAt DESC, id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	events := make([]models.Event, 0)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return events, fmt.Errorf("error listing events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return events, fmt.Errorf("error scanning event row: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// UpdateEventResult records the outcome of a processing attempt.
+func (s *service) UpdateEventResult(event models.Event) error {
+	res, err := s.db.Exec(
+		`UPDATE events SET status = ?, error = ?, attempts = ?, processedAt = ? WHERE id = ?`,
+		event.Status, event.Error, event.Attempts, event.ProcessedAt, event.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating event %d: %w", event.Id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error updating event %d: %w", event.Id, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+)
+
+const habitGoalColumns = `habitId, target, period, unit`
+
+func scanHabitGoal(row interface{ Scan(...any) error }) (models.HabitGoal, error) {
+	var g models.HabitGoal
+	err := row.Scan(&g.HabitId, &g.Target, &g.Period, &g.Unit)
+	return g, err
+}
+
+func (s *service) ListHabitGoals() ([]models.HabitGoal, error) {
+	goals := make([]models.HabitGoal, 0)
+	rows, err := s.db.Query(`SELECT ` + habitGoalColumns + ` FROM habit_goals ORDER BY habitId`)
+	if err != nil {
+		return goals, fmt.Errorf("error listing habit goals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := scanHabitGoal(rows)
+		if err != nil {
+			return goals, fmt.Errorf("error scanning habit goal row: %w", err)
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+func (s *service) GetHabitGoal(habitId string) (models.HabitGoal, error) {
+	row := s.db.QueryRow(`SELECT `+habitGoalColumns+` FROM habit_goals WHERE habitId = ?`, habitId)
+	g, err := scanHabitGoal(row)
+	if err != nil {
+		return g, fmt.Errorf("error retrieving goal of habit %s: %w", habitId, translateError(err))
+	}
+	return g, nil
+}
+
+// SaveHabitGoal creates or replaces the goal of a habit.
+func (s *service) SaveHabitGoal(g models.HabitGoal) error {
+	g.SetDefaults()
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO habit_goals (`+habitGoalColumns+`) VALUES (?, ?, ?, ?)
+		ON CONFLICT (habitId) DO UPDATE SET target = excluded.target, period = excluded.period, unit = excluded.unit`,
+		g.HabitId, g.Target, g.Period, g.Unit,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving goal of habit %s: %w", g.HabitId, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteHabitGoal(habitId string) error {
+	res, err := s.db.Exec(`DELETE FROM habit_goals WHERE habitId = ?`, habitId)
+	if err != nil {
+		return fmt.Errorf("error deleting goal of habit %s: %w", habitId, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error deleting goal of habit %s: %w", habitId, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+)
+
+const jobColumns = `name, schedule, lastRunAt, lastSuccessAt, lastDurationMs, lastError, nextRunAt`
+
+func scanJobState(row interface{ Scan(...any) error }) (models.JobState, error) {
+	var j models.JobState
+	err := row.Scan(&j.Name, &j.Schedule, &j.LastRunAt, &j.LastSuccessAt, &j.LastDurationMs, &j.LastError, &j.NextRunAt)
+	return j, err
+}
+
+func (s *service) ListJobStates() ([]models.JobState, error) {
+	jobs := make([]models.JobState, 0)
+	rows, err := s.db.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY name`)
+	if err != nil {
+		return jobs, fmt.Errorf("error listing jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job, err := scanJobState(rows)
+		if err != nil {
+			return jobs, fmt.Errorf("error scanning job row: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *service) GetJobState(name string) (models.JobState, error) {
+	row := s.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE name = ?`, name)
+	job, err := scanJobState(row)
+	if err != nil {
+		return job, fmt.Errorf("error retrieving job %s: %w", name, translateError(err))
+	}
+	return job, nil
+}
+
+// SaveJobState creates or replaces the stored state of a job.
+func (s *service) SaveJobState(job models.JobState) error {
+	_, err := s.db.Exec(
+		`INSERT INTO jobs (`+jobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET schedule = excluded.schedule, lastRunAt = excluded.lastRunAt,
+		lastSuccessAt = excluded.lastSuccessAt, lastDurationMs = excluded.lastDurationMs,
+		lastError = excluded.lastError, nextRunAt = excluded.nextRunAt`,
+		job.Name, job.Schedule, job.LastRunAt, job.LastSuccessAt, job.LastDurationMs, job.LastError, job.NextRunAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving job %s: %w", job.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS TodoistHabitProjectRule;
+DROP TABLE IF EXISTS TodoistHabitTextRule;
+DROP TABLE IF EXISTS HabiticaHabitRule;
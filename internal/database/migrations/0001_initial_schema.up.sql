@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS HabiticaHabitRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	habitId TEXT,
+	dailyId TEXT,
+	minScore INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS TodoistHabitTextRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	rule TEXT,
+	habitId TEXT
+);
+
+CREATE TABLE IF NOT EXISTS TodoistHabitProjectRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	todoistProjectId TEXT,
+	habitId TEXT
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS TodoistHabitProjectRule_todoistProjectId;
+DROP INDEX IF EXISTS TodoistHabitTextRule_rule;
+DROP INDEX IF EXISTS HabiticaHabitRule_habitId;
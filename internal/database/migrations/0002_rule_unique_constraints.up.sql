@@ -0,0 +1,29 @@
+-- keep the newest row for any rule that was inserted more than once by hand,
+-- copying the others to backup tables so they can be restored
+-- backup: HabiticaHabitRule_duplicates
+-- backup: TodoistHabitTextRule_duplicates
+-- backup: TodoistHabitProjectRule_duplicates
+CREATE TABLE IF NOT EXISTS HabiticaHabitRule_duplicates AS SELECT * FROM HabiticaHabitRule WHERE 0;
+INSERT INTO HabiticaHabitRule_duplicates
+SELECT * FROM HabiticaHabitRule
+WHERE id NOT IN (SELECT MAX(id) FROM HabiticaHabitRule GROUP BY habitId);
+DELETE FROM HabiticaHabitRule
+WHERE id NOT IN (SELECT MAX(id) FROM HabiticaHabitRule GROUP BY habitId);
+
+CREATE TABLE IF NOT EXISTS TodoistHabitTextRule_duplicates AS SELECT * FROM TodoistHabitTextRule WHERE 0;
+INSERT INTO TodoistHabitTextRule_duplicates
+SELECT * FROM TodoistHabitTextRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitTextRule GROUP BY rule);
+DELETE FROM TodoistHabitTextRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitTextRule GROUP BY rule);
+
+CREATE TABLE IF NOT EXISTS TodoistHabitProjectRule_duplicates AS SELECT * FROM TodoistHabitProjectRule WHERE 0;
+INSERT INTO TodoistHabitProjectRule_duplicates
+SELECT * FROM TodoistHabitProjectRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitProjectRule GROUP BY todoistProjectId);
+DELETE FROM TodoistHabitProjectRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitProjectRule GROUP BY todoistProjectId);
+
+CREATE UNIQUE INDEX HabiticaHabitRule_habitId ON HabiticaHabitRule (habitId);
+CREATE UNIQUE INDEX TodoistHabitTextRule_rule ON TodoistHabitTextRule (rule);
+CREATE UNIQUE INDEX TodoistHabitProjectRule_todoistProjectId ON TodoistHabitProjectRule (todoistProjectId);
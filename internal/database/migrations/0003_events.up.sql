@@ -0,0 +1,13 @@
+CREATE TABLE events (
+	id INTEGER PRIMARY KEY,
+	source TEXT NOT NULL,
+	eventType TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL,
+	receivedAt TIMESTAMP NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	processedAt TIMESTAMP
+);
+
+CREATE INDEX events_status_receivedAt ON events (status, receivedAt);
@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN deliveryKey;
+DROP TABLE IF EXISTS webhook_deliveries;
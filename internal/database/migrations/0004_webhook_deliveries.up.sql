@@ -0,0 +1,11 @@
+CREATE TABLE webhook_deliveries (
+	deliveryKey TEXT PRIMARY KEY,
+	source TEXT NOT NULL,
+	eventId INTEGER NOT NULL,
+	receivedAt TIMESTAMP NOT NULL,
+	expiresAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_expiresAt ON webhook_deliveries (expiresAt);
+
+ALTER TABLE events ADD COLUMN deliveryKey TEXT NOT NULL DEFAULT '';
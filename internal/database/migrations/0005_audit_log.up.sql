@@ -0,0 +1,11 @@
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY,
+	occurredAt TIMESTAMP NOT NULL,
+	action TEXT NOT NULL,
+	source TEXT NOT NULL DEFAULT '',
+	remoteAddr TEXT NOT NULL DEFAULT '',
+	path TEXT NOT NULL DEFAULT '',
+	reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX audit_log_occurredAt ON audit_log (occurredAt);
@@ -0,0 +1,9 @@
+CREATE TABLE todoist_completions (
+	id INTEGER PRIMARY KEY,
+	todoistTaskId TEXT NOT NULL,
+	habiticaTaskId TEXT NOT NULL,
+	scoredAt TIMESTAMP NOT NULL,
+	undoneAt TIMESTAMP
+);
+
+CREATE INDEX todoist_completions_todoistTaskId ON todoist_completions (todoistTaskId, scoredAt);
@@ -0,0 +1,20 @@
+-- rules that only differ in the dropped columns would collide on the text
+-- index; keep the newest and copy the others, whole, to a backup table
+-- backup: TodoistHabitTextRule_matching_duplicates
+DROP INDEX IF EXISTS TodoistHabitTextRule_match;
+
+CREATE TABLE IF NOT EXISTS TodoistHabitTextRule_matching_duplicates AS SELECT * FROM TodoistHabitTextRule WHERE 0;
+INSERT INTO TodoistHabitTextRule_matching_duplicates
+SELECT * FROM TodoistHabitTextRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitTextRule GROUP BY rule);
+DELETE FROM TodoistHabitTextRule
+WHERE id NOT IN (SELECT MAX(id) FROM TodoistHabitTextRule GROUP BY rule);
+
+ALTER TABLE TodoistHabitTextRule DROP COLUMN continueMatching;
+ALTER TABLE TodoistHabitTextRule DROP COLUMN priority;
+ALTER TABLE TodoistHabitTextRule DROP COLUMN operator;
+ALTER TABLE TodoistHabitTextRule DROP COLUMN sectionId;
+ALTER TABLE TodoistHabitTextRule DROP COLUMN labels;
+ALTER TABLE TodoistHabitTextRule DROP COLUMN matchType;
+
+CREATE UNIQUE INDEX TodoistHabitTextRule_rule ON TodoistHabitTextRule (rule);
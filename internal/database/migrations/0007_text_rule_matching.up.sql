@@ -0,0 +1,13 @@
+-- text rules can now match on labels and sections as well as text, and several
+-- rules may share the same text, so uniqueness covers the whole match
+DROP INDEX IF EXISTS TodoistHabitTextRule_rule;
+
+ALTER TABLE TodoistHabitTextRule ADD COLUMN matchType TEXT NOT NULL DEFAULT 'prefix';
+ALTER TABLE TodoistHabitTextRule ADD COLUMN labels TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE TodoistHabitTextRule ADD COLUMN sectionId TEXT NOT NULL DEFAULT '';
+ALTER TABLE TodoistHabitTextRule ADD COLUMN operator TEXT NOT NULL DEFAULT 'and';
+ALTER TABLE TodoistHabitTextRule ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE TodoistHabitTextRule ADD COLUMN continueMatching INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX TodoistHabitTextRule_match
+ON TodoistHabitTextRule (rule, matchType, labels, sectionId, operator, habitId);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS habit_rule_firings;
+
+ALTER TABLE HabiticaHabitRule DROP COLUMN counter;
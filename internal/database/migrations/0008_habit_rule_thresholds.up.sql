@@ -0,0 +1,11 @@
+-- which habit counter a min habit rule compares against its threshold: 'up'
+-- counts only positive scores, 'net' is up minus down
+ALTER TABLE HabiticaHabitRule ADD COLUMN counter TEXT NOT NULL DEFAULT 'up';
+
+-- a row per rule and day on which the rule has scored its daily
+CREATE TABLE habit_rule_firings (
+	ruleId INTEGER NOT NULL,
+	day TEXT NOT NULL,
+	firedAt TIMESTAMP NOT NULL,
+	PRIMARY KEY (ruleId, day)
+);
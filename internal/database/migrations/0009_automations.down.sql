@@ -0,0 +1,95 @@
+-- the rules are read back out of the automations they were stored as; other
+-- automations have nowhere to go and are copied, whole, to a backup table
+-- backup: automations_rolled_back
+CREATE TABLE HabiticaHabitRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	habitId TEXT,
+	dailyId TEXT,
+	minScore INTEGER,
+	counter TEXT NOT NULL DEFAULT 'up'
+);
+
+INSERT INTO HabiticaHabitRule (id, name, habitId, dailyId, minScore, counter)
+SELECT
+	id, name, triggerSubject,
+	json_extract(actions, '$[0].task_id'),
+	CAST(json_extract(conditions, '$[0].value') AS INTEGER),
+	substr(json_extract(conditions, '$[0].field'), 6)
+FROM automations
+WHERE source = 'habit';
+
+CREATE UNIQUE INDEX HabiticaHabitRule_habitId ON HabiticaHabitRule (habitId);
+
+CREATE TABLE TodoistHabitTextRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	rule TEXT,
+	habitId TEXT,
+	matchType TEXT NOT NULL DEFAULT 'prefix',
+	labels TEXT NOT NULL DEFAULT '[]',
+	sectionId TEXT NOT NULL DEFAULT '',
+	operator TEXT NOT NULL DEFAULT 'and',
+	priority INTEGER NOT NULL DEFAULT 0,
+	continueMatching INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO TodoistHabitTextRule
+	(id, name, rule, habitId, matchType, labels, sectionId, operator, priority, continueMatching)
+SELECT
+	a.id, a.name,
+	COALESCE((
+		SELECT json_extract(c.value, '$.value') FROM json_each(a.conditions) AS c
+		WHERE json_extract(c.value, '$.field') = 'item.content'
+	), ''),
+	json_extract(a.actions, '$[0].task_id'),
+	COALESCE((
+		SELECT json_extract(c.value, '$.op') FROM json_each(a.conditions) AS c
+		WHERE json_extract(c.value, '$.field') = 'item.content'
+	), 'prefix'),
+	(
+		SELECT json_group_array(json_extract(c.value, '$.value') ORDER BY c.key) FROM json_each(a.conditions) AS c
+		WHERE json_extract(c.value, '$.field') = 'item.labels'
+	),
+	COALESCE((
+		SELECT json_extract(c.value, '$.value') FROM json_each(a.conditions) AS c
+		WHERE json_extract(c.value, '$.field') = 'item.section_id'
+	), ''),
+	CASE a.matchMode WHEN 'any' THEN 'or' ELSE 'and' END,
+	a.priority, NOT a.stop
+FROM automations AS a
+WHERE a.source = 'text';
+
+CREATE UNIQUE INDEX TodoistHabitTextRule_match
+ON TodoistHabitTextRule (rule, matchType, labels, sectionId, operator, habitId);
+
+CREATE TABLE TodoistHabitProjectRule (
+	id INTEGER PRIMARY KEY,
+	name TEXT,
+	todoistProjectId TEXT,
+	habitId TEXT
+);
+
+INSERT INTO TodoistHabitProjectRule (id, name, todoistProjectId, habitId)
+SELECT id, name, json_extract(conditions, '$[0].value'), json_extract(actions, '$[0].task_id')
+FROM automations
+WHERE source = 'project';
+
+CREATE UNIQUE INDEX TodoistHabitProjectRule_todoistProjectId ON TodoistHabitProjectRule (todoistProjectId);
+
+CREATE TABLE habit_rule_firings (
+	ruleId INTEGER NOT NULL,
+	day TEXT NOT NULL,
+	firedAt TIMESTAMP NOT NULL,
+	PRIMARY KEY (ruleId, day)
+);
+
+INSERT INTO habit_rule_firings (ruleId, day, firedAt)
+SELECT CAST(substr(automationKey, 7) AS INTEGER), day, firedAt FROM automation_firings
+WHERE automationKey LIKE 'habit:%';
+
+CREATE TABLE IF NOT EXISTS automations_rolled_back AS SELECT * FROM automations WHERE 0;
+INSERT INTO automations_rolled_back SELECT * FROM automations WHERE source = 'automation';
+
+DROP TABLE automation_firings;
+DROP TABLE automations;
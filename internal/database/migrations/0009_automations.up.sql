@@ -0,0 +1,94 @@
+-- source is the kind of rule an automation was created as: 'automation' for
+-- ones managed at /api/automations, or 'habit', 'text' or 'project' for the
+-- rules managed at /api/rules
+CREATE TABLE automations (
+	id INTEGER PRIMARY KEY,
+	source TEXT NOT NULL DEFAULT 'automation',
+	name TEXT NOT NULL DEFAULT '',
+	enabled INTEGER NOT NULL DEFAULT 1,
+	triggerKind TEXT NOT NULL,
+	triggerEvent TEXT NOT NULL DEFAULT '',
+	triggerSubject TEXT NOT NULL DEFAULT '',
+	matchMode TEXT NOT NULL DEFAULT 'all',
+	conditions TEXT NOT NULL DEFAULT '[]',
+	actions TEXT NOT NULL DEFAULT '[]',
+	groupName TEXT NOT NULL DEFAULT '',
+	priority INTEGER NOT NULL DEFAULT 0,
+	stop INTEGER NOT NULL DEFAULT 0,
+	fallback INTEGER NOT NULL DEFAULT 0,
+	once TEXT NOT NULL DEFAULT ''
+);
+
+-- the rules move into automations, written as the engine runs them. Min habit
+-- rules keep their ids so their firings carry over; text and project rules are
+-- numbered after them.
+INSERT INTO automations (id, source, name, triggerKind, triggerSubject, conditions, actions, once)
+SELECT
+	id, 'habit', COALESCE(name, ''), 'habitica', habitId,
+	json_array(json_object('field', 'task.' || counter, 'op', 'gte', 'value', CAST(COALESCE(minScore, 0) AS TEXT))),
+	json_array(json_object('kind', 'habitica.score', 'task_id', dailyId)),
+	'day'
+FROM HabiticaHabitRule;
+
+CREATE TEMP TABLE text_rule_conditions AS
+SELECT r.id AS ruleId, (
+	SELECT json_group_array(json(c.condition) ORDER BY c.position)
+	FROM (
+		SELECT -1 AS position, json_object('field', 'item.content', 'op', r.matchType, 'value', r.rule) AS condition
+		WHERE COALESCE(r.rule, '') <> ''
+		UNION ALL
+		SELECT l.key, json_object('field', 'item.labels', 'op', 'has', 'value', l.value)
+		FROM json_each(r.labels) AS l
+		UNION ALL
+		SELECT json_array_length(r.labels), json_object('field', 'item.section_id', 'op', 'eq', 'value', r.sectionId)
+		WHERE r.sectionId <> ''
+	) AS c
+) AS conditions
+FROM TodoistHabitTextRule AS r;
+
+INSERT INTO automations
+	(id, source, name, enabled, triggerKind, triggerEvent, matchMode, conditions, actions, groupName, priority, stop)
+SELECT
+	r.id + (SELECT COALESCE(MAX(id), 0) FROM HabiticaHabitRule), 'text', COALESCE(r.name, ''),
+	c.conditions <> '[]', 'todoist', 'item:completed',
+	CASE r.operator WHEN 'or' THEN 'any' ELSE 'all' END,
+	c.conditions,
+	json_array(json_object('kind', 'habitica.score', 'task_id', r.habitId)),
+	'todoist-habitica', r.priority, NOT r.continueMatching
+FROM TodoistHabitTextRule AS r
+JOIN text_rule_conditions AS c ON c.ruleId = r.id;
+
+DROP TABLE text_rule_conditions;
+
+INSERT INTO automations
+	(id, source, name, triggerKind, triggerEvent, conditions, actions, groupName, stop, fallback)
+SELECT
+	id + (SELECT COALESCE(MAX(id), 0) FROM HabiticaHabitRule) + (SELECT COALESCE(MAX(id), 0) FROM TodoistHabitTextRule),
+	'project', COALESCE(name, ''), 'todoist', 'item:completed',
+	json_array(json_object('field', 'item.project_id', 'op', 'eq', 'value', todoistProjectId)),
+	json_array(json_object('kind', 'habitica.score', 'task_id', habitId)),
+	'todoist-habitica', 1, 1
+FROM TodoistHabitProjectRule;
+
+-- the uniqueness the rule tables had: one min habit rule per habit, one text
+-- rule per match and habit, one project rule per project
+CREATE UNIQUE INDEX automations_habit_rule ON automations (triggerSubject) WHERE source = 'habit';
+CREATE UNIQUE INDEX automations_text_rule ON automations (matchMode, conditions, actions) WHERE source = 'text';
+CREATE UNIQUE INDEX automations_project_rule ON automations (conditions) WHERE source = 'project';
+
+DROP TABLE HabiticaHabitRule;
+DROP TABLE TodoistHabitTextRule;
+DROP TABLE TodoistHabitProjectRule;
+
+-- firings are now kept for any automation, keyed by source and id, e.g. habit:3
+CREATE TABLE automation_firings (
+	automationKey TEXT NOT NULL,
+	day TEXT NOT NULL,
+	firedAt TIMESTAMP NOT NULL,
+	PRIMARY KEY (automationKey, day)
+);
+
+INSERT INTO automation_firings (automationKey, day, firedAt)
+SELECT 'habit:' || ruleId, day, firedAt FROM habit_rule_firings;
+
+DROP TABLE habit_rule_firings;
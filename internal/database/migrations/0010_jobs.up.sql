@@ -0,0 +1,11 @@
+-- state of each scheduled job, kept so missed runs can be caught up after a
+-- restart and so /api/jobs can show when jobs last and next run
+CREATE TABLE jobs (
+	name TEXT PRIMARY KEY,
+	schedule TEXT NOT NULL,
+	lastRunAt TIMESTAMP,
+	lastSuccessAt TIMESTAMP,
+	lastDurationMs INTEGER NOT NULL DEFAULT 0,
+	lastError TEXT NOT NULL DEFAULT '',
+	nextRunAt TIMESTAMP
+);
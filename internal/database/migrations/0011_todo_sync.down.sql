@@ -0,0 +1,2 @@
+DROP TABLE todo_links;
+DROP TABLE todo_sync_projects;
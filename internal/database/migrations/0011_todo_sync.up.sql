@@ -0,0 +1,20 @@
+-- Todoist projects whose tasks are mirrored as Habitica todos
+CREATE TABLE todo_sync_projects (
+	todoistProjectId TEXT PRIMARY KEY,
+	addedAt TIMESTAMP NOT NULL
+);
+
+-- a Todoist task and the Habitica todo mirroring it, with the last state
+-- written to both and when, for last-write-wins, and the writes whose
+-- webhooks are still to come back, as JSON
+CREATE TABLE todo_links (
+	id INTEGER PRIMARY KEY,
+	todoistTaskId TEXT NOT NULL UNIQUE,
+	habiticaTaskId TEXT NOT NULL UNIQUE,
+	todoistProjectId TEXT NOT NULL,
+	content TEXT NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT FALSE,
+	updatedAt TIMESTAMP NOT NULL,
+	updatedBy TEXT NOT NULL,
+	pending TEXT NOT NULL DEFAULT '[]'
+);
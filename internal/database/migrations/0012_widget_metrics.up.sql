@@ -0,0 +1,23 @@
+-- the metrics shown on a widget, in position order. A metric reads value from
+-- what selector picks out of source, and its goal from goalSource
+CREATE TABLE widget_metrics (
+	id INTEGER PRIMARY KEY,
+	widget TEXT NOT NULL DEFAULT 'default',
+	name TEXT NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL,
+	selector TEXT NOT NULL DEFAULT '',
+	value TEXT NOT NULL,
+	goalSource TEXT NOT NULL DEFAULT 'none',
+	goal INTEGER NOT NULL DEFAULT 0,
+	position INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (widget, name)
+);
+
+-- what the widget showed before metrics were configurable
+INSERT INTO widget_metrics (name, label, source, selector, value, goalSource, position) VALUES
+	('water', 'Water', 'habitica.habit', 'Water', 'net', 'habit_notes', 1),
+	('reading', 'Reading', 'habitica.habit', 'Reading', 'up', 'habit_notes', 2),
+	('dailies', 'Dailies', 'habitica.dailies', '', 'done', 'dailies_due', 3),
+	('todoist_done', 'Tasks done', 'todoist.stats', '', 'completed_today', 'todoist_daily_goal', 4),
+	('todoist_due', 'Tasks due', 'todoist.tasks', 'today | od', 'count', 'none', 5);
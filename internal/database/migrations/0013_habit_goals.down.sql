@@ -0,0 +1,3 @@
+UPDATE widget_metrics SET goalSource = 'habit_notes' WHERE goalSource = 'habit_goal';
+
+DROP TABLE habit_goals;
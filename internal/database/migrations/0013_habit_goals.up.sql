@@ -0,0 +1,10 @@
+-- the target for each Habitica habit's counter, replacing "Goal: N" notes
+CREATE TABLE habit_goals (
+	habitId TEXT PRIMARY KEY,
+	target INTEGER NOT NULL,
+	period TEXT NOT NULL DEFAULT 'daily',
+	unit TEXT NOT NULL DEFAULT ''
+);
+
+-- widget metrics read goals from habit_goals instead of notes
+UPDATE widget_metrics SET goalSource = 'habit_goal' WHERE goalSource = 'habit_notes';
@@ -0,0 +1,13 @@
+-- one value per day for each tracked metric, recorded by the snapshot job or
+-- backfilled from the APIs' history
+CREATE TABLE snapshots (
+	date TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	subject TEXT NOT NULL DEFAULT '',
+	value REAL NOT NULL,
+	goal REAL,
+	recordedAt TIMESTAMP NOT NULL,
+	PRIMARY KEY (date, metric, subject)
+);
+
+CREATE INDEX snapshots_metric ON snapshots (metric, subject, date);
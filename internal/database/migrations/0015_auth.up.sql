@@ -0,0 +1,16 @@
+-- API tokens and web UI sessions, kept as SHA-256 hashes of the secrets
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	tokenHash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	createdAt TIMESTAMP NOT NULL,
+	lastUsedAt TIMESTAMP
+);
+
+CREATE TABLE sessions (
+	sessionHash TEXT PRIMARY KEY,
+	scopes TEXT NOT NULL,
+	createdAt TIMESTAMP NOT NULL,
+	expiresAt TIMESTAMP NOT NULL
+);
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"misc/internal/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when a rule with the requested id does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a write would violate a unique constraint.
+	ErrDuplicate = errors.New("duplicate")
+)
+
+// translateError maps driver errors onto the package's sentinel errors.
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// checkAffected returns ErrNotFound when an update or delete matched no rows.
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// The min habit, text and project rules are stored as automations, in the
+// shape the engine runs them; the functions below read and write them as
+// their rule types.
+
+func (s *service) ListHabitRules() ([]models.HabiticaHabitRule, error) {
+	rules := make([]models.HabiticaHabitRule, 0)
+	automations, err := s.queryAutomations(
+		`SELECT `+automationColumns+` FROM automations WHERE source = ? ORDER BY id`,
+		models.AutomationSourceHabit,
+	)
+	if err != nil {
+		return rules, fmt.Errorf("error listing habit rules: %w", err)
+	}
+	for _, a := range automations {
+		rules = append(rules, a.HabitRule())
+	}
+	return rules, nil
+}
+
+func (s *service) GetHabitRuleById(id int64) (models.HabiticaHabitRule, error) {
+	a, err := s.getAutomation(models.AutomationSourceHabit, id)
+	if err != nil {
+		return models.HabiticaHabitRule{}, fmt.Errorf("error retrieving habit rule %d: %w", id, err)
+	}
+	return a.HabitRule(), nil
+}
+
+func (s *service) CreateHabitRule(rule models.HabiticaHabitRule) (models.HabiticaHabitRule, error) {
+	rule.SetDefaults()
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+	a, err := s.CreateAutomation(rule.Automation())
+	if err != nil {
+		return rule, fmt.Errorf("error creating habit rule: %w", err)
+	}
+	rule.Id = a.Id
+	return rule, nil
+}
+
+func (s *service) UpdateHabitRule(rule models.HabiticaHabitRule) error {
+	rule.SetDefaults()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := s.UpdateAutomation(rule.Automation()); err != nil {
+		return fmt.Errorf("error updating habit rule %d: %w", rule.Id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteHabitRule(id int64) error {
+	return s.deleteAutomation(models.AutomationSourceHabit, id)
+}
+
+func (s *service) GetTodoistHabiticaTextRuleById(id int64) (models.TodoistHabiticaTextRule, error) {
+	a, err := s.getAutomation(models.AutomationSourceText, id)
+	if err != nil {
+		return models.TodoistHabiticaTextRule{}, fmt.Errorf("error retrieving text rule %d: %w", id, err)
+	}
+	return a.TextRule(), nil
+}
+
+func (s *service) CreateTodoistHabiticaTextRule(rule models.TodoistHabiticaTextRule) (models.TodoistHabiticaTextRule, error) {
+	rule.SetDefaults()
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+	a, err := s.CreateAutomation(rule.Automation())
+	if err != nil {
+		return rule, fmt.Errorf("error creating text rule: %w", err)
+	}
+	rule.Id = a.Id
+	return rule, nil
+}
+
+func (s *service) UpdateTodoistHabiticaTextRule(rule models.TodoistHabiticaTextRule) error {
+	rule.SetDefaults()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := s.UpdateAutomation(rule.Automation()); err != nil {
+		return fmt.Errorf("error updating text rule %d: %w", rule.Id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteTodoistHabiticaTextRule(id int64) error {
+	return s.deleteAutomation(models.AutomationSourceText, id)
+}
+
+func (s *service) ListTodoistHabiticaProjectRules() ([]models.TodoistHabiticaProjectRule, error) {
+	rules := make([]models.TodoistHabiticaProjectRule, 0)
+	automations, err := s.queryAutomations(
+		`SELECT `+automationColumns+` FROM automations WHERE source = ? ORDER BY id`,
+		models.AutomationSourceProject,
+	)
+	if err != nil {
+		return rules, fmt.Errorf("error listing project rules: %w", err)
+	}
+	for _, a := range automations {
+		rules = append(rules, a.ProjectRule())
+	}
+	return rules, nil
+}
+
+func (s *service) GetTodoistHabiticaProjectRuleById(id int64) (models.TodoistHabiticaProjectRule, error) {
+	a, err := s.getAutomation(models.AutomationSourceProject, id)
+	if err != nil {
+		return models.TodoistHabiticaProjectRule{}, fmt.Errorf("error retrieving project rule %d: %w", id, err)
+	}
+	return a.ProjectRule(), nil
+}
+
+func (s *service) CreateTodoistHabiticaProjectRule(rule models.TodoistHabiticaProjectRule) (models.TodoistHabiticaProjectRule, error) {
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+	a, err := s.CreateAutomation(rule.Automation())
+	if err != nil {
+		return rule, fmt.Errorf("error creating project rule: %w", err)
+	}
+	rule.Id = a.Id
+	return rule, nil
+}
+
+func (s *service) UpdateTodoistHabiticaProjectRule(rule models.TodoistHabiticaProjectRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := s.UpdateAutomation(rule.Automation()); err != nil {
+		return fmt.Errorf("error updating project rule %d: %w", rule.Id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteTodoistHabiticaProjectRule(id int64) error {
+	return s.deleteAutomation(models.AutomationSourceProject, id)
+}
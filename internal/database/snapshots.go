@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+	"strings"
+)
+
+const snapshotColumns = `date, metric, subject, value, goal, recordedAt`
+
+// SaveSnapshots records snapshots, replacing any already recorded for the
+// same day, metric and subject.
+func (s *service) SaveSnapshots(snapshots []models.Snapshot) error {
+	_, err := s.insertSnapshots(snapshots, `ON CONFLICT (date, metric, subject) DO UPDATE SET
+		value = excluded.value, goal = excluded.goal, recordedAt = excluded.recordedAt`)
+	return err
+}
+
+// FillSnapshots records snapshots for the days, metrics and subjects that have
+// none yet, and returns how many it recorded.
+func (s *service) FillSnapshots(snapshots []models.Snapshot) (int, error) {
+	return s.insertSnapshots(snapshots, `ON CONFLICT (date, metric, subject) DO NOTHING`)
+}
+
+// insertSnapshots inserts snapshots with the given conflict clause in one
+// transaction and returns how many rows changed.
+func (s *service) insertSnapshots(snapshots []models.Snapshot, conflict string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error saving snapshots: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO snapshots (` + snapshotColumns + `) VALUES (?, ?, ?, ?, ?, ?) ` + conflict)
+	if err != nil {
+		return 0, fmt.Errorf("error saving snapshots: %w", err)
+	}
+	defer stmt.Close()
+
+	var count int64
+	for _, snap := range snapshots {
+		res, err := stmt.Exec(snap.Date, snap.Metric, snap.Subject, snap.Value, snap.Goal, snap.RecordedAt.UTC())
+		if err != nil {
+			return 0, fmt.Errorf("error saving snapshot %s %s %s: %w", snap.Date, snap.Metric, snap.Subject, err)
+		}
+		n, _ := res.RowsAffected()
+		count += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error saving snapshots: %w", err)
+	}
+	return int(count), nil
+}
+
+// ListSnapshots returns the snapshots matching filter, oldest day first.
+func (s *service) ListSnapshots(filter models.SnapshotFilter) ([]models.Snapshot, error) {
+	var where []string
+	var args []any
+	if filter.Metric != "" {
+		where = append(where, "metric = ?")
+		args = append(args, filter.Metric)
+	}
+	if filter.Subject != "" {
+		where = append(where, "subject = ?")
+		args = append(args, filter.Subject)
+	}
+	if filter.From != "" {
+		where = append(where, "date >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where = append(where, "date <= ?")
+		args = append(args, filter.To)
+	}
+	query := `SELECT ` + snapshotColumns + ` FROM snapshots`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY date, metric, subject`
+
+	snapshots := make([]models.Snapshot, 0)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return snapshots, fmt.Errorf("error listing snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snap models.Snapshot
+		if err := rows.Scan(&snap.Date, &snap.Metric, &snap.Subject, &snap.Value, &snap.Goal, &snap.RecordedAt); err != nil {
+			return snapshots, fmt.Errorf("error scanning snapshot row: %w", err)
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"misc/internal/models"
+	"time"
+)
+
+func (s *service) ListTodoSyncProjects() ([]models.TodoSyncProject, error) {
+	projects := make([]models.TodoSyncProject, 0)
+	rows, err := s.db.Query(`SELECT todoistProjectId, addedAt FROM todo_sync_projects ORDER BY addedAt`)
+	if err != nil {
+		return projects, fmt.Errorf("error listing sync projects: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.TodoSyncProject
+		if err := rows.Scan(&p.TodoistProjectId, &p.AddedAt); err != nil {
+			return projects, fmt.Errorf("error scanning sync project row: %w", err)
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (s *service) IsTodoSyncProject(projectId string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM todo_sync_projects WHERE todoistProjectId = ?`, projectId).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("error checking sync project %s: %w", projectId, err)
+	}
+	return n > 0, nil
+}
+
+// AddTodoSyncProject selects a project for syncing. Adding a project that is
+// already selected does nothing.
+func (s *service) AddTodoSyncProject(projectId string) (models.TodoSyncProject, error) {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO todo_sync_projects (todoistProjectId, addedAt) VALUES (?, ?)`,
+		projectId, time.Now().UTC(),
+	)
+	if err != nil {
+		return models.TodoSyncProject{}, fmt.Errorf("error adding sync project %s: %w", projectId, err)
+	}
+	p := models.TodoSyncProject{TodoistProjectId: projectId}
+	err = s.db.QueryRow(`SELECT addedAt FROM todo_sync_projects WHERE todoistProjectId = ?`, projectId).Scan(&p.AddedAt)
+	if err != nil {
+		return p, fmt.Errorf("error retrieving sync project %s: %w", projectId, err)
+	}
+	return p, nil
+}
+
+// RemoveTodoSyncProject stops mirroring new tasks of the project. Tasks that
+// are already linked stay in sync.
+func (s *service) RemoveTodoSyncProject(projectId string) error {
+	res, err := s.db.Exec(`DELETE FROM todo_sync_projects WHERE todoistProjectId = ?`, projectId)
+	if err != nil {
+		return fmt.Errorf("error removing sync project %s: %w", projectId, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error removing sync project %s: %w", projectId, err)
+	}
+	return nil
+}
+
+const todoLinkColumns = `id, todoistTaskId, habiticaTaskId, todoistProjectId, content, completed, updatedAt, updatedBy, pending`
+
+func scanTodoLink(row interface{ Scan(...any) error }) (models.TodoLink, error) {
+	var l models.TodoLink
+	var pending string
+	err := row.Scan(&l.Id, &l.TodoistTaskId, &l.HabiticaTaskId, &l.TodoistProjectId, &l.Content, &l.Completed, &l.UpdatedAt, &l.UpdatedBy, &pending)
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal([]byte(pending), &l.Pending); err != nil {
+		return l, fmt.Errorf("error decoding pending writes of todo link %d: %w", l.Id, err)
+	}
+	return l, nil
+}
+
+func encodePending(pending []models.TodoEcho) (string, error) {
+	if pending == nil {
+		pending = []models.TodoEcho{}
+	}
+	b, err := json.Marshal(pending)
+	return string(b), err
+}
+
+func (s *service) ListTodoLinks() ([]models.TodoLink, error) {
+	links := make([]models.TodoLink, 0)
+	rows, err := s.db.Query(`SELECT ` + todoLinkColumns + ` FROM todo_links ORDER BY id`)
+	if err != nil {
+		return links, fmt.Errorf("error listing todo links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		link, err := scanTodoLink(rows)
+		if err != nil {
+			return links, fmt.Errorf("error scanning todo link row: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// FindTodoLinkByTodoist returns the link for a Todoist task, or nil if the
+// task isn't linked.
+func (s *service) FindTodoLinkByTodoist(taskId string) (*models.TodoLink, error) {
+	return s.findTodoLink(`todoistTaskId`, taskId)
+}
+
+// FindTodoLinkByHabitica returns the link for a Habitica todo, or nil if the
+// todo isn't linked.
+func (s *service) FindTodoLinkByHabitica(taskId string) (*models.TodoLink, error) {
+	return s.findTodoLink(`habiticaTaskId`, taskId)
+}
+
+func (s *service) findTodoLink(column, taskId string) (*models.TodoLink, error) {
+	row := s.db.QueryRow(`SELECT `+todoLinkColumns+` FROM todo_links WHERE `+column+` = ?`, taskId)
+	link, err := scanTodoLink(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving todo link for %s: %w", taskId, err)
+	}
+	return &link, nil
+}
+
+func (s *service) CreateTodoLink(link models.TodoLink) (models.TodoLink, error) {
+	pending, err := encodePending(link.Pending)
+	if err != nil {
+		return link, fmt.Errorf("error encoding pending writes: %w", err)
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO todo_links (todoistTaskId, habiticaTaskId, todoistProjectId, content, completed, updatedAt, updatedBy, pending)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.TodoistTaskId, link.HabiticaTaskId, link.TodoistProjectId, link.Content, link.Completed, link.UpdatedAt.UTC(), link.UpdatedBy, pending,
+	)
+	if err != nil {
+		return link, fmt.Errorf("error creating todo link: %w", translateError(err))
+	}
+	link.Id, err = res.LastInsertId()
+	if err != nil {
+		return link, fmt.Errorf("error reading todo link id: %w", err)
+	}
+	return link, nil
+}
+
+func (s *service) UpdateTodoLink(link models.TodoLink) error {
+	pending, err := encodePending(link.Pending)
+	if err != nil {
+		return fmt.Errorf("error encoding pending writes of todo link %d: %w", link.Id, err)
+	}
+	res, err := s.db.Exec(
+		`UPDATE todo_links SET todoistProjectId = ?, content = ?, completed = ?, updatedAt = ?, updatedBy = ?, pending = ?
+		WHERE id = ?`,
+		link.TodoistProjectId, link.Content, link.Completed, link.UpdatedAt.UTC(), link.UpdatedBy, pending, link.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating todo link %d: %w", link.Id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error updating todo link %d: %w", link.Id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteTodoLink(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM todo_links WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting todo link %d: %w", id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error deleting todo link %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"misc/internal/models"
+)
+
+const widgetMetricColumns = `id, widget, name, label, source, selector, value, goalSource, goal, position`
+
+func scanWidgetMetric(row interface{ Scan(...any) error }) (models.WidgetMetric, error) {
+	var m models.WidgetMetric
+	err := row.Scan(&m.Id, &m.Widget, &m.Name, &m.Label, &m.Source, &m.Selector, &m.Value, &m.GoalSource, &m.Goal, &m.Position)
+	return m, err
+}
+
+// ListWidgetMetrics returns the metrics of a widget in position order, or of
+// every widget when widget is empty.
+func (s *service) ListWidgetMetrics(widget string) ([]models.WidgetMetric, error) {
+	metrics := make([]models.WidgetMetric, 0)
+	query := `SELECT ` + widgetMetricColumns + ` FROM widget_metrics`
+	var args []any
+	if widget != "" {
+		query += ` WHERE widget = ?`
+		args = append(args, widget)
+	}
+	query += ` ORDER BY widget, position, id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return metrics, fmt.Errorf("error listing widget metrics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanWidgetMetric(rows)
+		if err != nil {
+			return metrics, fmt.Errorf("error scanning widget metric row: %w", err)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *service) GetWidgetMetric(id int64) (models.WidgetMetric, error) {
+	row := s.db.QueryRow(`SELECT `+widgetMetricColumns+` FROM widget_metrics WHERE id = ?`, id)
+	m, err := scanWidgetMetric(row)
+	if err != nil {
+		return m, fmt.Errorf("error retrieving widget metric %d: %w", id, translateError(err))
+	}
+	return m, nil
+}
+
+func (s *service) CreateWidgetMetric(m models.WidgetMetric) (models.WidgetMetric, error) {
+	m.SetDefaults()
+	if err := m.Validate(); err != nil {
+		return m, err
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO widget_metrics (widget, name, label, source, selector, value, goalSource, goal, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Widget, m.Name, m.Label, m.Source, m.Selector, m.Value, m.GoalSource, m.Goal, m.Position,
+	)
+	if err != nil {
+		return m, fmt.Errorf("error creating widget metric: %w", translateError(err))
+	}
+	m.Id, err = res.LastInsertId()
+	if err != nil {
+		return m, fmt.Errorf("error reading widget metric id: %w", err)
+	}
+	return m, nil
+}
+
+func (s *service) UpdateWidgetMetric(m models.WidgetMetric) error {
+	m.SetDefaults()
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	res, err := s.db.Exec(
+		`UPDATE widget_metrics SET widget = ?, name = ?, label = ?, source = ?, selector = ?, value = ?,
+		goalSource = ?, goal = ?, position = ? WHERE id = ?`,
+		m.Widget, m.Name, m.Label, m.Source, m.Selector, m.Value, m.GoalSource, m.Goal, m.Position, m.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating widget metric %d: %w", m.Id, translateError(err))
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error updating widget metric %d: %w", m.Id, err)
+	}
+	return nil
+}
+
+func (s *service) DeleteWidgetMetric(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM widget_metrics WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting widget metric %d: %w", id, err)
+	}
+	if err := checkAffected(res); err != nil {
+		return fmt.Errorf("error deleting widget metric %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Activity kinds: what the automations are doing, as streamed live.
+const (
+	ActivityWebhook = "webhook"
+	ActivityRule    = "rule"
+	ActivityCall    = "call"
+)
+
+// Outcomes of an ActivityCall. Webhooks have their event status as outcome
+// and rules their automation run outcome.
+const (
+	ActivityOK    = "ok"
+	ActivityError = "error"
+)
+
+// Activity is one thing that happened in the automations: a webhook
+// processed, a rule that matched, or a call made to Habitica or Todoist.
+//
+// Source is where a webhook or rule's event came from, or the API called.
+// Name is the webhook's event type, the rule's name or the call, e.g.
+// habitica.score. Subject is the journal event id, the rule's key or the task
+// called on.
+type Activity struct {
+	Id         int64     `json:"id"`
+	Kind       string    `json:"kind"`
+	Source     string    `json:"source"`
+	Name       string    `json:"name"`
+	Subject    string    `json:"subject,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// ActivityFilter narrows a stream of activity. Zero values match everything.
+type ActivityFilter struct {
+	Kind   string
+	Source string
+}
+
+func (f ActivityFilter) Matches(a Activity) bool {
+	return (f.Kind == "" || f.Kind == a.Kind) && (f.Source == "" || f.Source == a.Source)
+}
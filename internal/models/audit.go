@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+const AuditWebhookRejected = "webhook_rejected"
+
+// AuditEntry is a security relevant event, such as a rejected webhook.
+type AuditEntry struct {
+	Id         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Action     string    `json:"action"`
+	Source     string    `json:"source,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scopes. Widget reads the widget, dashboard, charts and stats; admin can do
+// everything, including managing rules.
+const (
+	ScopeWidget = "widget"
+	ScopeAdmin  = "admin"
+)
+
+const (
+	AuditAuthRejected = "auth_rejected"
+	AuditLoginFailed  = "login_failed"
+)
+
+// APIToken is a token for the phone widget or a script. Only a hash of the
+// token itself is stored; it is shown once, when created.
+type APIToken struct {
+	Id         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (t APIToken) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(t.Scopes) == 0 {
+		return ValidationError{Field: "scopes", Message: "at least one scope is required"}
+	}
+	for _, scope := range t.Scopes {
+		if scope != ScopeWidget && scope != ScopeAdmin {
+			return ValidationError{Field: "scopes", Message: "must be widget or admin"}
+		}
+	}
+	return nil
+}
+
+// Session is a web UI login.
+type Session struct {
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasScope reports whether scopes grant scope. Admin grants every scope.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAdmin)
+}
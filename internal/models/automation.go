@@ -0,0 +1,354 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Trigger kinds: the sources of events automations can react to.
+const (
+	TriggerTodoist  = "todoist"
+	TriggerHabitica = "habitica"
+	TriggerFitbit   = "fitbit"
+	TriggerSchedule = "schedule"
+)
+
+// Condition operators. Prefix and contains ignore case, has checks a list
+// fact such as item.labels, and the comparisons are numeric.
+const (
+	OpEquals    = "eq"
+	OpNotEquals = "ne"
+	OpPrefix    = "prefix"
+	OpContains  = "contains"
+	OpRegex     = "regex"
+	OpHas       = "has"
+	OpGte       = "gte"
+	OpGt        = "gt"
+	OpLte       = "lte"
+	OpLt        = "lt"
+)
+
+// Action kinds.
+const (
+	ActionScoreHabitica   = "habitica.score"
+	ActionUnscoreHabitica = "habitica.unscore"
+	ActionCreateTodoist   = "todoist.create"
+	ActionCloseTodoist    = "todoist.close"
+	ActionNotify          = "notify"
+)
+
+// How an automation's conditions are combined.
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// OnceDaily makes an automation fire at most once a day while its conditions
+// hold, and undo its Habitica scores if they stop holding that day.
+const OnceDaily = "day"
+
+// What an automation was created as. Every automation lives in the
+// automations table; the rule sources are edited through their rule types.
+const (
+	AutomationSourceStored  = "automation"
+	AutomationSourceHabit   = "habit"
+	AutomationSourceText    = "text"
+	AutomationSourceProject = "project"
+)
+
+// TodoistHabiticaGroup is the group the Todoist text and project rules share,
+// so that project rules only apply when no text rule matched.
+const TodoistHabiticaGroup = "todoist-habitica"
+
+// AutomationEvent is something that happened, described by facts that
+// conditions can test, e.g. item.content or task.up. Subject is the id of
+// what the event is about: a Todoist item, Habitica task or Fitbit metric.
+type AutomationEvent struct {
+	Kind    string
+	Name    string
+	Subject string
+	Facts   map[string]any
+}
+
+// Trigger selects the events an automation runs for. Event is a Todoist event
+// name, Habitica webhook type, Fitbit metric or schedule name, and Subject
+// limits it to one task; either matches anything when empty.
+type Trigger struct {
+	Kind    string `json:"kind"`
+	Event   string `json:"event,omitempty"`
+	Subject string `json:"subject,omitempty"`
+}
+
+type Condition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Action is something an automation does. String fields may refer to facts
+// of the triggering event as {field}, e.g. "{item.id}".
+type Action struct {
+	Kind      string `json:"kind"`
+	TaskId    string `json:"task_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	ProjectId string `json:"project_id,omitempty"`
+}
+
+// Automation runs its actions when a triggering event matches its conditions.
+// Automations are tried by descending priority. Within a group, one with Stop
+// set keeps lower priority automations from running, and Fallback ones only
+// run when nothing else in the group matched.
+type Automation struct {
+	Id         int64       `json:"id"`
+	Source     string      `json:"source"`
+	Name       string      `json:"name"`
+	Enabled    bool        `json:"enabled"`
+	Trigger    Trigger     `json:"trigger"`
+	Match      string      `json:"match"`
+	Conditions []Condition `json:"conditions"`
+	Actions    []Action    `json:"actions"`
+	Group      string      `json:"group,omitempty"`
+	Priority   int         `json:"priority"`
+	Stop       bool        `json:"stop"`
+	Fallback   bool        `json:"fallback"`
+	Once       string      `json:"once,omitempty"`
+}
+
+// AutomationRun reports what an automation did for one event.
+type AutomationRun struct {
+	Key     string       `json:"key"`
+	Name    string       `json:"name"`
+	Outcome string       `json:"outcome"`
+	Calls   []ActionCall `json:"calls"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// Outcomes of an AutomationRun.
+const (
+	AutomationFired        = "fired"
+	AutomationReverted     = "reverted"
+	AutomationAlreadyFired = "already fired today"
+)
+
+// ActionCall is an action as run, with the event's facts filled in.
+type ActionCall struct {
+	Kind      string `json:"kind"`
+	TaskId    string `json:"task_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	ProjectId string `json:"project_id,omitempty"`
+}
+
+// Key identifies the automation across sources, e.g. "habit:3".
+func (a Automation) Key() string {
+	return fmt.Sprintf("%s:%d", a.Source, a.Id)
+}
+
+// SetDefaults fills in the match mode and empty lists of a new automation.
+func (a *Automation) SetDefaults() {
+	if a.Source == "" {
+		a.Source = AutomationSourceStored
+	}
+	if a.Match == "" {
+		a.Match = MatchAll
+	}
+	if a.Conditions == nil {
+		a.Conditions = []Condition{}
+	}
+	if a.Actions == nil {
+		a.Actions = []Action{}
+	}
+}
+
+func (a Automation) Validate() error {
+	switch a.Trigger.Kind {
+	case TriggerTodoist, TriggerHabitica, TriggerFitbit, TriggerSchedule:
+	default:
+		return ValidationError{"trigger.kind", "must be todoist, habitica, fitbit or schedule"}
+	}
+	if a.Match != MatchAll && a.Match != MatchAny {
+		return ValidationError{"match", "must be all or any"}
+	}
+	if a.Once != "" && a.Once != OnceDaily {
+		return ValidationError{"once", "must be empty or day"}
+	}
+	for i, c := range a.Conditions {
+		if err := c.validate(); err != nil {
+			return ValidationError{fmt.Sprintf("conditions[%d]", i), err.Error()}
+		}
+	}
+	if len(a.Actions) == 0 {
+		return ValidationError{"actions", "at least one action is required"}
+	}
+	for i, action := range a.Actions {
+		if err := action.validate(); err != nil {
+			return ValidationError{fmt.Sprintf("actions[%d]", i), err.Error()}
+		}
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+	if c.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	switch c.Op {
+	case OpEquals, OpNotEquals, OpPrefix, OpContains, OpHas:
+	case OpRegex:
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return fmt.Errorf("value is not a valid regular expression: %w", err)
+		}
+	case OpGte, OpGt, OpLte, OpLt:
+		if _, err := strconv.ParseFloat(c.Value, 64); err != nil {
+			return fmt.Errorf("value must be a number for %s", c.Op)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+	return nil
+}
+
+func (a Action) validate() error {
+	switch a.Kind {
+	case ActionScoreHabitica, ActionUnscoreHabitica, ActionCloseTodoist:
+		if a.TaskId == "" {
+			return fmt.Errorf("task_id is required for %s", a.Kind)
+		}
+	case ActionCreateTodoist, ActionNotify:
+		if a.Content == "" {
+			return fmt.Errorf("content is required for %s", a.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", a.Kind)
+	}
+	return nil
+}
+
+// Automation expresses a min habit rule for the engine: score the daily once a
+// day when the habit's counter reaches the threshold.
+func (r HabiticaHabitRule) Automation() Automation {
+	r.SetDefaults()
+	return Automation{
+		Id:      r.Id,
+		Source:  AutomationSourceHabit,
+		Name:    r.Name,
+		Enabled: true,
+		Trigger: Trigger{Kind: TriggerHabitica, Subject: r.HabitId},
+		Match:   MatchAll,
+		Conditions: []Condition{
+			{Field: "task." + r.Counter, Op: OpGte, Value: strconv.Itoa(r.MinScore)},
+		},
+		Actions: []Action{{Kind: ActionScoreHabitica, TaskId: r.DailyId}},
+		Once:    OnceDaily,
+	}
+}
+
+// Automation expresses a text rule for the engine.
+func (r TodoistHabiticaTextRule) Automation() Automation {
+	r.SetDefaults()
+	conditions := make([]Condition, 0, len(r.Labels)+2)
+	if r.Rule != "" {
+		conditions = append(conditions, Condition{Field: "item.content", Op: r.MatchType, Value: r.Rule})
+	}
+	for _, label := range r.Labels {
+		conditions = append(conditions, Condition{Field: "item.labels", Op: OpHas, Value: label})
+	}
+	if r.SectionId != "" {
+		conditions = append(conditions, Condition{Field: "item.section_id", Op: OpEquals, Value: r.SectionId})
+	}
+	match := MatchAll
+	if r.Operator == RuleOperatorOr {
+		match = MatchAny
+	}
+	return Automation{
+		Id:     r.Id,
+		Source: AutomationSourceText,
+		Name:   r.Name,
+		// a text rule with nothing to match never applies
+		Enabled:    len(conditions) > 0,
+		Trigger:    Trigger{Kind: TriggerTodoist, Event: TodoistItemCompleted},
+		Match:      match,
+		Conditions: conditions,
+		Actions:    []Action{{Kind: ActionScoreHabitica, TaskId: r.HabitId}},
+		Group:      TodoistHabiticaGroup,
+		Priority:   r.Priority,
+		Stop:       !r.Continue,
+	}
+}
+
+// Automation expresses a project rule for the engine, as a fallback for when
+// no text rule matched.
+func (r TodoistHabiticaProjectRule) Automation() Automation {
+	return Automation{
+		Id:      r.Id,
+		Source:  AutomationSourceProject,
+		Name:    r.Name,
+		Enabled: true,
+		Trigger: Trigger{Kind: TriggerTodoist, Event: TodoistItemCompleted},
+		Match:   MatchAll,
+		Conditions: []Condition{
+			{Field: "item.project_id", Op: OpEquals, Value: r.ProjectId},
+		},
+		Actions:  []Action{{Kind: ActionScoreHabitica, TaskId: r.HabitId}},
+		Group:    TodoistHabiticaGroup,
+		Stop:     true,
+		Fallback: true,
+	}
+}
+
+// HabitRule reads back the min habit rule an automation was stored as.
+func (a Automation) HabitRule() HabiticaHabitRule {
+	rule := HabiticaHabitRule{Id: a.Id, Name: a.Name, HabitId: a.Trigger.Subject}
+	if len(a.Conditions) > 0 {
+		rule.Counter = strings.TrimPrefix(a.Conditions[0].Field, "task.")
+		rule.MinScore, _ = strconv.Atoi(a.Conditions[0].Value)
+	}
+	if len(a.Actions) > 0 {
+		rule.DailyId = a.Actions[0].TaskId
+	}
+	rule.SetDefaults()
+	return rule
+}
+
+// TextRule reads back the text rule an automation was stored as.
+func (a Automation) TextRule() TodoistHabiticaTextRule {
+	rule := TodoistHabiticaTextRule{
+		Id:       a.Id,
+		Name:     a.Name,
+		Operator: RuleOperatorAnd,
+		Priority: a.Priority,
+		Continue: !a.Stop,
+	}
+	if a.Match == MatchAny {
+		rule.Operator = RuleOperatorOr
+	}
+	for _, c := range a.Conditions {
+		switch c.Field {
+		case "item.content":
+			rule.Rule = c.Value
+			rule.MatchType = c.Op
+		case "item.labels":
+			rule.Labels = append(rule.Labels, c.Value)
+		case "item.section_id":
+			rule.SectionId = c.Value
+		}
+	}
+	if len(a.Actions) > 0 {
+		rule.HabitId = a.Actions[0].TaskId
+	}
+	rule.SetDefaults()
+	return rule
+}
+
+// ProjectRule reads back the project rule an automation was stored as.
+func (a Automation) ProjectRule() TodoistHabiticaProjectRule {
+	rule := TodoistHabiticaProjectRule{Id: a.Id, Name: a.Name}
+	if len(a.Conditions) > 0 {
+		rule.ProjectId = a.Conditions[0].Value
+	}
+	if len(a.Actions) > 0 {
+		rule.HabitId = a.Actions[0].TaskId
+	}
+	return rule
+}
@@ -0,0 +1,26 @@
+package models
+
+// Chart types.
+const (
+	ChartBar       = "bar"
+	ChartSparkline = "sparkline"
+)
+
+// Chart is a daily series to draw, one point per day from From to To that has
+// a value.
+type Chart struct {
+	Name   string       `json:"name"`
+	Title  string       `json:"title"`
+	Unit   string       `json:"unit,omitempty"`
+	Type   string       `json:"type"`
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Days   int          `json:"days"`
+	Points []ChartPoint `json:"points"`
+}
+
+type ChartPoint struct {
+	Date  string   `json:"date"`
+	Value float64  `json:"value"`
+	Goal  *float64 `json:"goal,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Dashboard is what the web dashboard shows, the same as the kindle
+// dashboard: habits, today's dailies, chores and hygiene tasks from Todoist
+// and the Fitbit summary. Sections whose source failed are empty and the
+// failure is in Sources.
+type Dashboard struct {
+	Habits    []DashboardHabit     `json:"habits"`
+	Dailies   []DashboardDaily     `json:"dailies"`
+	Chores    []DashboardTask      `json:"chores"`
+	Hygiene   []DashboardTask      `json:"hygiene"`
+	Fitbit    *DashboardFitbit     `json:"fitbit,omitempty"`
+	Sources   []WidgetSourceStatus `json:"sources"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+type DashboardHabit struct {
+	Id    string `json:"id"`
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+	// the habit's goal, if it has one
+	Goal *HabitGoal `json:"goal,omitempty"`
+}
+
+type DashboardDaily struct {
+	Id        string `json:"id"`
+	Text      string `json:"text"`
+	Completed bool   `json:"completed"`
+	Streak    int    `json:"streak"`
+}
+
+type DashboardTask struct {
+	Id      string `json:"id"`
+	Content string `json:"content"`
+}
+
+type DashboardFitbit struct {
+	Steps             int `json:"steps"`
+	StepsGoal         int `json:"steps_goal"`
+	ActiveMinutes     int `json:"active_minutes"`
+	ActiveMinutesGoal int `json:"active_minutes_goal"`
+	// the latest weight logged in the last week, 0 if none
+	Weight float64 `json:"weight"`
+}
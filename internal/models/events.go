@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const HabiticaEventSource = "habitica"
+const TodoistEventSource = "todoist"
+
+const (
+	EventStatusPending   = "pending"
+	EventStatusProcessed = "processed"
+	EventStatusSkipped   = "skipped"
+	EventStatusFailed    = "failed"
+	EventStatusDuplicate = "duplicate"
+)
+
+// Event is an inbound webhook as recorded in the event journal.
+type Event struct {
+	Id          int64      `json:"id"`
+	Source      string     `json:"source"`
+	DeliveryKey string     `json:"delivery_key,omitempty"`
+	EventType   string     `json:"event_type"`
+	Payload     string     `json:"payload"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Attempts    int        `json:"attempts"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// EventFilter narrows a journal listing. Zero values match everything.
+// Subject is the id of the Todoist item or Habitica task an event is about.
+type EventFilter struct {
+	Source    string
+	Status    string
+	EventType string
+	Subject   string
+	Since     time.Time
+	Limit     int
+}
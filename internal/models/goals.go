@@ -0,0 +1,36 @@
+package models
+
+// How often a habit goal's target is meant to be reached.
+const (
+	GoalPeriodDaily  = "daily"
+	GoalPeriodWeekly = "weekly"
+)
+
+// HabitGoal is the target for a Habitica habit's counter over a period, such
+// as 8 glasses of water a day.
+type HabitGoal struct {
+	HabitId string `json:"habit_id"`
+	Target  int    `json:"target"`
+	Period  string `json:"period"`
+	Unit    string `json:"unit"`
+}
+
+// SetDefaults makes a goal daily when no period is set.
+func (g *HabitGoal) SetDefaults() {
+	if g.Period == "" {
+		g.Period = GoalPeriodDaily
+	}
+}
+
+func (g HabitGoal) Validate() error {
+	if g.HabitId == "" {
+		return ValidationError{"habit_id", "is required"}
+	}
+	if g.Target < 1 {
+		return ValidationError{"target", "must be at least 1"}
+	}
+	if g.Period != GoalPeriodDaily && g.Period != GoalPeriodWeekly {
+		return ValidationError{"period", "must be daily or weekly"}
+	}
+	return nil
+}
@@ -15,13 +15,42 @@ type HabiticaWebhookTask struct {
 	Up   int    `json:"counterUp"`
 	Down int    `json:"counterDown"`
 
-	Type  string `json:"type"`
-	Text  string `json:"text"`
-	Notes string `json:"notes"`
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Notes     string `json:"notes"`
+	Completed bool   `json:"completed"`
+	UpdatedAt string `json:"updatedAt"`
 }
 
+// Habit counters a min habit rule can compare against its threshold.
+const (
+	HabitCounterUp  = "up"
+	HabitCounterNet = "net"
+)
+
+// HabiticaHabitRule scores DailyId once a day when the habit's Counter reaches
+// MinScore, and unscores it if the counter drops back below. A MinScore of 0
+// uses the target of the habit's goal instead.
 type HabiticaHabitRule struct {
-	HabitId  string
-	DailyId  string
-	MinScore int
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	HabitId  string `json:"habit_id"`
+	DailyId  string `json:"daily_id"`
+	MinScore int    `json:"min_score"`
+	Counter  string `json:"counter"`
+}
+
+// SetDefaults counts only positive scores when no counter is set.
+func (r *HabiticaHabitRule) SetDefaults() {
+	if r.Counter == "" {
+		r.Counter = HabitCounterUp
+	}
+}
+
+// Count is the task's counter value that the rule compares to MinScore.
+func (r HabiticaHabitRule) Count(task HabiticaWebhookTask) int {
+	if r.Counter == HabitCounterNet {
+		return task.Up - task.Down
+	}
+	return task.Up
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// JobState is what is known about a scheduled job. Running is only tracked
+// in memory.
+type JobState struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	Running        bool       `json:"running"`
+}
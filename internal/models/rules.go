@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ValidationError reports a rule field that failed validation.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+func (r HabiticaHabitRule) Validate() error {
+	if r.HabitId == "" {
+		return ValidationError{"habit_id", "is required"}
+	}
+	if r.DailyId == "" {
+		return ValidationError{"daily_id", "is required"}
+	}
+	if r.HabitId == r.DailyId {
+		return ValidationError{"daily_id", "must be different from habit_id"}
+	}
+	if r.MinScore < 0 {
+		return ValidationError{"min_score", "must be at least 1, or 0 to use the habit's goal"}
+	}
+	if r.Counter != HabitCounterUp && r.Counter != HabitCounterNet {
+		return ValidationError{"counter", "must be up or net"}
+	}
+	return nil
+}
+
+func (r TodoistHabiticaTextRule) Validate() error {
+	if r.Rule == "" && len(r.Labels) == 0 && r.SectionId == "" {
+		return ValidationError{"rule", "text, a label or a section is required"}
+	}
+	switch r.MatchType {
+	case TextMatchPrefix, TextMatchContains:
+	case TextMatchRegex:
+		if _, err := regexp.Compile(r.Rule); err != nil {
+			return ValidationError{"rule", "is not a valid regular expression: " + err.Error()}
+		}
+	default:
+		return ValidationError{"match_type", "must be prefix, contains or regex"}
+	}
+	for _, label := range r.Labels {
+		if label == "" {
+			return ValidationError{"labels", "must not be empty"}
+		}
+	}
+	if r.Operator != RuleOperatorAnd && r.Operator != RuleOperatorOr {
+		return ValidationError{"operator", "must be and or or"}
+	}
+	if r.HabitId == "" {
+		return ValidationError{"habit_id", "is required"}
+	}
+	return nil
+}
+
+func (r TodoistHabiticaProjectRule) Validate() error {
+	if r.ProjectId == "" {
+		return ValidationError{"project_id", "is required"}
+	}
+	if r.HabitId == "" {
+		return ValidationError{"habit_id", "is required"}
+	}
+	return nil
+}
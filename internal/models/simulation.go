@@ -0,0 +1,12 @@
+package models
+
+// Simulation reports what processing a webhook payload would do, without
+// calling Habitica or Todoist or changing any stored state.
+type Simulation struct {
+	Source      string          `json:"source"`
+	EventType   string          `json:"event_type"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Automations []AutomationRun `json:"automations"`
+	Calls       []ActionCall    `json:"calls"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Snapshot metrics. Habit metrics have the habit's id as subject and widget
+// metrics "<widget>.<metric name>"; the others have none.
+const (
+	SnapshotHabitUp          = "habit.up"
+	SnapshotHabitDown        = "habit.down"
+	SnapshotDailiesDone      = "dailies.done"
+	SnapshotDailiesDue       = "dailies.due"
+	SnapshotTodoistCompleted = "todoist.completed"
+	SnapshotSteps            = "fitbit.steps"
+	SnapshotActiveMinutes    = "fitbit.active_minutes"
+	SnapshotWeight           = "fitbit.weight"
+	SnapshotSleepMinutes     = "fitbit.sleep_minutes"
+	SnapshotWidget           = "widget"
+)
+
+// SnapshotDateFormat is the layout of Snapshot.Date, a day in local time.
+const SnapshotDateFormat = "2006-01-02"
+
+// Snapshot is the value of a metric on a day, and its goal that day if it
+// had one.
+type Snapshot struct {
+	Date       string    `json:"date"`
+	Metric     string    `json:"metric"`
+	Subject    string    `json:"subject"`
+	Value      float64   `json:"value"`
+	Goal       *float64  `json:"goal,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// SnapshotFilter narrows a snapshot listing. From and To are inclusive dates
+// in SnapshotDateFormat; empty fields match everything.
+type SnapshotFilter struct {
+	Metric  string
+	Subject string
+	From    string
+	To      string
+}
@@ -0,0 +1,39 @@
+package models
+
+// MetricStats summarizes the daily snapshots of one metric and subject.
+// Averages cover the days from From to To that have a value; streaks cover
+// all recorded history.
+type MetricStats struct {
+	Metric  string `json:"metric"`
+	Subject string `json:"subject"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Days    int    `json:"days"`
+
+	Average float64 `json:"average"`
+	// RollingAverage is only filled in for a single metric's stats
+	RollingAverage []StatsPoint `json:"rolling_average,omitempty"`
+
+	// GoalDays are the days in range with a goal, HitDays those that reached
+	// it. HitRate is omitted when no day had a goal.
+	GoalDays int      `json:"goal_days"`
+	HitDays  int      `json:"hit_days"`
+	HitRate  *float64 `json:"hit_rate,omitempty"`
+
+	// streaks of consecutive days reaching the goal. Today only counts once
+	// reached, so an unfinished day doesn't break the current streak.
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+
+	// average per day over the last 7 days and the 7 before, and the change
+	// between them as a fraction of last week's; omitted when last week has
+	// no values or averaged 0
+	ThisWeek     float64  `json:"this_week"`
+	LastWeek     float64  `json:"last_week"`
+	WeekOverWeek *float64 `json:"week_over_week,omitempty"`
+}
+
+type StatsPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
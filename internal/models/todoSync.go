@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// TodoSyncProject is a Todoist project whose tasks are mirrored as Habitica
+// todos.
+type TodoSyncProject struct {
+	TodoistProjectId string    `json:"todoist_project_id"`
+	AddedAt          time.Time `json:"added_at"`
+}
+
+// TodoLink links a Todoist task to the Habitica todo mirroring it. Content
+// and Completed are the state last written to both sides, at UpdatedAt by the
+// UpdatedBy source; a change older than UpdatedAt loses to it. Pending are the
+// sync's own writes whose webhooks haven't come back yet.
+type TodoLink struct {
+	Id               int64      `json:"id"`
+	TodoistTaskId    string     `json:"todoist_task_id"`
+	HabiticaTaskId   string     `json:"habitica_task_id"`
+	TodoistProjectId string     `json:"todoist_project_id"`
+	Content          string     `json:"content"`
+	Completed        bool       `json:"completed"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	UpdatedBy        string     `json:"updated_by"`
+	Pending          []TodoEcho `json:"pending"`
+}
+
+// Changes the sync writes to a side of a link.
+const (
+	TodoChangeCompleted   = "completed"
+	TodoChangeUncompleted = "uncompleted"
+	TodoChangeContent     = "content"
+)
+
+// TodoEchoTTL is how long the webhook for one of the sync's own writes is
+// waited for. They normally arrive within seconds; one that never does
+// mustn't swallow a real change much later.
+const TodoEchoTTL = 10 * time.Minute
+
+// TodoEcho is a change the sync wrote to Source, at At, which will come back
+// as a webhook from Source.
+type TodoEcho struct {
+	Source  string    `json:"source"`
+	Change  string    `json:"change"`
+	Content string    `json:"content,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// CompletionChange is the change that completes or uncompletes a task.
+func CompletionChange(completed bool) string {
+	if completed {
+		return TodoChangeCompleted
+	}
+	return TodoChangeUncompleted
+}
+
+// Expect records a write whose webhook is still to come.
+func (l *TodoLink) Expect(echo TodoEcho) {
+	l.dropExpired(echo.At)
+	l.Pending = append(l.Pending, echo)
+}
+
+// TakeEcho reports whether a change is the webhook of a pending write, and
+// forgets the write if so.
+func (l *TodoLink) TakeEcho(source, change, content string, now time.Time) bool {
+	l.dropExpired(now)
+	for i, echo := range l.Pending {
+		if echo.Source == source && echo.Change == change && echo.Content == content {
+			l.Pending = append(l.Pending[:i:i], l.Pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (l *TodoLink) dropExpired(now time.Time) {
+	pending := make([]TodoEcho, 0, len(l.Pending))
+	for _, echo := range l.Pending {
+		if now.Sub(echo.At) < TodoEchoTTL {
+			pending = append(pending, echo)
+		}
+	}
+	l.Pending = pending
+}
@@ -1,25 +1,245 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Todoist webhook event names.
+const (
+	TodoistItemAdded       = "item:added"
+	TodoistItemUpdated     = "item:updated"
+	TodoistItemDeleted     = "item:deleted"
+	TodoistItemCompleted   = "item:completed"
+	TodoistItemUncompleted = "item:uncompleted"
+
+	TodoistNoteAdded   = "note:added"
+	TodoistNoteUpdated = "note:updated"
+	TodoistNoteDeleted = "note:deleted"
+
+	TodoistProjectAdded      = "project:added"
+	TodoistProjectUpdated    = "project:updated"
+	TodoistProjectDeleted    = "project:deleted"
+	TodoistProjectArchived   = "project:archived"
+	TodoistProjectUnarchived = "project:unarchived"
+
+	TodoistSectionAdded      = "section:added"
+	TodoistSectionUpdated    = "section:updated"
+	TodoistSectionDeleted    = "section:deleted"
+	TodoistSectionArchived   = "section:archived"
+	TodoistSectionUnarchived = "section:unarchived"
+
+	TodoistLabelAdded   = "label:added"
+	TodoistLabelDeleted = "label:deleted"
+	TodoistLabelUpdated = "label:updated"
+
+	TodoistFilterAdded   = "filter:added"
+	TodoistFilterDeleted = "filter:deleted"
+	TodoistFilterUpdated = "filter:updated"
+
+	TodoistReminderFired = "reminder:fired"
+)
+
+// TodoistWebhook is the envelope Todoist posts for every event. EventData
+// depends on the event name and is decoded with the typed accessors below.
 type TodoistWebhook struct {
-	EventName string       `json:"event_name"`
-	EventData TodoistEvent `json:"event_data"`
+	EventName   string              `json:"event_name"`
+	UserId      string              `json:"user_id"`
+	EventData   json.RawMessage     `json:"event_data"`
+	Initiator   TodoistCollaborator `json:"initiator"`
+	TriggeredAt string              `json:"triggered_at"`
+	Version     string              `json:"version"`
+}
+
+type TodoistCollaborator struct {
+	Id       string `json:"id"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+}
+
+// TodoistItem is the event data for item:* events.
+type TodoistItem struct {
+	Id             string      `json:"id"`
+	UserId         string      `json:"user_id"`
+	ProjectId      string      `json:"project_id"`
+	SectionId      string      `json:"section_id"`
+	ParentId       string      `json:"parent_id"`
+	Content        string      `json:"content"`
+	Description    string      `json:"description"`
+	Priority       int         `json:"priority"`
+	Labels         []string    `json:"labels"`
+	Checked        bool        `json:"checked"`
+	IsDeleted      bool        `json:"is_deleted"`
+	Due            *TodoistDue `json:"due"`
+	AddedAt        string      `json:"added_at"`
+	UpdatedAt      string      `json:"updated_at"`
+	CompletedAt    string      `json:"completed_at"`
+	ResponsibleUid string      `json:"responsible_uid"`
+}
+
+type TodoistDue struct {
+	Date        string `json:"date"`
+	String      string `json:"string"`
+	Timezone    string `json:"timezone"`
+	IsRecurring bool   `json:"is_recurring"`
+}
+
+// TodoistNote is the event data for note:* events.
+type TodoistNote struct {
+	Id        string `json:"id"`
+	ItemId    string `json:"item_id"`
+	ProjectId string `json:"project_id"`
+	Content   string `json:"content"`
+	PostedAt  string `json:"posted_at"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// TodoistProject is the event data for project:* events.
+type TodoistProject struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	ParentId   string `json:"parent_id"`
+	IsArchived bool   `json:"is_archived"`
+	IsDeleted  bool   `json:"is_deleted"`
+}
+
+// TodoistSection is the event data for section:* events.
+type TodoistSection struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	ProjectId  string `json:"project_id"`
+	IsArchived bool   `json:"is_archived"`
+	IsDeleted  bool   `json:"is_deleted"`
+}
+
+// TodoistLabel is the event data for label:* events.
+type TodoistLabel struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// TodoistFilter is the event data for filter:* events.
+type TodoistFilter struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// TodoistReminder is the event data for reminder:fired events.
+type TodoistReminder struct {
+	Id     string      `json:"id"`
+	ItemId string      `json:"item_id"`
+	Type   string      `json:"type"`
+	Due    *TodoistDue `json:"due"`
+}
+
+func (w TodoistWebhook) Item() (TodoistItem, error) {
+	var item TodoistItem
+	return item, w.decode("item", &item)
+}
+
+func (w TodoistWebhook) Note() (TodoistNote, error) {
+	var note TodoistNote
+	return note, w.decode("note", &note)
+}
+
+func (w TodoistWebhook) Project() (TodoistProject, error) {
+	var project TodoistProject
+	return project, w.decode("project", &project)
 }
 
-type TodoistEvent struct {
-	Content     string `json:"content"`
-	Description string `json:"description"`
-	ProjectId   string `json:"project_id"`
-	Id          string `json:"id"`
+func (w TodoistWebhook) Section() (TodoistSection, error) {
+	var section TodoistSection
+	return section, w.decode("section", &section)
 }
 
+func (w TodoistWebhook) Label() (TodoistLabel, error) {
+	var label TodoistLabel
+	return label, w.decode("label", &label)
+}
+
+func (w TodoistWebhook) Filter() (TodoistFilter, error) {
+	var filter TodoistFilter
+	return filter, w.decode("filter", &filter)
+}
+
+func (w TodoistWebhook) Reminder() (TodoistReminder, error) {
+	var reminder TodoistReminder
+	return reminder, w.decode("reminder", &reminder)
+}
+
+// decode unmarshals the event data after checking that the event name is for
+// the expected kind of object, e.g. "item" for item:completed.
+func (w TodoistWebhook) decode(kind string, v any) error {
+	eventKind, _, _ := strings.Cut(w.EventName, ":")
+	if eventKind != kind {
+		return fmt.Errorf("%s event has no %s data", w.EventName, kind)
+	}
+	if err := json.Unmarshal(w.EventData, v); err != nil {
+		return fmt.Errorf("error decoding %s event data: %w", w.EventName, err)
+	}
+	return nil
+}
+
+// TodoistCompletion links a Todoist completion to a Habitica task it scored,
+// so the score can be undone if the Todoist task is uncompleted.
+type TodoistCompletion struct {
+	Id             int64
+	TodoistTaskId  string
+	HabiticaTaskId string
+	ScoredAt       time.Time
+}
+
+// Ways a text rule's Rule can be matched against a task's content.
+const (
+	TextMatchPrefix   = "prefix"
+	TextMatchContains = "contains"
+	TextMatchRegex    = "regex"
+)
+
+// Operators combining the conditions of a text rule.
+const (
+	RuleOperatorAnd = "and"
+	RuleOperatorOr  = "or"
+)
+
+// TodoistHabiticaTextRule scores a Habitica task when a completed Todoist task
+// matches its conditions: the text Rule, each of Labels and SectionId, where
+// set, combined with Operator. Rules are tried by descending Priority and
+// matching stops at the first match unless Continue is set.
 type TodoistHabiticaTextRule struct {
-	Name    string
-	Rule    string
-	HabitId string
+	Id        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Rule      string   `json:"rule"`
+	MatchType string   `json:"match_type"`
+	Labels    []string `json:"labels"`
+	SectionId string   `json:"section_id"`
+	Operator  string   `json:"operator"`
+	Priority  int      `json:"priority"`
+	Continue  bool     `json:"continue"`
+	HabitId   string   `json:"habit_id"`
+}
+
+// SetDefaults fills in the match type and operator when they're left empty.
+func (r *TodoistHabiticaTextRule) SetDefaults() {
+	if r.MatchType == "" {
+		r.MatchType = TextMatchPrefix
+	}
+	if r.Operator == "" {
+		r.Operator = RuleOperatorAnd
+	}
+	if r.Labels == nil {
+		r.Labels = []string{}
+	}
 }
 
 type TodoistHabiticaProjectRule struct {
-	Name      string
-	ProjectId string
-	HabitId   string
+	Id        int64  `json:"id"`
+	Name      string `json:"name"`
+	ProjectId string `json:"project_id"`
+	HabitId   string `json:"habit_id"`
 }
@@ -1,14 +1,131 @@
 package models
 
+import (
+	"fmt"
+	"slices"
+)
+
+// DefaultWidget is the widget metrics belong to when none is named.
+const DefaultWidget = "default"
+
+// Where a widget metric reads from.
+const (
+	// a Habitica habit, selected by text or id
+	MetricSourceHabit = "habitica.habit"
+	// the Habitica dailies
+	MetricSourceDailies = "habitica.dailies"
+	// Todoist tasks matching the selector filter, "today | od" when empty
+	MetricSourceTodoistTasks = "todoist.tasks"
+	// Todoist completion stats
+	MetricSourceTodoistStats = "todoist.stats"
+)
+
+// Where a widget metric's goal comes from.
+const (
+	GoalSourceNone = "none"
+	// the metric's own Goal
+	GoalSourceFixed = "fixed"
+	// the habit's goal in habit_goals
+	GoalSourceHabitGoal = "habit_goal"
+	// the number of dailies due today
+	GoalSourceDailiesDue = "dailies_due"
+	// the daily goal set in Todoist
+	GoalSourceTodoistDailyGoal = "todoist_daily_goal"
+)
+
+// metricValues are the value expressions each source supports.
+var metricValues = map[string][]string{
+	MetricSourceHabit:        {"up", "down", "net"},
+	MetricSourceDailies:      {"done", "due", "remaining"},
+	MetricSourceTodoistTasks: {"count"},
+	MetricSourceTodoistStats: {"completed_today"},
+}
+
+// metricGoalSources are the goal sources each source supports besides none
+// and fixed.
+var metricGoalSources = map[string][]string{
+	MetricSourceHabit:        {GoalSourceHabitGoal},
+	MetricSourceDailies:      {GoalSourceDailiesDue},
+	MetricSourceTodoistStats: {GoalSourceTodoistDailyGoal},
+}
+
+// WidgetMetric is one number shown on a widget, with an optional goal.
+type WidgetMetric struct {
+	Id         int64  `json:"id"`
+	Widget     string `json:"widget"`
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Source     string `json:"source"`
+	Selector   string `json:"selector"`
+	Value      string `json:"value"`
+	GoalSource string `json:"goal_source"`
+	Goal       int    `json:"goal"`
+	Position   int    `json:"position"`
+}
+
+// SetDefaults puts a new metric on the default widget without a goal.
+func (m *WidgetMetric) SetDefaults() {
+	if m.Widget == "" {
+		m.Widget = DefaultWidget
+	}
+	if m.GoalSource == "" {
+		m.GoalSource = GoalSourceNone
+	}
+}
+
+func (m WidgetMetric) Validate() error {
+	if m.Name == "" {
+		return ValidationError{"name", "is required"}
+	}
+	values, ok := metricValues[m.Source]
+	if !ok {
+		return ValidationError{"source", fmt.Sprintf("must be %s, %s, %s or %s",
+			MetricSourceHabit, MetricSourceDailies, MetricSourceTodoistTasks, MetricSourceTodoistStats)}
+	}
+	if m.Source == MetricSourceHabit && m.Selector == "" {
+		return ValidationError{"selector", "the habit's text or id is required"}
+	}
+	if !slices.Contains(values, m.Value) {
+		return ValidationError{"value", fmt.Sprintf("must be one of %v for %s", values, m.Source)}
+	}
+	if m.GoalSource != GoalSourceNone && m.GoalSource != GoalSourceFixed &&
+		!slices.Contains(metricGoalSources[m.Source], m.GoalSource) {
+		return ValidationError{"goal_source", fmt.Sprintf("must be none, fixed or one of %v for %s", metricGoalSources[m.Source], m.Source)}
+	}
+	return nil
+}
+
+// WidgetMetricValue is a metric as shown on the widget. Goal is omitted for
+// metrics without one.
+type WidgetMetricValue struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Value int    `json:"value"`
+	Goal  *int   `json:"goal,omitempty"`
+}
+
+// How loading a widget's source went.
+const (
+	WidgetSourceOK       = "ok"
+	WidgetSourceError    = "error"
+	WidgetSourceTimeout  = "timeout"
+	WidgetSourceCanceled = "canceled"
+)
+
+// WidgetSourceStatus reports how one of the sources a widget reads from
+// loaded. Metrics reading from a source that didn't load are left out.
+type WidgetSourceStatus struct {
+	Source     string `json:"source"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// WidgetResponse holds the metrics that could be worked out, the status of
+// each source and any errors evaluating metrics.
 type WidgetResponse struct {
-	HabiticaWaterValue int      `json:"habitica_water_value"`
-	HabiticaWaterGoal  int      `json:"habitica_water_goal"`
-	HabiticaReadValue  int      `json:"habitica_read_value"`
-	HabiticaReadGoal   int      `json:"habitica_read_goal"`
-	HabiticaDailysDone int      `json:"habitica_dailys_done"`
-	HabiticaDailysDue  int      `json:"habitica_dailys_due"`
-	TodoistTasksDone   int      `json:"todoist_tasks_done"`
-	TodoistTasksGoal   int      `json:"todoist_tasks_goal"`
-	TodoistTasksDue    int      `json:"todoist_tasks_due"`
-	Errors             []string `json:"errors,omitempty"`
+	Widget  string               `json:"widget"`
+	Metrics []WidgetMetricValue  `json:"metrics"`
+	Sources []WidgetSourceStatus `json:"sources"`
+	Errors  []string             `json:"errors,omitempty"`
 }
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"misc/internal/models"
+)
+
+// activityKeepAlive is how often an idle stream gets a comment, so proxies
+// don't close it.
+const activityKeepAlive = 15 * time.Second
+
+func (s *Server) registerActivityRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /events/stream", s.activityStreamHandler)
+}
+
+// activityStreamHandler streams activity as Server-Sent Events until the
+// client goes away, optionally filtered by kind and source.
+func (s *Server) activityStreamHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.ActivityFilter{Kind: q.Get("kind"), Source: q.Get("source")}
+	switch filter.Kind {
+	case "", models.ActivityWebhook, models.ActivityRule, models.ActivityCall:
+	default:
+		writeError(w, models.ValidationError{Field: "kind", Message: "must be webhook, rule or call"})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Error("error clearing write deadline for activity stream", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	activity, unsubscribe := s.activity.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(activityKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case a, ok := <-activity:
+			if !ok {
+				return
+			}
+			if !filter.Matches(a) {
+				continue
+			}
+			err = writeActivity(w, a)
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			slog.Info("activity stream closed", "err", err)
+			return
+		}
+	}
+}
+
+// writeActivity writes one event, named after the activity's kind.
+func writeActivity(w http.ResponseWriter, a models.Activity) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", a.Id, a.Kind, data)
+	return err
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"misc/cmd/web"
+	"misc/internal/database"
+	"misc/internal/models"
+	"misc/internal/services"
+)
+
+const sessionCookie = "session"
+
+// publicRoutes need no credentials. The webhooks check their own.
+var publicRoutes = map[string]bool{
+	"/health":             true,
+	"/assets/":            true,
+	"POST /habiticaEvent": true,
+	"POST /todoistEvent":  true,
+	"GET /login":          true,
+	"POST /login":         true,
+	"POST /logout":        true,
+}
+
+// widgetRoutes are the read-only routes open to the widget scope. Every other
+// route needs admin, including the web UI, whose pages check off dailies and
+// tasks and stream activity.
+var widgetRoutes = map[string]bool{
+	"GET /widget":             true,
+	"GET /charts/{file}":      true,
+	"GET /api/stats":          true,
+	"GET /api/stats/{metric}": true,
+	"GET /api/snapshots":      true,
+}
+
+// requiredScope is the scope needed for the route pattern a request matched,
+// or "" for a public route.
+func requiredScope(pattern string) string {
+	switch {
+	case publicRoutes[pattern]:
+		return ""
+	case widgetRoutes[pattern]:
+		return models.ScopeWidget
+	}
+	return models.ScopeAdmin
+}
+
+func (s *Server) registerAuthRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /login", s.loginPageHandler)
+	mux.HandleFunc("POST /login", s.loginHandler)
+	mux.HandleFunc("POST /logout", s.logoutHandler)
+	mux.HandleFunc("GET /api/tokens", s.listTokensHandler)
+	mux.HandleFunc("POST /api/tokens", s.createTokenHandler)
+	mux.HandleFunc("DELETE /api/tokens/{id}", s.deleteTokenHandler)
+}
+
+// RouteScope is the scope a request needs on the server's routes, or "" for a
+// public route.
+func (s *Server) RouteScope(r *http.Request) string {
+	_, pattern := s.routes().Handler(r)
+	return requiredScope(pattern)
+}
+
+// requireAuth lets a request through to mux when its API token or session
+// has the scope its route needs.
+func (s *Server) requireAuth(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		scope := requiredScope(pattern)
+		if scope == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		scopes, err := s.credentials(r)
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			s.rejectAuth(w, r, "unknown api token")
+		case err != nil:
+			writeError(w, err)
+		case scopes == nil:
+			s.loginRequired(w, r)
+		case !models.HasScope(scopes, scope):
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "requires the " + scope + " scope"})
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+// credentials returns the scopes of the request's API token, sent as a
+// bearer token or, on GET for embedding, a ?token= parameter, or of its
+// session. They're nil without either or with an expired session.
+func (s *Server) credentials(r *http.Request) ([]string, error) {
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		secret = r.URL.Query().Get("token")
+		ok = secret != ""
+	}
+	if ok {
+		token, err := s.auth.Token(secret)
+		return token.Scopes, err
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	session, err := s.auth.Session(cookie.Value)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	return session.Scopes, err
+}
+
+// loginRequired sends browsers to the login page and answers 401 otherwise.
+func (s *Server) loginRequired(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.RequestURI()
+	if r.Header.Get("HX-Request") != "" {
+		// come back to the page that made the htmx request
+		if page, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil {
+			next = page.RequestURI()
+		}
+		w.Header().Set("HX-Redirect", "/login?next="+url.QueryEscape(next))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+}
+
+// rejectAuth answers 401 and records the attempt in the audit log.
+func (s *Server) rejectAuth(w http.ResponseWriter, r *http.Request, reason string) {
+	s.audit(r, models.AuditAuthRejected, reason)
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+}
+
+func (s *Server) audit(r *http.Request, action, reason string) {
+	entry := models.AuditEntry{
+		Action:     action,
+		RemoteAddr: s.remoteAddr(r),
+		Path:       r.URL.Path,
+		Reason:     reason,
+	}
+	slog.Warn("rejected credentials", "action", action, "remoteAddr", entry.RemoteAddr, "path", entry.Path, "reason", reason)
+	if err := s.db.RecordAudit(entry); err != nil {
+		slog.Error("error recording audit entry", "err", err)
+	}
+}
+
+func (s *Server) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, r, web.LoginPage(web.LoginForm{Next: r.URL.Query().Get("next"), Enabled: s.auth.LoginEnabled()}))
+}
+
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	form := web.LoginForm{Next: r.PostFormValue("next"), Enabled: s.auth.LoginEnabled()}
+	secret, session, err := s.auth.Login(r.PostFormValue("password"))
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		s.audit(r, models.AuditLoginFailed, "wrong password")
+		form.Error = "Wrong password"
+		w.WriteHeader(http.StatusUnauthorized)
+		render(w, r, web.LoginPage(form))
+		return
+	}
+	if err != nil {
+		slog.Error("error logging in", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    secret,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, localRedirect(form.Next), http.StatusSeeOther)
+}
+
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := s.auth.Logout(cookie.Value); err != nil {
+			slog.Error("error logging out", "err", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// localRedirect keeps redirects after login on this site, defaulting to the
+// dashboard.
+func localRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/web"
+	}
+	return next
+}
+
+func (s *Server) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.db.ListAPITokens()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+type createTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type createTokenResponse struct {
+	models.APIToken
+	// Token is the secret, only ever shown here
+	Token string `json:"token"`
+}
+
+func (s *Server) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req createTokenRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	token, secret, err := s.auth.CreateToken(req.Name, req.Scopes)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, createTokenResponse{APIToken: token, Token: secret})
+}
+
+func (s *Server) deleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteAPIToken(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
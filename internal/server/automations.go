@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"misc/internal/models"
+)
+
+func (s *Server) registerAutomationRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/automations", s.listAutomationsHandler)
+	mux.HandleFunc("POST /api/automations", s.createAutomationHandler)
+	mux.HandleFunc("GET /api/automations/{id}", s.getAutomationHandler)
+	mux.HandleFunc("PUT /api/automations/{id}", s.updateAutomationHandler)
+	mux.HandleFunc("DELETE /api/automations/{id}", s.deleteAutomationHandler)
+}
+
+func (s *Server) listAutomationsHandler(w http.ResponseWriter, r *http.Request) {
+	automations, err := s.db.ListAutomations()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, automations)
+}
+
+func (s *Server) getAutomationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	automation, err := s.db.GetAutomation(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, automation)
+}
+
+func (s *Server) createAutomationHandler(w http.ResponseWriter, r *http.Request) {
+	automation := models.Automation{Enabled: true}
+	if err := decodeJSON(r, &automation); err != nil {
+		writeError(w, err)
+		return
+	}
+	automation.Source = models.AutomationSourceStored
+	automation.SetDefaults()
+	if err := s.checkAutomation(automation); err != nil {
+		writeError(w, err)
+		return
+	}
+	automation, err := s.db.CreateAutomation(automation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, automation)
+}
+
+func (s *Server) updateAutomationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	automation := models.Automation{Enabled: true}
+	if err := decodeJSON(r, &automation); err != nil {
+		writeError(w, err)
+		return
+	}
+	automation.Id = id
+	automation.Source = models.AutomationSourceStored
+	automation.SetDefaults()
+	if err := s.checkEditable(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.checkAutomation(automation); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.UpdateAutomation(automation); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, automation)
+}
+
+func (s *Server) deleteAutomationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.checkEditable(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteAutomation(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkEditable rejects changes to the automations the rules are stored as;
+// those are edited at /api/rules so they keep the shape of their rule.
+func (s *Server) checkEditable(id int64) error {
+	automation, err := s.db.GetAutomation(id)
+	if err != nil {
+		return err
+	}
+	if automation.Source != models.AutomationSourceStored {
+		return models.ValidationError{
+			Field:   "id",
+			Message: fmt.Sprintf("is a %s rule, edit it at /api/rules", automation.Source),
+		}
+	}
+	return nil
+}
+
+// checkAutomation validates the automation and the Habitica tasks its actions
+// name directly, rather than through a {field} of the event.
+func (s *Server) checkAutomation(automation models.Automation) error {
+	if err := automation.Validate(); err != nil {
+		return err
+	}
+	for i, action := range automation.Actions {
+		if action.Kind != models.ActionScoreHabitica && action.Kind != models.ActionUnscoreHabitica {
+			continue
+		}
+		if strings.Contains(action.TaskId, "{") {
+			continue
+		}
+		if err := s.validateTask(fmt.Sprintf("actions[%d].task_id", i), action.TaskId, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
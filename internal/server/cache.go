@@ -0,0 +1,33 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"misc/internal/services"
+)
+
+// cacheTTLs is how long upstream reads are cached, from DefaultCacheTTLs and
+// CACHE_TTL_HABITICA, CACHE_TTL_TODOIST and CACHE_TTL_FITBIT, e.g. "30s".
+// A TTL of 0 turns caching off for that API.
+func cacheTTLs() services.CacheTTLs {
+	ttls := services.DefaultCacheTTLs
+	for env, ttl := range map[string]*time.Duration{
+		"CACHE_TTL_HABITICA": &ttls.Habitica,
+		"CACHE_TTL_TODOIST":  &ttls.Todoist,
+		"CACHE_TTL_FITBIT":   &ttls.Fitbit,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			slog.Warn("ignoring invalid cache ttl", "env", env, "value", value)
+			continue
+		}
+		*ttl = d
+	}
+	return ttls
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"misc/cmd/web"
+	"misc/internal/models"
+	"misc/internal/services"
+)
+
+type ChartService interface {
+	Chart(name string, days int) (models.Chart, error)
+}
+
+// waterHabit is the habit charted as water, by text or id, from
+// CHART_WATER_HABIT.
+func waterHabit() string {
+	if habit := os.Getenv("CHART_WATER_HABIT"); habit != "" {
+		return habit
+	}
+	return services.DefaultWaterHabit
+}
+
+func (s *Server) registerChartRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /charts/{file}", s.chartHandler)
+}
+
+// chartHandler draws /charts/{name}.svg over the last ?days= days, 14 by
+// default, at ?width= by ?height=. ?type= draws it as a bar chart or
+// sparkline instead of the chart's own type.
+func (s *Server) chartHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".svg")
+	if !ok || !services.IsChart(name) {
+		http.NotFound(w, r)
+		return
+	}
+	days, err := intParam(r, "days", 14, 2, 365)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	size := web.DefaultChartSize
+	if size.Width, err = intParam(r, "width", size.Width, 50, 2000); err != nil {
+		writeError(w, err)
+		return
+	}
+	if size.Height, err = intParam(r, "height", size.Height, 40, 1000); err != nil {
+		writeError(w, err)
+		return
+	}
+	chartType := r.URL.Query().Get("type")
+	switch chartType {
+	case "", models.ChartBar, models.ChartSparkline:
+	default:
+		writeError(w, models.ValidationError{Field: "type", Message: "must be bar or sparkline"})
+		return
+	}
+
+	chart, err := s.charts.Chart(name, days)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if chartType != "" {
+		chart.Type = chartType
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=60")
+	render(w, r, web.ChartSVG(chart, size))
+}
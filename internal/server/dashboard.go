@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"misc/cmd/web"
+	"misc/internal/models"
+)
+
+type DashboardService interface {
+	GetDashboard(context.Context) models.Dashboard
+	CheckDaily(dailyId string, done bool) error
+	CloseTask(taskId string) error
+}
+
+func (s *Server) registerDashboardRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /web", s.dashboardPageHandler)
+	mux.HandleFunc("GET /web/dashboard", s.dashboardContentHandler)
+	mux.HandleFunc("POST /web/dailies/{id}/check", s.checkDailyHandler(true))
+	mux.HandleFunc("POST /web/dailies/{id}/uncheck", s.checkDailyHandler(false))
+	mux.HandleFunc("POST /web/tasks/{id}/close", s.closeTaskHandler)
+}
+
+func (s *Server) dashboardPageHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, r, web.DashboardPage(s.dashboard.GetDashboard(r.Context())))
+}
+
+func (s *Server) dashboardContentHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, r, web.DashboardContent(s.dashboard.GetDashboard(r.Context())))
+}
+
+// checkDailyHandler checks off or unchecks a daily and answers with the
+// refreshed dashboard.
+func (s *Server) checkDailyHandler(done bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.dashboard.CheckDaily(r.PathValue("id"), done); err != nil {
+			slog.Error("error checking daily", "id", r.PathValue("id"), "done", done, "err", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.dashboardContentHandler(w, r)
+	}
+}
+
+func (s *Server) closeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.dashboard.CloseTask(r.PathValue("id")); err != nil {
+		slog.Error("error closing task", "id", r.PathValue("id"), "err", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.dashboardContentHandler(w, r)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"misc/internal/models"
+)
+
+func (s *Server) registerEventRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/events", s.listEventsHandler)
+	mux.HandleFunc("GET /api/events/{id}", s.getEventHandler)
+	mux.HandleFunc("POST /api/events/replay", s.replayEventsHandler)
+	mux.HandleFunc("GET /api/audit", s.listAuditHandler)
+}
+
+func (s *Server) listEventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.EventFilter{
+		Source:    q.Get("source"),
+		Status:    q.Get("status"),
+		EventType: q.Get("type"),
+		Subject:   q.Get("subject"),
+		Limit:     100,
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			writeError(w, models.ValidationError{Field: "limit", Message: "must be a positive integer"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	events, err := s.db.ListEvents(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) getEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	event, err := s.db.GetEvent(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, event)
+}
+
+type replayRequest struct {
+	Ids    []int64 `json:"ids"`
+	Failed bool    `json:"failed"`
+}
+
+// replayEventsHandler reprocesses either the listed events or, with
+// {"failed": true}, every event that failed.
+func (s *Server) replayEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var req replayRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Failed == (len(req.Ids) > 0) {
+		writeError(w, models.ValidationError{Field: "body", Message: "set either ids or failed"})
+		return
+	}
+
+	var events []models.Event
+	var err error
+	if req.Failed {
+		events, err = s.webhooks.ReplayFailed()
+	} else {
+		events, err = s.webhooks.Replay(req.Ids)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) listAuditHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.db.ListAuditEntries(100)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"misc/internal/models"
+	"misc/internal/services"
+)
+
+func (s *Server) registerGoalRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/goals", s.listHabitGoalsHandler)
+	mux.HandleFunc("POST /api/goals/import", s.importHabitGoalsHandler)
+	mux.HandleFunc("GET /api/goals/{habitId}", s.getHabitGoalHandler)
+	mux.HandleFunc("PUT /api/goals/{habitId}", s.saveHabitGoalHandler)
+	mux.HandleFunc("DELETE /api/goals/{habitId}", s.deleteHabitGoalHandler)
+}
+
+func (s *Server) listHabitGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	goals, err := s.db.ListHabitGoals()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goals)
+}
+
+func (s *Server) getHabitGoalHandler(w http.ResponseWriter, r *http.Request) {
+	goal, err := s.db.GetHabitGoal(r.PathValue("habitId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goal)
+}
+
+// saveHabitGoalHandler sets the goal of a habit, replacing any it had.
+func (s *Server) saveHabitGoalHandler(w http.ResponseWriter, r *http.Request) {
+	var goal models.HabitGoal
+	if err := decodeJSON(r, &goal); err != nil {
+		writeError(w, err)
+		return
+	}
+	goal.HabitId = r.PathValue("habitId")
+	goal.SetDefaults()
+	if err := goal.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.validateTask("habit_id", goal.HabitId, models.HabiticaHabitType); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.SaveHabitGoal(goal); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goal)
+}
+
+func (s *Server) deleteHabitGoalHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.db.DeleteHabitGoal(r.PathValue("habitId")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importHabitGoalsHandler creates goals from "Goal: N" habit notes and
+// returns the goals it created.
+func (s *Server) importHabitGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	goals, err := services.ImportHabitGoals(s.db, s.habClient)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, goals)
+}
+
+// importHabitGoalsOnStartup carries goals over from habit notes when there
+// are none yet, so metrics and rules that follow goals keep working after
+// upgrading.
+func (s *Server) importHabitGoalsOnStartup() {
+	goals, err := services.ImportHabitGoalsIfEmpty(s.db, s.habClient)
+	if err != nil {
+		slog.Warn("error importing habit goals from notes, run POST /api/goals/import to retry", "err", err)
+		return
+	}
+	if goals != nil {
+		slog.Info("imported habit goals from notes", "goals", len(goals))
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"misc/clients/fitbit"
+	"misc/internal/database"
+	"misc/internal/services"
+)
+
+func (s *Server) registerJobRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/jobs", s.listJobsHandler)
+	mux.HandleFunc("POST /api/jobs/{name}/run", s.runJobHandler)
+}
+
+func (s *Server) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.scheduler.Jobs()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// runJobHandler starts a job now. The job runs in the background; its outcome
+// shows up in GET /api/jobs.
+func (s *Server) runJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !s.scheduler.Trigger(name) {
+		writeError(w, fmt.Errorf("job %s: %w", name, database.ErrNotFound))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// Default job schedules, in the server's local time. Each can be overridden
+// with JOB_SCHEDULE_<NAME>, e.g. JOB_SCHEDULE_TODOIST_RECONCILE.
+var defaultJobSchedules = map[string]string{
+	"hourly":            "0 * * * *",
+	"fitbit-goals":      "*/30 * * * *",
+	"fitbit-token":      "0 */6 * * *",
+	"todoist-reconcile": "30 3 * * *",
+	"todo-sync":         "15 * * * *",
+	"snapshot":          "55 * * * *",
+}
+
+func jobSchedule(name string) string {
+	env := "JOB_SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if schedule := os.Getenv(env); schedule != "" {
+		return schedule
+	}
+	return defaultJobSchedules[name]
+}
+
+// jobDeps are what the background jobs work with. fitbit is nil without a
+// saved token.
+type jobDeps struct {
+	db             database.Service
+	engine         services.AutomationRunner
+	webhooks       *services.WebhookService
+	todoSync       *services.TodoSync
+	todoistService *services.TodoistService
+	fitbit         *fitbit.FitbitClient
+	snapshots      *services.Snapshotter
+	cache          *services.Cache
+	ttls           services.CacheTTLs
+}
+
+// newScheduler registers the background jobs. The Fitbit jobs need a token
+// saved by an earlier interactive login, and reconciliation and the todo sync
+// need a Todoist API key; jobs whose dependencies are missing are left out.
+func newScheduler(deps jobDeps) (*services.Scheduler, error) {
+	scheduler := services.NewScheduler(deps.db)
+	jobs := []services.Job{
+		services.ScheduleJob("hourly", jobSchedule("hourly"), deps.engine),
+		deps.snapshots.Job(jobSchedule("snapshot")),
+	}
+
+	if deps.fitbit == nil {
+		slog.Info("fitbit jobs disabled, no saved fitbit token")
+	} else {
+		jobs = append(jobs,
+			services.FitbitGoalJob(jobSchedule("fitbit-goals"), services.NewCachedFitbit(deps.fitbit, deps.cache, deps.ttls.Fitbit), deps.engine),
+			services.FitbitTokenJob(jobSchedule("fitbit-token"), deps.fitbit),
+		)
+	}
+
+	if os.Getenv("TODOIST_API_KEY") == "" {
+		slog.Info("todoist jobs disabled, TODOIST_API_KEY is not set")
+	} else {
+		reconciler := services.NewTodoistReconciler(deps.todoistService, deps.db, deps.webhooks)
+		jobs = append(jobs,
+			reconciler.Job(jobSchedule("todoist-reconcile")),
+			deps.todoSync.Job(jobSchedule("todo-sync")),
+		)
+	}
+
+	for _, job := range jobs {
+		if err := scheduler.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"misc/internal/models"
+)
+
+// maxWebhookBody caps how much of a webhook body is read for verification.
+const maxWebhookBody = 1 << 20
+
+// verifyTodoistSignature rejects requests whose X-Todoist-Hmac-SHA256 header
+// isn't the base64 HMAC-SHA256 of the body keyed with the app client secret.
+func (s *Server) verifyTodoistSignature(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.todoistSecret == "" {
+			s.rejectWebhook(w, r, models.TodoistEventSource, "TODOIST_CLIENT_SECRET is not configured")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			s.rejectWebhook(w, r, models.TodoistEventSource, "unable to read body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		signature, err := base64.StdEncoding.DecodeString(r.Header.Get("X-Todoist-Hmac-SHA256"))
+		if err != nil || len(signature) == 0 {
+			s.rejectWebhook(w, r, models.TodoistEventSource, "missing or malformed signature")
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(s.todoistSecret))
+		mac.Write(body)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			s.rejectWebhook(w, r, models.TodoistEventSource, "signature mismatch")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// verifyHabiticaToken rejects requests that don't carry the shared webhook
+// secret, either as a ?token= query parameter on the webhook URL registered
+// with Habitica or in an X-Webhook-Token header.
+func (s *Server) verifyHabiticaToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.habiticaSecret == "" {
+			s.rejectWebhook(w, r, models.HabiticaEventSource, "HABITICA_WEBHOOK_SECRET is not configured")
+			return
+		}
+
+		token := r.Header.Get("X-Webhook-Token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.habiticaSecret)) != 1 {
+			s.rejectWebhook(w, r, models.HabiticaEventSource, "missing or invalid token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rejectWebhook answers 401 and records the attempt in the audit log.
+func (s *Server) rejectWebhook(w http.ResponseWriter, r *http.Request, source, reason string) {
+	entry := models.AuditEntry{
+		Action:     models.AuditWebhookRejected,
+		Source:     source,
+		RemoteAddr: s.remoteAddr(r),
+		Path:       r.URL.Path,
+		Reason:     reason,
+	}
+	slog.Warn("rejected webhook", "source", source, "remoteAddr", entry.RemoteAddr, "reason", reason)
+	if err := s.db.RecordAudit(entry); err != nil {
+		slog.Error("error recording audit entry", "err", err)
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// TrustedProxies are the reverse proxies whose X-Forwarded-For header is
+// believed, as addresses or CIDR prefixes.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma separated list of addresses and CIDR
+// prefixes, as in TRUSTED_PROXIES.
+func ParseTrustedProxies(list string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) trusts(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddr is the address of the client that made the request. The
+// X-Forwarded-For header is only believed when the request came through a
+// trusted proxy, and then only as far back as the proxies are trusted: the
+// client is the rightmost address that isn't one of them.
+func (p TrustedProxies) ClientAddr(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !p.trusts(peer) {
+		return r.RemoteAddr
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := r.RemoteAddr
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		client = addr
+		if !p.trusts(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// remoteAddr is the client's address for the audit log.
+func (s *Server) remoteAddr(r *http.Request) string {
+	return s.proxies.ClientAddr(r)
+}
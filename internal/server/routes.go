@@ -9,12 +9,13 @@ import (
 
 	"misc/cmd/web"
 	"misc/internal/models"
-
-	"github.com/a-h/templ"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
+	return s.requireAuth(s.routes())
+}
 
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.HelloWorldHandler)
 
@@ -22,11 +23,22 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	fileServer := http.FileServer(http.FS(web.Files))
 	mux.Handle("/assets/", fileServer)
-	mux.Handle("/web", templ.Handler(web.HelloForm()))
-	mux.HandleFunc("/hello", web.HelloWebHandler)
-	mux.HandleFunc("POST /habiticaEvent", s.HabiticaWebhookHandler)
-	mux.HandleFunc("POST /todoistEvent", s.TodoistWebhookHandler)
-	mux.HandleFunc("GET /widget", s.WidgetHandler)
+	mux.HandleFunc("POST /habiticaEvent", s.verifyHabiticaToken(s.HabiticaWebhookHandler))
+	mux.HandleFunc("POST /todoistEvent", s.verifyTodoistSignature(s.TodoistWebhookHandler))
+	s.registerWidgetRoutes(mux)
+	s.registerRuleRoutes(mux)
+	s.registerUIRoutes(mux)
+	s.registerDashboardRoutes(mux)
+	s.registerActivityRoutes(mux)
+	s.registerChartRoutes(mux)
+	s.registerEventRoutes(mux)
+	s.registerAutomationRoutes(mux)
+	s.registerJobRoutes(mux)
+	s.registerTodoSyncRoutes(mux)
+	s.registerGoalRoutes(mux)
+	s.registerSnapshotRoutes(mux)
+	s.registerStatsRoutes(mux)
+	s.registerAuthRoutes(mux)
 
 	return mux
 }
@@ -46,50 +58,32 @@ func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) HabiticaWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.HabiticaWebhook
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		slog.Error("error decoding request", "err", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if req.Task.Type != models.HabiticaHabitType {
-		slog.Info("got non habit task", "event", req)
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	slog.Info("checking habit", "id", req.Task.Id, "name", req.Task.Text)
-	err = s.habService.CheckMinHabit(req.Task.Id, req.Task.Up)
-	if err != nil {
-		slog.Error("error checking habit", "err", err)
-	}
+	// Habitica has no delivery id, retries are recognised from the payload
+	s.receiveWebhook(w, r, models.HabiticaEventSource, "")
 }
 
 func (s *Server) TodoistWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	var req models.TodoistWebhook
-	err := json.NewDecoder(r.Body).Decode(&req)
+	s.receiveWebhook(w, r, models.TodoistEventSource, r.Header.Get("X-Todoist-Delivery-ID"))
+}
+
+// receiveWebhook journals and processes a webhook. Once the event is journaled
+// the delivery is acknowledged even if processing failed, since failed events
+// can be replayed from the journal.
+func (s *Server) receiveWebhook(w http.ResponseWriter, r *http.Request, source, deliveryId string) {
+	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		slog.Error("error decoding request", "err", err)
+		slog.Error("error reading request", "source", source, "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	slog.Info("got todoist event", "taskName", req.EventData.Content)
-
-	err = s.todoHabService.ScoreTask(req.EventData.Content, req.EventData.ProjectId)
 
+	event, err := s.webhooks.Receive(source, deliveryId, payload)
 	if err != nil {
-		slog.Error("error scoring task", "err", err)
+		slog.Error("error receiving webhook", "source", source, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-}
-
-func (s *Server) WidgetHandler(w http.ResponseWriter, r *http.Request) {
-	resp := s.widgetService.GetWidgetResponse()
-	if len(resp.Errors) > 0 {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-
-	json.NewEncoder(w).Encode(resp)
+	slog.Info("processed webhook", "id", event.Id, "source", source, "type", event.EventType, "status", event.Status)
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
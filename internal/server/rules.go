@@ -0,0 +1,391 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"misc/clients/habitica"
+	"misc/internal/database"
+	"misc/internal/models"
+)
+
+// HabiticaTaskReader looks up Habitica tasks so rules can't reference task ids
+// that don't exist, and lists them for the rule pickers.
+type HabiticaTaskReader interface {
+	GetTask(string) (habitica.Task, error)
+	GetHabits() ([]habitica.Habit, error)
+	GetDailys() ([]habitica.Daily, error)
+}
+
+func (s *Server) registerRuleRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/rules/habits", s.listHabitRulesHandler)
+	mux.HandleFunc("POST /api/rules/habits", s.createHabitRuleHandler)
+	mux.HandleFunc("GET /api/rules/habits/{id}", s.getHabitRuleHandler)
+	mux.HandleFunc("PUT /api/rules/habits/{id}", s.updateHabitRuleHandler)
+	mux.HandleFunc("DELETE /api/rules/habits/{id}", s.deleteHabitRuleHandler)
+
+	mux.HandleFunc("GET /api/rules/text", s.listTextRulesHandler)
+	mux.HandleFunc("POST /api/rules/text", s.createTextRuleHandler)
+	mux.HandleFunc("GET /api/rules/text/{id}", s.getTextRuleHandler)
+	mux.HandleFunc("PUT /api/rules/text/{id}", s.updateTextRuleHandler)
+	mux.HandleFunc("DELETE /api/rules/text/{id}", s.deleteTextRuleHandler)
+
+	mux.HandleFunc("GET /api/rules/projects", s.listProjectRulesHandler)
+	mux.HandleFunc("POST /api/rules/projects", s.createProjectRuleHandler)
+	mux.HandleFunc("GET /api/rules/projects/{id}", s.getProjectRuleHandler)
+	mux.HandleFunc("PUT /api/rules/projects/{id}", s.updateProjectRuleHandler)
+	mux.HandleFunc("DELETE /api/rules/projects/{id}", s.deleteProjectRuleHandler)
+
+	mux.HandleFunc("POST /api/rules/simulate", s.simulateRulesHandler)
+}
+
+func (s *Server) listHabitRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.db.ListHabitRules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) getHabitRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.GetHabitRuleById(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) createHabitRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule models.HabiticaHabitRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.SetDefaults()
+	if err := s.checkHabitRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.CreateHabitRule(rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) updateHabitRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var rule models.HabiticaHabitRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.Id = id
+	rule.SetDefaults()
+	if err := s.checkHabitRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.UpdateHabitRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) deleteHabitRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteHabitRule(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listTextRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.db.GetTodoistHabiticaTextRules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) getTextRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.GetTodoistHabiticaTextRuleById(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) createTextRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule models.TodoistHabiticaTextRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.SetDefaults()
+	if err := s.checkTextRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.CreateTodoistHabiticaTextRule(rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) updateTextRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var rule models.TodoistHabiticaTextRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.Id = id
+	rule.SetDefaults()
+	if err := s.checkTextRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.UpdateTodoistHabiticaTextRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) deleteTextRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteTodoistHabiticaTextRule(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listProjectRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.db.ListTodoistHabiticaProjectRules()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) getProjectRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.GetTodoistHabiticaProjectRuleById(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) createProjectRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule models.TodoistHabiticaProjectRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.checkProjectRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule, err := s.db.CreateTodoistHabiticaProjectRule(rule)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) updateProjectRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var rule models.TodoistHabiticaProjectRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	rule.Id = id
+	if err := s.checkProjectRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.UpdateTodoistHabiticaProjectRule(rule); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) deleteProjectRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteTodoistHabiticaProjectRule(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type simulateRequest struct {
+	Source  string          `json:"source"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// simulateRulesHandler reports which rules a sample webhook payload would
+// match and the Habitica calls it would make, without making them.
+func (s *Server) simulateRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(req.Payload) == 0 {
+		writeError(w, models.ValidationError{Field: "payload", Message: "is required"})
+		return
+	}
+	sim, err := s.simulator.Simulate(req.Source, req.Payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sim)
+}
+
+// checkHabitRule validates the rule and the Habitica tasks it references.
+func (s *Server) checkHabitRule(rule models.HabiticaHabitRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := s.validateTask("habit_id", rule.HabitId, models.HabiticaHabitType); err != nil {
+		return err
+	}
+	return s.validateTask("daily_id", rule.DailyId, models.HabiticaDailyType)
+}
+
+func (s *Server) checkTextRule(rule models.TodoistHabiticaTextRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	return s.validateTask("habit_id", rule.HabitId, "")
+}
+
+func (s *Server) checkProjectRule(rule models.TodoistHabiticaProjectRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	return s.validateTask("habit_id", rule.HabitId, "")
+}
+
+// validateTask checks that taskId exists in Habitica and, when taskType is set,
+// that it is a task of that type.
+func (s *Server) validateTask(field, taskId, taskType string) error {
+	if taskId == "" {
+		return models.ValidationError{Field: field, Message: "is required"}
+	}
+	task, err := s.habClient.GetTask(taskId)
+	if errors.Is(err, habitica.ErrNotFound) {
+		return models.ValidationError{Field: field, Message: "no habitica task with this id"}
+	}
+	if err != nil {
+		return fmt.Errorf("error looking up habitica task: %w", err)
+	}
+	if taskType != "" && task.Type != taskType {
+		return models.ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("habitica task %q is a %s, not a %s", task.Text, task.Type, taskType),
+		}
+	}
+	return nil
+}
+
+func pathId(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, models.ValidationError{Field: "id", Message: "must be an integer"}
+	}
+	return id, nil
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return models.ValidationError{Field: "body", Message: err.Error()}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error encoding response", "err", err)
+	}
+}
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var validationErr models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, database.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, database.ErrDuplicate):
+		status = http.StatusConflict
+	default:
+		slog.Error("error handling request", "err", err)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
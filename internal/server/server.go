@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -9,6 +12,7 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 
+	"misc/clients/fitbit"
 	"misc/clients/habitica"
 	"misc/clients/todoist"
 	"misc/internal/database"
@@ -17,15 +21,29 @@ import (
 )
 
 type WidgetService interface {
-	GetWidgetResponse() models.WidgetResponse
+	GetWidgetResponse(context.Context, string) (models.WidgetResponse, error)
 }
 type Server struct {
 	port int
 
-	db             database.Service
-	habService     services.HabiticaMinHabitService
-	todoHabService services.TodoistHabiticaService
-	widgetService  WidgetService
+	db              database.Service
+	habClient       HabiticaTaskReader
+	todoistProjects TodoistProjectLister
+	widgetService   WidgetService
+	webhooks        *services.WebhookService
+	simulator       *services.Simulator
+	scheduler       *services.Scheduler
+	cache           *services.Cache
+	snapshots       *services.Snapshotter
+	stats           *services.StatsService
+	dashboard       DashboardService
+	activity        *services.ActivityHub
+	charts          ChartService
+	auth            *services.AuthService
+	proxies         TrustedProxies
+
+	todoistSecret  string
+	habiticaSecret string
 }
 
 func NewServer() *http.Server {
@@ -34,6 +52,18 @@ func NewServer() *http.Server {
 		port: port,
 
 		db: database.New(),
+
+		todoistSecret:  os.Getenv("TODOIST_CLIENT_SECRET"),
+		habiticaSecret: os.Getenv("HABITICA_WEBHOOK_SECRET"),
+	}
+	proxies, err := ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	NewServer.proxies = proxies
+	NewServer.auth = services.NewAuthService(NewServer.db, os.Getenv("UI_PASSWORD"))
+	if !NewServer.auth.LoginEnabled() {
+		slog.Warn("web UI login disabled, UI_PASSWORD is not set")
 	}
 	habClient := habitica.NewHabiticaClient(
 		os.Getenv("HABITICA_API_USER"),
@@ -43,18 +73,65 @@ func NewServer() *http.Server {
 	todoistRestClient := todoist.NewClient(os.Getenv("TODOIST_API_KEY"))
 	todoistSyncClient := todoist.NewSyncClient(os.Getenv("TODOIST_API_KEY"))
 
+	NewServer.habClient = &habClient
+	go NewServer.importHabitGoalsOnStartup()
+
 	todoistService := services.NewTodoistService(todoistRestClient, todoistSyncClient)
-	NewServer.habService = services.NewHabitcaMinHabitService(
-		NewServer.db,
-		&habClient,
-	)
+	NewServer.cache = services.NewCache()
+	ttls := cacheTTLs()
+	NewServer.activity = services.NewActivityHub()
+	// writes made by the automations and the dashboard are published
+	habWriter := services.NewPublishingHabitica(&habClient, NewServer.activity)
+	todoistWriter := services.NewPublishingTodoist(&todoistService, NewServer.activity)
+	engine, webhooks, todoSync := newAutomations(NewServer.db, habWriter, todoistWriter, NewServer.cache, NewServer.activity)
+	NewServer.webhooks = webhooks
+	NewServer.simulator = services.NewSimulator(NewServer.db)
+	NewServer.stats = services.NewStatsService(NewServer.db)
+
+	cachedHabitica := services.NewCachedHabitica(&habClient, NewServer.cache, ttls.Habitica)
+	cachedTodoist := services.NewCachedTodoist(&todoistService, NewServer.cache, ttls.Todoist)
+	NewServer.widgetService = services.NewWidgetService(NewServer.db, cachedHabitica, cachedTodoist)
+	NewServer.todoistProjects = &todoistService
 
-	NewServer.todoHabService = services.NewTodoistHabiticaService(
+	var fitClient *fitbit.FitbitClient
+	if client, err := fitbit.NewSavedTokenClient(); err != nil {
+		slog.Info("fitbit disabled", "err", err)
+	} else {
+		fitClient = &client
+	}
+	// a nil *FitbitClient in the interface wouldn't read as nil
+	var fitReader services.FitbitReader
+	if fitClient != nil {
+		fitReader = services.NewCachedFitbit(fitClient, NewServer.cache, ttls.Fitbit)
+	}
+	NewServer.dashboard = services.NewDashboardService(
 		NewServer.db,
-		&habClient,
+		cachedHabitica,
+		cachedTodoist,
+		fitReader,
+		NewServer.cache,
+		habWriter,
+		todoistWriter,
 	)
+	NewServer.charts = services.NewChartService(NewServer.db, cachedHabitica, fitReader, waterHabit())
+	NewServer.snapshots = newSnapshotter(NewServer.db, cachedHabitica, &todoistService, fitClient, NewServer.widgetService)
 
-	NewServer.widgetService = services.NewWidgetService(&habClient, &todoistService)
+	scheduler, err := newScheduler(jobDeps{
+		db:             NewServer.db,
+		engine:         engine,
+		webhooks:       webhooks,
+		todoSync:       todoSync,
+		todoistService: &todoistService,
+		fitbit:         fitClient,
+		snapshots:      NewServer.snapshots,
+		cache:          NewServer.cache,
+		ttls:           ttls,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler.Start(context.Background())
+	NewServer.scheduler = scheduler
 
 	// Declare Server config
 	server := &http.Server{
@@ -67,3 +144,36 @@ func NewServer() *http.Server {
 
 	return server
 }
+
+// NewWebhookService wires the event journal to the automation services. It is
+// shared by the server and the replay command.
+func NewWebhookService(
+	db database.Service,
+	habClient *habitica.HabiticaClient,
+	todoistService *services.TodoistService,
+) *services.WebhookService {
+	_, webhooks, _ := newAutomations(db, habClient, todoistService, nil, nil)
+	return webhooks
+}
+
+// newAutomations builds the automation engine and the Todoist and Habitica
+// event handlers, including the todo sync, behind a WebhookService that
+// invalidates cache, if set, on each event. Webhooks and the automations they
+// run are published to activity, if set. Notifications are posted to
+// NOTIFY_WEBHOOK_URL when it is set.
+func newAutomations(
+	db database.Service,
+	habWriter services.HabiticaTodoWriter,
+	todoistWriter services.TodoistTaskWriteSyncer,
+	cache services.CacheInvalidator,
+	activity services.ActivityPublisher,
+) (*services.Engine, *services.WebhookService, *services.TodoSync) {
+	engine, todoistEvents, habiticaEvents := services.NewAutomations(db, services.Actuators{
+		Habitica: habWriter,
+		Todoist:  todoistWriter,
+		Notifier: services.NewNotifier(os.Getenv("NOTIFY_WEBHOOK_URL")),
+	}, activity)
+	todoSync := services.NewTodoSync(db, habWriter, todoistWriter)
+	todoSync.Register(todoistEvents, habiticaEvents)
+	return engine, services.NewWebhookService(db, db, engine, todoistEvents, habiticaEvents, cache, activity), todoSync
+}
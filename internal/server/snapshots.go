@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"misc/clients/fitbit"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/database"
+	"misc/internal/models"
+	"misc/internal/services"
+)
+
+func (s *Server) registerSnapshotRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/snapshots", s.listSnapshotsHandler)
+	mux.HandleFunc("POST /api/snapshots/backfill", s.backfillSnapshotsHandler)
+}
+
+// NewSnapshotter builds a Snapshotter reading the APIs directly, for the
+// snapshots command.
+func NewSnapshotter(db database.Service) *services.Snapshotter {
+	habClient := habitica.NewHabiticaClient(
+		os.Getenv("HABITICA_API_USER"),
+		os.Getenv("HABITICA_API_KEY"),
+	)
+	todoistService := services.NewTodoistService(
+		todoist.NewClient(os.Getenv("TODOIST_API_KEY")),
+		todoist.NewSyncClient(os.Getenv("TODOIST_API_KEY")),
+	)
+	var fitClient *fitbit.FitbitClient
+	if client, err := fitbit.NewSavedTokenClient(); err == nil {
+		fitClient = &client
+	}
+	widget := services.NewWidgetService(db, &habClient, &todoistService)
+	return newSnapshotter(db, &habClient, &todoistService, fitClient, widget)
+}
+
+// newSnapshotter records snapshots from the APIs that are set up: Todoist
+// needs TODOIST_API_KEY and Fitbit a saved token.
+func newSnapshotter(
+	db database.Service,
+	habitica services.HabiticaTaskRepository,
+	todoistService *services.TodoistService,
+	fitClient *fitbit.FitbitClient,
+	widget services.WidgetReader,
+) *services.Snapshotter {
+	var todoistHistory services.TodoistHistoryReader
+	if os.Getenv("TODOIST_API_KEY") != "" {
+		todoistHistory = todoistService
+	}
+	var fitbitHistory services.FitbitHistoryReader
+	if fitClient != nil {
+		fitbitHistory = fitClient
+	}
+	return services.NewSnapshotter(db, habitica, todoistHistory, fitbitHistory, widget)
+}
+
+// listSnapshotsHandler lists snapshots, filtered by ?metric=, ?subject= and
+// the inclusive dates ?from= and ?to=.
+func (s *Server) listSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.SnapshotFilter{
+		Metric:  q.Get("metric"),
+		Subject: q.Get("subject"),
+		From:    q.Get("from"),
+		To:      q.Get("to"),
+	}
+	for field, value := range map[string]string{"from": filter.From, "to": filter.To} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseDate(field, value); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	snapshots, err := s.db.ListSnapshots(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+// backfillSnapshotsHandler fills in the days from ?from= to ?to= that have no
+// snapshot from the APIs' history. to defaults to yesterday.
+// maxBackfillDays caps a backfill over the API, which runs within the
+// request's write timeout and makes 4 Fitbit calls per 30 days against a limit
+// of about 150 an hour. Longer ranges can be filled in steps, or with `api
+// snapshots backfill`.
+const maxBackfillDays = 90
+
+func (s *Server) backfillSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err := parseDate("from", q.Get("from"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	year, month, day := time.Now().Date()
+	yesterday := time.Date(year, month, day-1, 0, 0, 0, 0, time.Local)
+	to := yesterday
+	if q.Get("to") != "" {
+		if to, err = parseDate("to", q.Get("to")); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if to.After(yesterday) {
+		to = yesterday
+	}
+	if to.After(from.AddDate(0, 0, maxBackfillDays-1)) {
+		writeError(w, models.ValidationError{
+			Field:   "from",
+			Message: fmt.Sprintf("must be at most %d days before to", maxBackfillDays),
+		})
+		return
+	}
+
+	filled, err := s.snapshots.Backfill(r.Context(), from, to)
+	if err != nil {
+		// what was filled is kept, and a retry fills the rest
+		writeJSON(w, http.StatusBadGateway, map[string]any{"filled": filled, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"filled": filled})
+}
+
+func parseDate(field, value string) (time.Time, error) {
+	t, err := time.ParseInLocation(models.SnapshotDateFormat, value, time.Local)
+	if err != nil {
+		return t, models.ValidationError{Field: field, Message: fmt.Sprintf("must be a date like %s", models.SnapshotDateFormat)}
+	}
+	return t, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"misc/internal/models"
+)
+
+func (s *Server) registerStatsRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/stats", s.statsSummaryHandler)
+	mux.HandleFunc("GET /api/stats/{metric}", s.metricStatsHandler)
+}
+
+// statsSummaryHandler returns the stats of every metric and subject over the
+// last ?days= days, 30 by default.
+func (s *Server) statsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	days, err := intParam(r, "days", 30, 1, 3650)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	stats, err := s.stats.Summary(days)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// metricStatsHandler returns the stats of one metric and ?subject= over the
+// last ?days= days, with a rolling average over ?window= days, 7 by default.
+func (s *Server) metricStatsHandler(w http.ResponseWriter, r *http.Request) {
+	days, err := intParam(r, "days", 30, 1, 3650)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	window, err := intParam(r, "window", 7, 1, 365)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	stats, err := s.stats.Metric(r.PathValue("metric"), r.URL.Query().Get("subject"), days, window)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// intParam reads an integer query parameter from lo to hi, or def when it
+// isn't set.
+func intParam(r *http.Request, name string, def, lo, hi int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return 0, models.ValidationError{Field: name, Message: fmt.Sprintf("must be a number from %d to %d", lo, hi)}
+	}
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+
+	"misc/internal/models"
+)
+
+func (s *Server) registerTodoSyncRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/sync/projects", s.listSyncProjectsHandler)
+	mux.HandleFunc("PUT /api/sync/projects/{id}", s.addSyncProjectHandler)
+	mux.HandleFunc("DELETE /api/sync/projects/{id}", s.removeSyncProjectHandler)
+	mux.HandleFunc("GET /api/sync/links", s.listTodoLinksHandler)
+}
+
+func (s *Server) listSyncProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.db.ListTodoSyncProjects()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// addSyncProjectHandler selects a Todoist project for syncing and starts the
+// todo-sync job to mirror the tasks already in it.
+func (s *Server) addSyncProjectHandler(w http.ResponseWriter, r *http.Request) {
+	projectId := r.PathValue("id")
+	if err := s.checkTodoistProject(projectId); err != nil {
+		writeError(w, err)
+		return
+	}
+	project, err := s.db.AddTodoSyncProject(projectId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.scheduler.Trigger("todo-sync")
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (s *Server) removeSyncProjectHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.db.RemoveTodoSyncProject(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listTodoLinksHandler(w http.ResponseWriter, r *http.Request) {
+	links, err := s.db.ListTodoLinks()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+// checkTodoistProject makes sure a project exists in Todoist.
+func (s *Server) checkTodoistProject(projectId string) error {
+	projects, err := s.todoistProjects.GetProjects()
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if p.ID == projectId {
+			return nil
+		}
+	}
+	return models.ValidationError{Field: "id", Message: "no todoist project with this id"}
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"misc/clients/todoist"
+	"misc/cmd/web"
+	"misc/internal/database"
+	"misc/internal/models"
+
+	"github.com/a-h/templ"
+)
+
+// TodoistProjectLister lists Todoist projects for the project rule picker.
+type TodoistProjectLister interface {
+	GetProjects() ([]todoist.Project, error)
+}
+
+func (s *Server) registerUIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /rules", s.rulesPageHandler)
+	mux.HandleFunc("GET /rules/{kind}/new", s.newRulePageHandler)
+	mux.HandleFunc("GET /rules/{kind}/{id}/edit", s.editRulePageHandler)
+	mux.HandleFunc("GET /rules/{kind}/options", s.ruleOptionsHandler)
+	mux.HandleFunc("POST /rules/{kind}", s.submitRuleFormHandler)
+	mux.HandleFunc("POST /rules/{kind}/{id}", s.submitRuleFormHandler)
+	mux.HandleFunc("POST /rules/{kind}/validate", s.validateRuleFormHandler)
+	mux.HandleFunc("DELETE /rules/{kind}/{id}", s.deleteRuleFormHandler)
+}
+
+func (s *Server) rulesPageHandler(w http.ResponseWriter, r *http.Request) {
+	habitRules, err := s.db.ListHabitRules()
+	if err != nil {
+		slog.Error("error listing habit rules", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	textRules, err := s.db.GetTodoistHabiticaTextRules()
+	if err != nil {
+		slog.Error("error listing text rules", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projectRules, err := s.db.ListTodoistHabiticaProjectRules()
+	if err != nil {
+		slog.Error("error listing project rules", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	render(w, r, web.RulesPage(habitRules, textRules, projectRules))
+}
+
+func (s *Server) newRulePageHandler(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	if !validRuleKind(kind) {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, r, web.RuleFormPage(web.RuleForm{Kind: kind, Values: map[string]string{}}))
+}
+
+func (s *Server) editRulePageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	form := web.RuleForm{Kind: r.PathValue("kind"), Id: id}
+	switch form.Kind {
+	case web.HabitRuleKind:
+		var rule models.HabiticaHabitRule
+		rule, err = s.db.GetHabitRuleById(id)
+		form.Values = map[string]string{
+			"name":     rule.Name,
+			"habit_id": rule.HabitId,
+			"daily_id": rule.DailyId,
+			"counter":  rule.Counter,
+		}
+		if rule.MinScore != 0 {
+			form.Values["min_score"] = strconv.Itoa(rule.MinScore)
+		}
+	case web.TextRuleKind:
+		var rule models.TodoistHabiticaTextRule
+		rule, err = s.db.GetTodoistHabiticaTextRuleById(id)
+		form.Values = map[string]string{
+			"name":       rule.Name,
+			"rule":       rule.Rule,
+			"match_type": rule.MatchType,
+			"labels":     strings.Join(rule.Labels, ", "),
+			"section_id": rule.SectionId,
+			"operator":   rule.Operator,
+			"priority":   strconv.Itoa(rule.Priority),
+			"habit_id":   rule.HabitId,
+		}
+		if rule.Continue {
+			form.Values["continue"] = "on"
+		}
+	case web.ProjectRuleKind:
+		var rule models.TodoistHabiticaProjectRule
+		rule, err = s.db.GetTodoistHabiticaProjectRuleById(id)
+		form.Values = map[string]string{
+			"name":       rule.Name,
+			"project_id": rule.ProjectId,
+			"habit_id":   rule.HabitId,
+		}
+	default:
+		err = database.ErrNotFound
+	}
+
+	if errors.Is(err, database.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("error loading rule", "kind", form.Kind, "id", id, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	render(w, r, web.RuleFormPage(form))
+}
+
+// ruleOptionsHandler renders a picker select filled live from Habitica or
+// Todoist.
+func (s *Server) ruleOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	name := q.Get("name")
+	selected := q.Get("selected")
+
+	options, err := s.ruleOptions(q.Get("source"))
+	if err != nil {
+		slog.Error("error loading rule options", "source", q.Get("source"), "err", err)
+		options = nil
+		if selected != "" {
+			options = []web.Option{{Value: selected, Label: selected + " (unable to load tasks)"}}
+		}
+	}
+	render(w, r, web.OptionsSelect(name, options, selected, web.ValidateURL(r.PathValue("kind"))))
+}
+
+func (s *Server) ruleOptions(source string) ([]web.Option, error) {
+	var options []web.Option
+	switch source {
+	case web.HabitOptions, web.DailyOptions, web.TaskOptions:
+		if source != web.DailyOptions {
+			habits, err := s.habClient.GetHabits()
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range habits {
+				options = append(options, web.Option{Value: h.ID, Label: "Habit: " + h.Text})
+			}
+		}
+		if source != web.HabitOptions {
+			dailys, err := s.habClient.GetDailys()
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range dailys {
+				options = append(options, web.Option{Value: d.ID, Label: "Daily: " + d.Text})
+			}
+		}
+	case web.ProjectOptions:
+		projects, err := s.todoistProjects.GetProjects()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			if !p.IsArchived {
+				options = append(options, web.Option{Value: p.ID, Label: p.Name})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown option source %q", source)
+	}
+	return options, nil
+}
+
+func (s *Server) submitRuleFormHandler(w http.ResponseWriter, r *http.Request) {
+	form := web.RuleForm{Kind: r.PathValue("kind")}
+	if !validRuleKind(form.Kind) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.PathValue("id") != "" {
+		id, err := pathId(r)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		form.Id = id
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	form.Values = formValues(r.PostForm)
+
+	if err := s.applyRuleForm(form, true); err != nil {
+		form.Error = ruleFormError(err)
+		render(w, r, web.RuleFormFragment(form))
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		w.Header().Set("HX-Redirect", "/rules")
+		return
+	}
+	http.Redirect(w, r, "/rules", http.StatusSeeOther)
+}
+
+// validateRuleFormHandler runs the same checks as a save and renders only the
+// error summary, for inline validation while the form is being filled in.
+func (s *Server) validateRuleFormHandler(w http.ResponseWriter, r *http.Request) {
+	form := web.RuleForm{Kind: r.PathValue("kind")}
+	if !validRuleKind(form.Kind) {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	form.Values = formValues(r.PostForm)
+
+	message := ""
+	if err := s.applyRuleForm(form, false); err != nil {
+		message = ruleFormError(err)
+	}
+	render(w, r, web.RuleErrors(message))
+}
+
+func (s *Server) deleteRuleFormHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil || !validRuleKind(r.PathValue("kind")) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.PathValue("kind") {
+	case web.HabitRuleKind:
+		err = s.db.DeleteHabitRule(id)
+	case web.TextRuleKind:
+		err = s.db.DeleteTodoistHabiticaTextRule(id)
+	case web.ProjectRuleKind:
+		err = s.db.DeleteTodoistHabiticaProjectRule(id)
+	}
+
+	// a rule that is already gone should still disappear from the table
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		slog.Error("error deleting rule", "kind", r.PathValue("kind"), "id", id, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// applyRuleForm validates the submitted form, including the Habitica tasks it
+// references, and when save is set creates or updates the rule.
+func (s *Server) applyRuleForm(form web.RuleForm, save bool) error {
+	v := form.Values
+	switch form.Kind {
+	case web.HabitRuleKind:
+		// a blank min score follows the habit's goal
+		var minScore int
+		var err error
+		if v["min_score"] != "" {
+			if minScore, err = strconv.Atoi(v["min_score"]); err != nil {
+				return models.ValidationError{Field: "min_score", Message: "must be a number"}
+			}
+		}
+		rule := models.HabiticaHabitRule{
+			Id:       form.Id,
+			Name:     v["name"],
+			HabitId:  v["habit_id"],
+			DailyId:  v["daily_id"],
+			MinScore: minScore,
+			Counter:  v["counter"],
+		}
+		rule.SetDefaults()
+		if err := s.checkHabitRule(rule); err != nil || !save {
+			return err
+		}
+		if form.Id == 0 {
+			_, err = s.db.CreateHabitRule(rule)
+			return err
+		}
+		return s.db.UpdateHabitRule(rule)
+	case web.TextRuleKind:
+		priority := 0
+		if v["priority"] != "" {
+			var err error
+			if priority, err = strconv.Atoi(v["priority"]); err != nil {
+				return models.ValidationError{Field: "priority", Message: "must be a number"}
+			}
+		}
+		rule := models.TodoistHabiticaTextRule{
+			Id:        form.Id,
+			Name:      v["name"],
+			Rule:      v["rule"],
+			MatchType: v["match_type"],
+			Labels:    splitLabels(v["labels"]),
+			SectionId: v["section_id"],
+			Operator:  v["operator"],
+			Priority:  priority,
+			Continue:  v["continue"] != "",
+			HabitId:   v["habit_id"],
+		}
+		rule.SetDefaults()
+		if err := s.checkTextRule(rule); err != nil || !save {
+			return err
+		}
+		if form.Id == 0 {
+			_, err := s.db.CreateTodoistHabiticaTextRule(rule)
+			return err
+		}
+		return s.db.UpdateTodoistHabiticaTextRule(rule)
+	case web.ProjectRuleKind:
+		rule := models.TodoistHabiticaProjectRule{
+			Id:        form.Id,
+			Name:      v["name"],
+			ProjectId: v["project_id"],
+			HabitId:   v["habit_id"],
+		}
+		if err := s.checkProjectRule(rule); err != nil || !save {
+			return err
+		}
+		if form.Id == 0 {
+			_, err := s.db.CreateTodoistHabiticaProjectRule(rule)
+			return err
+		}
+		return s.db.UpdateTodoistHabiticaProjectRule(rule)
+	}
+	return database.ErrNotFound
+}
+
+func ruleFormError(err error) string {
+	var validationErr models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return validationErr.Error()
+	case errors.Is(err, database.ErrDuplicate):
+		return "a rule for this task or project already exists"
+	case errors.Is(err, database.ErrNotFound):
+		return "this rule no longer exists"
+	}
+	slog.Error("error saving rule", "err", err)
+	return "unable to save rule: " + err.Error()
+}
+
+func validRuleKind(kind string) bool {
+	switch kind {
+	case web.HabitRuleKind, web.TextRuleKind, web.ProjectRuleKind:
+		return true
+	}
+	return false
+}
+
+// splitLabels parses the comma separated labels field of the text rule form.
+func splitLabels(field string) []string {
+	labels := make([]string, 0)
+	for _, label := range strings.Split(field, ",") {
+		label = strings.TrimPrefix(strings.TrimSpace(label), "@")
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func formValues(form url.Values) map[string]string {
+	values := make(map[string]string, len(form))
+	for k := range form {
+		values[k] = form.Get(k)
+	}
+	return values
+}
+
+func render(w http.ResponseWriter, r *http.Request, c templ.Component) {
+	if err := c.Render(r.Context(), w); err != nil {
+		slog.Error("error rendering component", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
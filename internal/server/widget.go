@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"misc/internal/models"
+)
+
+func (s *Server) registerWidgetRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /widget", s.WidgetHandler)
+	mux.HandleFunc("GET /api/widget/metrics", s.listWidgetMetricsHandler)
+	mux.HandleFunc("POST /api/widget/metrics", s.createWidgetMetricHandler)
+	mux.HandleFunc("GET /api/widget/metrics/{id}", s.getWidgetMetricHandler)
+	mux.HandleFunc("PUT /api/widget/metrics/{id}", s.updateWidgetMetricHandler)
+	mux.HandleFunc("DELETE /api/widget/metrics/{id}", s.deleteWidgetMetricHandler)
+}
+
+// WidgetHandler serves the metrics of the widget named by ?widget=, or of the
+// default widget, along with the status of each source they read from.
+func (s *Server) WidgetHandler(w http.ResponseWriter, r *http.Request) {
+	widget := r.URL.Query().Get("widget")
+	if widget == "" {
+		widget = models.DefaultWidget
+	}
+	resp, err := s.widgetService.GetWidgetResponse(r.Context(), widget)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, widgetStatus(resp), resp)
+}
+
+// widgetStatus is 200 as long as there is something to show, partial data
+// included, and 502 when the upstream APIs left every metric out.
+func widgetStatus(resp models.WidgetResponse) int {
+	if len(resp.Metrics) > 0 {
+		return http.StatusOK
+	}
+	for _, source := range resp.Sources {
+		if source.Status != models.WidgetSourceOK {
+			return http.StatusBadGateway
+		}
+	}
+	return http.StatusOK
+}
+
+func (s *Server) listWidgetMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	metrics, err := s.db.ListWidgetMetrics(r.URL.Query().Get("widget"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
+
+func (s *Server) getWidgetMetricHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	metric, err := s.db.GetWidgetMetric(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, metric)
+}
+
+func (s *Server) createWidgetMetricHandler(w http.ResponseWriter, r *http.Request) {
+	var metric models.WidgetMetric
+	if err := decodeJSON(r, &metric); err != nil {
+		writeError(w, err)
+		return
+	}
+	metric.Selector = strings.TrimSpace(metric.Selector)
+	metric, err := s.db.CreateWidgetMetric(metric)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, metric)
+}
+
+func (s *Server) updateWidgetMetricHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var metric models.WidgetMetric
+	if err := decodeJSON(r, &metric); err != nil {
+		writeError(w, err)
+		return
+	}
+	metric.Id = id
+	metric.Selector = strings.TrimSpace(metric.Selector)
+	if err := s.db.UpdateWidgetMetric(metric); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, metric)
+}
+
+func (s *Server) deleteWidgetMetricHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.db.DeleteWidgetMetric(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"log/slog"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"sync"
+	"time"
+)
+
+type ActivityPublisher interface {
+	Publish(models.Activity)
+}
+
+// activityBuffer is how much activity a subscriber can fall behind by before
+// activity is dropped for it.
+const activityBuffer = 64
+
+// ActivityHub passes activity published by the services on to every
+// subscriber, in process. Publishing never blocks on a slow subscriber.
+type ActivityHub struct {
+	mu          sync.Mutex
+	lastId      int64
+	subscribers map[chan models.Activity]struct{}
+}
+
+func NewActivityHub() *ActivityHub {
+	return &ActivityHub{subscribers: make(map[chan models.Activity]struct{})}
+}
+
+// Publish numbers and timestamps the activity and hands it to the subscribers.
+func (h *ActivityHub) Publish(a models.Activity) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastId++
+	a.Id = h.lastId
+	if a.Time.IsZero() {
+		a.Time = time.Now().UTC()
+	}
+	for ch := range h.subscribers {
+		select {
+		case ch <- a:
+		default:
+			slog.Warn("dropping activity for slow subscriber", "id", a.Id, "kind", a.Kind)
+		}
+	}
+}
+
+// Subscribe returns a channel of the activity published from now on. Calling
+// the returned func unsubscribes and closes the channel.
+func (h *ActivityHub) Subscribe() (<-chan models.Activity, func()) {
+	ch := make(chan models.Activity, activityBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(h.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// publish is a no-op without a publisher, as in the simulator and the CLI.
+func publish(p ActivityPublisher, a models.Activity) {
+	if p != nil {
+		p.Publish(a)
+	}
+}
+
+// publishCall makes a call to source and publishes it with its outcome.
+func publishCall(p ActivityPublisher, source, name, subject string, call func() error) error {
+	start := time.Now()
+	err := call()
+	a := models.Activity{
+		Kind:       models.ActivityCall,
+		Source:     source,
+		Name:       name,
+		Subject:    subject,
+		Outcome:    models.ActivityOK,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		a.Outcome = models.ActivityError
+		a.Error = err.Error()
+	}
+	publish(p, a)
+	return err
+}
+
+// PublishingHabitica publishes every write made to Habitica. Reads aren't
+// published.
+type PublishingHabitica struct {
+	habitica HabiticaTodoWriter
+	activity ActivityPublisher
+}
+
+func NewPublishingHabitica(habitica HabiticaTodoWriter, activity ActivityPublisher) *PublishingHabitica {
+	return &PublishingHabitica{habitica: habitica, activity: activity}
+}
+
+func (h *PublishingHabitica) ScoreDaily(taskId string) error {
+	return publishCall(h.activity, models.HabiticaEventSource, models.ActionScoreHabitica, taskId, func() error {
+		return h.habitica.ScoreDaily(taskId)
+	})
+}
+
+func (h *PublishingHabitica) UnscoreTask(taskId string) error {
+	return publishCall(h.activity, models.HabiticaEventSource, models.ActionUnscoreHabitica, taskId, func() error {
+		return h.habitica.UnscoreTask(taskId)
+	})
+}
+
+func (h *PublishingHabitica) CreateTodo(text string) (habitica.Task, error) {
+	var task habitica.Task
+	err := publishCall(h.activity, models.HabiticaEventSource, "habitica.create_todo", text, func() error {
+		var err error
+		task, err = h.habitica.CreateTodo(text)
+		return err
+	})
+	return task, err
+}
+
+func (h *PublishingHabitica) UpdateTaskText(taskId, text string) error {
+	return publishCall(h.activity, models.HabiticaEventSource, "habitica.update_text", taskId, func() error {
+		return h.habitica.UpdateTaskText(taskId, text)
+	})
+}
+
+func (h *PublishingHabitica) DeleteTask(taskId string) error {
+	return publishCall(h.activity, models.HabiticaEventSource, "habitica.delete", taskId, func() error {
+		return h.habitica.DeleteTask(taskId)
+	})
+}
+
+type TodoistTaskWriteSyncer interface {
+	TodoistTaskWriter
+	TodoistTaskSyncer
+}
+
+// PublishingTodoist publishes every write made to Todoist. Reads aren't
+// published.
+type PublishingTodoist struct {
+	todoist  TodoistTaskWriteSyncer
+	activity ActivityPublisher
+}
+
+func NewPublishingTodoist(todoist TodoistTaskWriteSyncer, activity ActivityPublisher) *PublishingTodoist {
+	return &PublishingTodoist{todoist: todoist, activity: activity}
+}
+
+func (t *PublishingTodoist) CreateTask(content, projectId string) error {
+	return publishCall(t.activity, models.TodoistEventSource, models.ActionCreateTodoist, content, func() error {
+		return t.todoist.CreateTask(content, projectId)
+	})
+}
+
+func (t *PublishingTodoist) CloseTask(taskId string) error {
+	return publishCall(t.activity, models.TodoistEventSource, models.ActionCloseTodoist, taskId, func() error {
+		return t.todoist.CloseTask(taskId)
+	})
+}
+
+func (t *PublishingTodoist) ReopenTask(taskId string) error {
+	return publishCall(t.activity, models.TodoistEventSource, "todoist.reopen", taskId, func() error {
+		return t.todoist.ReopenTask(taskId)
+	})
+}
+
+func (t *PublishingTodoist) UpdateTaskContent(taskId, content string) error {
+	return publishCall(t.activity, models.TodoistEventSource, "todoist.update_content", taskId, func() error {
+		return t.todoist.UpdateTaskContent(taskId, content)
+	})
+}
+
+func (t *PublishingTodoist) GetProjectTasks(projectId string) ([]todoist.Task, error) {
+	return t.todoist.GetProjectTasks(projectId)
+}
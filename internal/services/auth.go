@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"misc/internal/models"
+	"time"
+)
+
+// ErrInvalidCredentials is returned for a wrong password.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// tokenPrefix marks API tokens so they're recognisable, e.g. in leaked logs.
+const tokenPrefix = "misc_"
+
+// SessionTTL is how long a web UI login lasts.
+const SessionTTL = 30 * 24 * time.Hour
+
+// tokenTouchInterval limits how often a token's last use is written.
+const tokenTouchInterval = time.Minute
+
+type AuthStore interface {
+	CreateAPIToken(models.APIToken, string) (models.APIToken, error)
+	FindAPIToken(string) (models.APIToken, error)
+	TouchAPIToken(int64, time.Time) error
+	CreateSession(string, models.Session) error
+	FindSession(string) (models.Session, error)
+	DeleteSession(string) error
+}
+
+// AuthService issues and checks API tokens and web UI sessions. Only SHA-256
+// hashes of their secrets are stored; the secrets are random, so a plain hash
+// is enough.
+type AuthService struct {
+	db AuthStore
+	// the web UI password; logging in is disabled without one
+	password string
+}
+
+func NewAuthService(db AuthStore, password string) *AuthService {
+	return &AuthService{db: db, password: password}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateToken creates an API token and returns it along with its secret,
+// which can't be retrieved later.
+func (a *AuthService) CreateToken(name string, scopes []string) (models.APIToken, string, error) {
+	token := models.APIToken{Name: name, Scopes: scopes}
+	if err := token.Validate(); err != nil {
+		return token, "", err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return token, "", err
+	}
+	secret = tokenPrefix + secret
+	token, err = a.db.CreateAPIToken(token, hashSecret(secret))
+	return token, secret, err
+}
+
+// Token returns the API token with the secret, or the store's not found error.
+func (a *AuthService) Token(secret string) (models.APIToken, error) {
+	token, err := a.db.FindAPIToken(hashSecret(secret))
+	if err != nil {
+		return token, err
+	}
+	now := time.Now().UTC()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > tokenTouchInterval {
+		if err := a.db.TouchAPIToken(token.Id, now); err != nil {
+			slog.Warn("error recording api token use", "name", token.Name, "err", err)
+		}
+	}
+	return token, nil
+}
+
+// Login checks the web UI password and starts an admin session, returning its
+// secret for the session cookie.
+func (a *AuthService) Login(password string) (string, models.Session, error) {
+	if a.password == "" || subtle.ConstantTimeCompare([]byte(password), []byte(a.password)) != 1 {
+		return "", models.Session{}, ErrInvalidCredentials
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return "", models.Session{}, err
+	}
+	now := time.Now().UTC()
+	session := models.Session{Scopes: []string{models.ScopeAdmin}, CreatedAt: now, ExpiresAt: now.Add(SessionTTL)}
+	if err := a.db.CreateSession(hashSecret(secret), session); err != nil {
+		return "", session, err
+	}
+	return secret, session, nil
+}
+
+// Session returns the unexpired session with the secret, or the store's not
+// found error.
+func (a *AuthService) Session(secret string) (models.Session, error) {
+	return a.db.FindSession(hashSecret(secret))
+}
+
+func (a *AuthService) Logout(secret string) error {
+	return a.db.DeleteSession(hashSecret(secret))
+}
+
+// LoginEnabled reports whether a web UI password is set.
+func (a *AuthService) LoginEnabled() bool {
+	return a.password != ""
+}
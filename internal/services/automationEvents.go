@@ -0,0 +1,97 @@
+package services
+
+import (
+	"misc/internal/models"
+	"time"
+)
+
+// TodoistAutomationEvent describes a Todoist webhook for the engine. Item
+// events have item.* facts and note events note.* facts.
+func TodoistAutomationEvent(w models.TodoistWebhook) models.AutomationEvent {
+	event := models.AutomationEvent{
+		Kind: models.TriggerTodoist,
+		Name: w.EventName,
+		Facts: map[string]any{
+			"event":        w.EventName,
+			"initiator.id": w.Initiator.Id,
+		},
+	}
+	if item, err := w.Item(); err == nil {
+		event.Subject = item.Id
+		addItemFacts(event.Facts, item)
+	}
+	if note, err := w.Note(); err == nil {
+		event.Subject = note.ItemId
+		event.Facts["note.id"] = note.Id
+		event.Facts["note.content"] = note.Content
+		event.Facts["item.id"] = note.ItemId
+		event.Facts["item.project_id"] = note.ProjectId
+	}
+	return event
+}
+
+func addItemFacts(facts map[string]any, item models.TodoistItem) {
+	labels := item.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	facts["item.id"] = item.Id
+	facts["item.content"] = item.Content
+	facts["item.description"] = item.Description
+	facts["item.project_id"] = item.ProjectId
+	facts["item.section_id"] = item.SectionId
+	facts["item.parent_id"] = item.ParentId
+	facts["item.labels"] = labels
+	facts["item.priority"] = item.Priority
+	facts["item.checked"] = item.Checked
+}
+
+// HabiticaAutomationEvent describes a Habitica task webhook for the engine.
+func HabiticaAutomationEvent(w models.HabiticaWebhook) models.AutomationEvent {
+	return models.AutomationEvent{
+		Kind:    models.TriggerHabitica,
+		Name:    w.Type,
+		Subject: w.Task.Id,
+		Facts: map[string]any{
+			"event":     w.Type,
+			"direction": w.Direction,
+			"task.id":   w.Task.Id,
+			"task.type": w.Task.Type,
+			"task.text": w.Task.Text,
+			"task.up":   w.Task.Up,
+			"task.down": w.Task.Down,
+			"task.net":  w.Task.Up - w.Task.Down,
+		},
+	}
+}
+
+// FitbitAutomationEvent describes today's reading of a Fitbit metric, such as
+// steps, and the goal for it. reached is whether a goal is set and was met.
+func FitbitAutomationEvent(metric string, value, goal float64) models.AutomationEvent {
+	return models.AutomationEvent{
+		Kind:    models.TriggerFitbit,
+		Name:    metric,
+		Subject: metric,
+		Facts: map[string]any{
+			"metric":  metric,
+			"value":   value,
+			"goal":    goal,
+			"reached": goal > 0 && value >= goal,
+		},
+	}
+}
+
+// ScheduleAutomationEvent describes a scheduled run named name.
+func ScheduleAutomationEvent(name string, at time.Time) models.AutomationEvent {
+	return models.AutomationEvent{
+		Kind:    models.TriggerSchedule,
+		Name:    name,
+		Subject: name,
+		Facts: map[string]any{
+			"schedule": name,
+			"time":     at.Format(time.RFC3339),
+			"weekday":  at.Weekday().String(),
+			"hour":     at.Hour(),
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"misc/clients/fitbit"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache key prefixes, one per upstream API. Invalidating a prefix drops
+// everything read from that API.
+const (
+	CacheHabitica = "habitica."
+	CacheTodoist  = "todoist."
+	CacheFitbit   = "fitbit."
+)
+
+type CacheInvalidator interface {
+	Invalidate(prefix string)
+}
+
+// Cache keeps upstream API reads for a while so the widget and dashboard
+// don't hit the rate limits. Concurrent reads of the same key share one
+// upstream call.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// generation counts invalidations, so a read that started before one
+	// isn't stored after it
+	generation uint64
+	group      singleflight.Group
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]cacheEntry)}
+}
+
+// Invalidate drops the entries whose key starts with prefix, e.g. CacheTodoist.
+func (c *Cache) Invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	slog.Debug("invalidated cache", "prefix", prefix)
+}
+
+func (c *Cache) lookup(key string) (any, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, c.generation, true
+	}
+	return nil, c.generation, false
+}
+
+func (c *Cache) store(key string, value any, ttl time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{value: value, expires: now.Add(ttl)}
+}
+
+// cached returns the value stored under key, or loads and stores it for ttl.
+// Errors aren't cached. A ttl of 0 turns caching off but still coalesces
+// concurrent reads.
+func cached[T any](c *Cache, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	value, generation, ok := c.lookup(key)
+	if ok {
+		return value.(T), nil
+	}
+
+	// reads after an invalidation don't join a call that started before it
+	flight := fmt.Sprintf("%s@%d", key, generation)
+	value, err, _ := c.group.Do(flight, func() (any, error) {
+		// a call that finished while this one waited may have stored it
+		if value, _, ok := c.lookup(key); ok {
+			return value, nil
+		}
+		loaded, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if ttl > 0 {
+			c.store(key, loaded, ttl, generation)
+		}
+		return loaded, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
+
+// CacheTTLs is how long reads from each upstream API are kept.
+type CacheTTLs struct {
+	Habitica time.Duration
+	Todoist  time.Duration
+	Fitbit   time.Duration
+}
+
+var DefaultCacheTTLs = CacheTTLs{
+	Habitica: time.Minute,
+	Todoist:  time.Minute,
+	Fitbit:   5 * time.Minute,
+}
+
+// CachedHabitica reads habits and dailies through the cache.
+type CachedHabitica struct {
+	repo  HabiticaTaskRepository
+	cache *Cache
+	ttl   time.Duration
+}
+
+func NewCachedHabitica(repo HabiticaTaskRepository, cache *Cache, ttl time.Duration) *CachedHabitica {
+	return &CachedHabitica{repo: repo, cache: cache, ttl: ttl}
+}
+
+func (h *CachedHabitica) GetHabits() ([]habitica.Habit, error) {
+	return cached(h.cache, CacheHabitica+"habits", h.ttl, h.repo.GetHabits)
+}
+
+func (h *CachedHabitica) GetDailys() ([]habitica.Daily, error) {
+	return cached(h.cache, CacheHabitica+"dailies", h.ttl, h.repo.GetDailys)
+}
+
+// CachedTodoist reads task filters and stats through the cache.
+type CachedTodoist struct {
+	repo  TodoistTaskRepository
+	cache *Cache
+	ttl   time.Duration
+}
+
+func NewCachedTodoist(repo TodoistTaskRepository, cache *Cache, ttl time.Duration) *CachedTodoist {
+	return &CachedTodoist{repo: repo, cache: cache, ttl: ttl}
+}
+
+func (t *CachedTodoist) GetTasks(opts *todoist.TaskFilterOptions) ([]todoist.Task, error) {
+	key := CacheTodoist + "tasks"
+	if opts != nil {
+		key = fmt.Sprintf("%s:%s:%s:%d", key, opts.Query, opts.Lang, opts.Limit)
+	}
+	return cached(t.cache, key, t.ttl, func() ([]todoist.Task, error) {
+		return t.repo.GetTasks(opts)
+	})
+}
+
+func (t *CachedTodoist) GetStats() (todoist.Stats, error) {
+	return cached(t.cache, CacheTodoist+"stats", t.ttl, t.repo.GetStats)
+}
+
+type FitbitReader interface {
+	GetFitbitActivity() (fitbit.ActivityResponse, error)
+	GetFitbitWeight() (fitbit.WeightResponse, error)
+}
+
+// CachedFitbit reads today's activity and recent weight through the cache.
+// Fitbit sends no webhooks here, so its entries only expire.
+type CachedFitbit struct {
+	fitbit FitbitReader
+	cache  *Cache
+	ttl    time.Duration
+}
+
+func NewCachedFitbit(fitbit FitbitReader, cache *Cache, ttl time.Duration) *CachedFitbit {
+	return &CachedFitbit{fitbit: fitbit, cache: cache, ttl: ttl}
+}
+
+func (f *CachedFitbit) GetFitbitActivity() (fitbit.ActivityResponse, error) {
+	// keyed by day so the first read after midnight isn't yesterday's
+	key := CacheFitbit + "activity:" + time.Now().Format("2006-01-02")
+	return cached(f.cache, key, f.ttl, f.fitbit.GetFitbitActivity)
+}
+
+func (f *CachedFitbit) GetFitbitWeight() (fitbit.WeightResponse, error) {
+	key := CacheFitbit + "weight:" + time.Now().Format("2006-01-02")
+	return cached(f.cache, key, f.ttl, f.fitbit.GetFitbitWeight)
+}
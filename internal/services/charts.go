@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"misc/clients/fitbit"
+	"misc/internal/models"
+	"sort"
+	"time"
+)
+
+// Charts served by the ChartService.
+const (
+	ChartSteps   = "steps"
+	ChartWeight  = "weight"
+	ChartWater   = "water"
+	ChartTodoist = "todoist"
+)
+
+// DefaultWaterHabit selects the habit charted as water, by text or id.
+const DefaultWaterHabit = "Water"
+
+func IsChart(name string) bool {
+	switch name {
+	case ChartSteps, ChartWeight, ChartWater, ChartTodoist:
+		return true
+	}
+	return false
+}
+
+type ChartStore interface {
+	ListSnapshots(models.SnapshotFilter) ([]models.Snapshot, error)
+}
+
+type WeightReader interface {
+	GetFitbitWeight() (fitbit.WeightResponse, error)
+}
+
+// ChartService gathers the daily series for the trend charts from the
+// snapshots. Weight is topped up with the last week's Fitbit log, so weigh-ins
+// show up before the next snapshot.
+type ChartService struct {
+	db       ChartStore
+	habitica HabiticaTaskRepository
+	// nil without a saved Fitbit token
+	fitbit     WeightReader
+	waterHabit string
+}
+
+func NewChartService(db ChartStore, habitica HabiticaTaskRepository, fitbit WeightReader, waterHabit string) *ChartService {
+	return &ChartService{db: db, habitica: habitica, fitbit: fitbit, waterHabit: waterHabit}
+}
+
+// Chart returns the named chart's series over the last days days.
+func (c *ChartService) Chart(name string, days int) (models.Chart, error) {
+	today := startOfDay(time.Now())
+	chart := models.Chart{
+		Name:   name,
+		Type:   models.ChartBar,
+		From:   today.AddDate(0, 0, 1-days).Format(models.SnapshotDateFormat),
+		To:     today.Format(models.SnapshotDateFormat),
+		Days:   days,
+		Points: make([]models.ChartPoint, 0, days),
+	}
+
+	filter := models.SnapshotFilter{From: chart.From, To: chart.To}
+	switch name {
+	case ChartSteps:
+		chart.Title, filter.Metric = "Steps", models.SnapshotSteps
+	case ChartTodoist:
+		chart.Title, filter.Metric = "Todoist", models.SnapshotTodoistCompleted
+	case ChartWater:
+		habits, err := c.habitica.GetHabits()
+		if err != nil {
+			return chart, fmt.Errorf("error getting habits for the water chart: %w", err)
+		}
+		habit, ok := findHabit(habits, c.waterHabit)
+		if !ok {
+			return chart, fmt.Errorf("no habit matching %q for the water chart", c.waterHabit)
+		}
+		chart.Title, filter.Metric, filter.Subject = habit.Text, models.SnapshotHabitUp, habit.ID
+	case ChartWeight:
+		chart.Title, chart.Unit, chart.Type, filter.Metric = "Weight", "kg", models.ChartSparkline, models.SnapshotWeight
+	default:
+		return chart, fmt.Errorf("unknown chart %q", name)
+	}
+
+	snapshots, err := c.db.ListSnapshots(filter)
+	if err != nil {
+		return chart, err
+	}
+	byDate := make(map[string]models.ChartPoint, len(snapshots))
+	for _, snap := range snapshots {
+		// an empty subject filter matches every subject
+		if snap.Subject == filter.Subject {
+			byDate[snap.Date] = models.ChartPoint{Date: snap.Date, Value: snap.Value, Goal: snap.Goal}
+		}
+	}
+	if name == ChartWeight {
+		c.recentWeight(chart, byDate)
+	}
+
+	for _, point := range byDate {
+		chart.Points = append(chart.Points, point)
+	}
+	sort.Slice(chart.Points, func(i, j int) bool {
+		return chart.Points[i].Date < chart.Points[j].Date
+	})
+	return chart, nil
+}
+
+// recentWeight adds the weigh-ins in the chart's range from the Fitbit log.
+// The chart still shows the snapshots if Fitbit can't be reached.
+func (c *ChartService) recentWeight(chart models.Chart, byDate map[string]models.ChartPoint) {
+	if c.fitbit == nil {
+		return
+	}
+	weight, err := c.fitbit.GetFitbitWeight()
+	if err != nil {
+		slog.Warn("error getting fitbit weight for chart", "err", err)
+		return
+	}
+	for _, r := range weight.WeightRecords {
+		if r.Date >= chart.From && r.Date <= chart.To {
+			byDate[r.Date] = models.ChartPoint{Date: r.Date, Value: r.Weight}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"time"
+)
+
+// Todoist filters for the dashboard's task sections, as on the kindle
+// dashboard.
+const (
+	ChoresFilter  = "##shared chores & (today | od)"
+	HygieneFilter = "##health and hygiene & (today | od)"
+)
+
+type HabitGoalLister interface {
+	ListHabitGoals() ([]models.HabitGoal, error)
+}
+
+type DailyChecker interface {
+	ScoreDaily(string) error
+	UnscoreTask(string) error
+}
+
+type TaskCloser interface {
+	CloseTask(string) error
+}
+
+// DashboardService gathers the web dashboard and checks off its dailies and
+// tasks. Reads go through the cache, which is invalidated after each check so
+// the refreshed dashboard shows it.
+type DashboardService struct {
+	goals    HabitGoalLister
+	habitica HabiticaTaskRepository
+	todoist  TodoistTaskRepository
+	// nil without a saved Fitbit token
+	fitbit  FitbitReader
+	cache   CacheInvalidator
+	dailies DailyChecker
+	tasks   TaskCloser
+}
+
+func NewDashboardService(
+	goals HabitGoalLister,
+	habitica HabiticaTaskRepository,
+	todoist TodoistTaskRepository,
+	fitbit FitbitReader,
+	cache CacheInvalidator,
+	dailies DailyChecker,
+	tasks TaskCloser,
+) *DashboardService {
+	return &DashboardService{
+		goals:    goals,
+		habitica: habitica,
+		todoist:  todoist,
+		fitbit:   fitbit,
+		cache:    cache,
+		dailies:  dailies,
+		tasks:    tasks,
+	}
+}
+
+// GetDashboard loads every section concurrently. A section whose source fails
+// is left empty and reported in Sources.
+func (d *DashboardService) GetDashboard(ctx context.Context) models.Dashboard {
+	dash := models.Dashboard{
+		Habits:  make([]models.DashboardHabit, 0),
+		Dailies: make([]models.DashboardDaily, 0),
+		Chores:  make([]models.DashboardTask, 0),
+		Hygiene: make([]models.DashboardTask, 0),
+	}
+
+	var goals map[string]models.HabitGoal
+	sources := []widgetSource{
+		{"habit goals", func(ctx context.Context) error {
+			list, err := callWithContext(ctx, d.goals.ListHabitGoals)
+			goals = habitGoalsById(list)
+			return err
+		}},
+		{"habits", func(ctx context.Context) error {
+			habits, err := callWithContext(ctx, d.habitica.GetHabits)
+			for _, h := range habits {
+				dash.Habits = append(dash.Habits, models.DashboardHabit{Id: h.ID, Text: h.Text, Count: h.CounterUp})
+			}
+			return err
+		}},
+		{"dailies", func(ctx context.Context) error {
+			dailies, err := callWithContext(ctx, d.habitica.GetDailys)
+			for _, daily := range dailies {
+				if daily.IsDue {
+					dash.Dailies = append(dash.Dailies, models.DashboardDaily{
+						Id:        daily.ID,
+						Text:      daily.Text,
+						Completed: daily.Completed,
+						Streak:    daily.Streak,
+					})
+				}
+			}
+			return err
+		}},
+		{"chores", func(ctx context.Context) error {
+			var err error
+			dash.Chores, err = d.taskSection(ctx, ChoresFilter)
+			return err
+		}},
+		{"hygiene", func(ctx context.Context) error {
+			var err error
+			dash.Hygiene, err = d.taskSection(ctx, HygieneFilter)
+			return err
+		}},
+	}
+	if d.fitbit != nil {
+		sources = append(sources, widgetSource{"fitbit", func(ctx context.Context) error {
+			fitbit, err := d.fitbitSection(ctx)
+			dash.Fitbit = fitbit
+			return err
+		}})
+	}
+
+	dash.Sources = loadSources(ctx, sources)
+	for i, h := range dash.Habits {
+		if g, ok := goals[h.Id]; ok {
+			dash.Habits[i].Goal = &g
+		}
+	}
+	dash.UpdatedAt = time.Now()
+	return dash
+}
+
+func (d *DashboardService) taskSection(ctx context.Context, filter string) ([]models.DashboardTask, error) {
+	section := make([]models.DashboardTask, 0)
+	tasks, err := callWithContext(ctx, func() ([]todoist.Task, error) {
+		return d.todoist.GetTasks(&todoist.TaskFilterOptions{Query: filter})
+	})
+	for _, t := range tasks {
+		section = append(section, models.DashboardTask{Id: t.ID, Content: t.Content})
+	}
+	return section, err
+}
+
+func (d *DashboardService) fitbitSection(ctx context.Context) (*models.DashboardFitbit, error) {
+	activity, err := callWithContext(ctx, d.fitbit.GetFitbitActivity)
+	if err != nil {
+		return nil, err
+	}
+	fitbit := &models.DashboardFitbit{
+		Steps:             activity.Summary.Steps,
+		StepsGoal:         activity.Goals.Steps,
+		ActiveMinutes:     activity.Summary.VeryActiveMinutes,
+		ActiveMinutesGoal: activity.Goals.ActiveMinutes,
+	}
+	weight, err := callWithContext(ctx, d.fitbit.GetFitbitWeight)
+	if records := weight.WeightRecords; err == nil && len(records) > 0 {
+		fitbit.Weight = records[len(records)-1].Weight
+	}
+	return fitbit, err
+}
+
+// CheckDaily checks off a daily in Habitica, or unchecks it when done is false.
+func (d *DashboardService) CheckDaily(dailyId string, done bool) error {
+	check := d.dailies.ScoreDaily
+	if !done {
+		check = d.dailies.UnscoreTask
+	}
+	if err := check(dailyId); err != nil {
+		return err
+	}
+	d.cache.Invalidate(CacheHabitica)
+	return nil
+}
+
+// CloseTask completes a Todoist task.
+func (d *DashboardService) CloseTask(taskId string) error {
+	if err := d.tasks.CloseTask(taskId); err != nil {
+		return err
+	}
+	d.cache.Invalidate(CacheTodoist)
+	return nil
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"misc/internal/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DailyUpdater interface {
+	ScoreDaily(string) error
+}
+
+type TaskUpdater interface {
+	DailyUpdater
+	UnscoreTask(string) error
+}
+
+type TodoistTaskWriter interface {
+	CreateTask(content, projectId string) error
+	CloseTask(string) error
+}
+
+type Notifier interface {
+	Notify(string) error
+}
+
+// Actuators are the clients automation actions are carried out through.
+type Actuators struct {
+	Habitica TaskUpdater
+	Todoist  TodoistTaskWriter
+	Notifier Notifier
+}
+
+// EngineStore is where the engine loads automations from and keeps track of
+// what they did.
+type EngineStore interface {
+	ListAutomations() ([]models.Automation, error)
+	ListHabitGoals() ([]models.HabitGoal, error)
+
+	MarkAutomationFired(string, string) (bool, error)
+	ClearAutomationFired(string, string) (bool, error)
+	RecordCompletion(string, []string) error
+}
+
+// Engine runs automations against events from Todoist, Habitica, Fitbit and
+// the scheduler. The min habit, text and project rules are stored as
+// automations too and run alongside the others.
+type Engine struct {
+	db        EngineStore
+	actuators Actuators
+	// publishes each automation that ran, if set
+	activity ActivityPublisher
+}
+
+func NewEngine(db EngineStore, actuators Actuators, activity ActivityPublisher) *Engine {
+	return &Engine{db: db, actuators: actuators, activity: activity}
+}
+
+// Automations returns the stored automations, with min habit rules that have
+// no threshold of their own following their habit's goal.
+func (e *Engine) Automations() ([]models.Automation, error) {
+	stored, err := e.db.ListAutomations()
+	if err != nil {
+		return nil, err
+	}
+	goals, err := e.db.ListHabitGoals()
+	if err != nil {
+		return nil, err
+	}
+	targets := habitGoalsById(goals)
+
+	automations := make([]models.Automation, 0, len(stored))
+	for _, a := range stored {
+		if a.Source == models.AutomationSourceHabit {
+			rule := a.HabitRule()
+			if rule.MinScore == 0 {
+				goal, ok := targets[rule.HabitId]
+				if !ok {
+					slog.Warn("skipping min habit rule, its habit has no goal", "rule", rule.Id, "habit", rule.HabitId)
+					continue
+				}
+				rule.MinScore = goal.Target
+				a = rule.Automation()
+			}
+		}
+		automations = append(automations, a)
+	}
+	return automations, nil
+}
+
+// Run carries out every automation the event triggers and reports what each
+// did. A failing automation doesn't stop the others; all errors are returned.
+// Habitica tasks scored for a completed Todoist item are recorded so the
+// scores can be undone if the item is uncompleted.
+func (e *Engine) Run(event models.AutomationEvent) ([]models.AutomationRun, error) {
+	automations, err := e.Automations()
+	if err != nil {
+		return nil, fmt.Errorf("error loading automations: %w", err)
+	}
+
+	runs := make([]models.AutomationRun, 0)
+	var errs []error
+	var scored []string
+	done := make(map[models.ActionCall]bool)
+	for _, c := range evaluate(automations, event) {
+		run, err := e.run(c, event, done)
+		if run.Outcome == "" {
+			continue
+		}
+		if err != nil {
+			run.Error = err.Error()
+			errs = append(errs, fmt.Errorf("error running automation %s: %w", run.Key, err))
+		}
+		for _, call := range run.Calls {
+			if run.Outcome == models.AutomationFired && call.Kind == models.ActionScoreHabitica {
+				scored = append(scored, call.TaskId)
+			}
+		}
+		slog.Info("ran automation", "key", run.Key, "name", run.Name, "outcome", run.Outcome, "calls", len(run.Calls))
+		publish(e.activity, models.Activity{
+			Kind:    models.ActivityRule,
+			Source:  event.Kind,
+			Name:    run.Name,
+			Subject: run.Key,
+			Outcome: run.Outcome,
+			Error:   run.Error,
+		})
+		runs = append(runs, run)
+	}
+
+	if event.Kind == models.TriggerTodoist && event.Name == models.TodoistItemCompleted && len(scored) > 0 {
+		if err := e.db.RecordCompletion(event.Subject, scored); err != nil {
+			errs = append(errs, fmt.Errorf("error linking completion to habitica tasks: %w", err))
+		}
+	}
+	return runs, errors.Join(errs...)
+}
+
+// run carries out one candidate automation. Automations that fire once a day
+// are marked before acting and unmarked if acting fails, so a retry acts
+// again; when their conditions stop holding their Habitica scores are undone.
+func (e *Engine) run(c candidate, event models.AutomationEvent, done map[models.ActionCall]bool) (models.AutomationRun, error) {
+	a := c.automation
+	run := models.AutomationRun{Key: a.Key(), Name: a.Name, Calls: make([]models.ActionCall, 0)}
+	if a.Once == "" {
+		run.Outcome = models.AutomationFired
+		var err error
+		run.Calls, err = e.perform(a.Actions, event.Facts, done)
+		return run, err
+	}
+
+	day := time.Now().Format(time.DateOnly)
+	if c.matched {
+		fired, err := e.db.MarkAutomationFired(run.Key, day)
+		if err != nil {
+			return run, err
+		}
+		if !fired {
+			run.Outcome = models.AutomationAlreadyFired
+			return run, nil
+		}
+		run.Outcome = models.AutomationFired
+		run.Calls, err = e.perform(a.Actions, event.Facts, done)
+		if err != nil {
+			_, clearErr := e.db.ClearAutomationFired(run.Key, day)
+			return run, errors.Join(err, clearErr)
+		}
+		return run, nil
+	}
+
+	cleared, err := e.db.ClearAutomationFired(run.Key, day)
+	if err != nil || !cleared {
+		return run, err
+	}
+	run.Outcome = models.AutomationReverted
+	run.Calls, err = e.perform(revertActions(a.Actions), event.Facts, done)
+	if err != nil {
+		_, markErr := e.db.MarkAutomationFired(run.Key, day)
+		return run, errors.Join(err, markErr)
+	}
+	return run, nil
+}
+
+// perform carries out actions in order, skipping any identical action already
+// carried out for this event, and stops at the first failure.
+func (e *Engine) perform(actions []models.Action, facts map[string]any, done map[models.ActionCall]bool) ([]models.ActionCall, error) {
+	calls := make([]models.ActionCall, 0, len(actions))
+	for _, action := range actions {
+		call := models.ActionCall{
+			Kind:      action.Kind,
+			TaskId:    expandFacts(action.TaskId, facts),
+			Content:   expandFacts(action.Content, facts),
+			ProjectId: expandFacts(action.ProjectId, facts),
+		}
+		if done[call] {
+			continue
+		}
+
+		var err error
+		switch call.Kind {
+		case models.ActionScoreHabitica:
+			err = e.actuators.Habitica.ScoreDaily(call.TaskId)
+		case models.ActionUnscoreHabitica:
+			err = e.actuators.Habitica.UnscoreTask(call.TaskId)
+		case models.ActionCreateTodoist:
+			err = e.actuators.Todoist.CreateTask(call.Content, call.ProjectId)
+		case models.ActionCloseTodoist:
+			err = e.actuators.Todoist.CloseTask(call.TaskId)
+		case models.ActionNotify:
+			err = e.actuators.Notifier.Notify(call.Content)
+		default:
+			err = fmt.Errorf("unknown action %q", call.Kind)
+		}
+		if err != nil {
+			return calls, fmt.Errorf("error running %s: %w", call.Kind, err)
+		}
+		done[call] = true
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// revertActions undoes Habitica scores. Other actions can't be undone.
+func revertActions(actions []models.Action) []models.Action {
+	reverted := make([]models.Action, 0, len(actions))
+	for _, action := range actions {
+		switch action.Kind {
+		case models.ActionScoreHabitica:
+			action.Kind = models.ActionUnscoreHabitica
+		case models.ActionUnscoreHabitica:
+			action.Kind = models.ActionScoreHabitica
+		default:
+			continue
+		}
+		reverted = append(reverted, action)
+	}
+	return reverted
+}
+
+var factPattern = regexp.MustCompile(`\{([a-z_.]+)\}`)
+
+// expandFacts replaces {field} with the event's fact of that name.
+func expandFacts(s string, facts map[string]any) string {
+	return factPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		value, ok := facts[ref[1:len(ref)-1]]
+		if !ok {
+			return ref
+		}
+		return factString(value)
+	})
+}
+
+type candidate struct {
+	automation models.Automation
+	matched    bool
+}
+
+// evaluate returns the automations the event triggers, in the order they
+// should run, and whether their conditions matched. Automations that didn't
+// match are only included if they fire once a day, so they can be reverted.
+func evaluate(automations []models.Automation, event models.AutomationEvent) []candidate {
+	triggered := make([]models.Automation, 0)
+	for _, a := range automations {
+		if a.Enabled && triggers(a.Trigger, event) {
+			triggered = append(triggered, a)
+		}
+	}
+	sort.SliceStable(triggered, func(i, j int) bool {
+		if triggered[i].Priority != triggered[j].Priority {
+			return triggered[i].Priority > triggered[j].Priority
+		}
+		return triggered[i].Id < triggered[j].Id
+	})
+
+	candidates := make([]candidate, 0)
+	stopped := make(map[string]bool)
+	matchedGroups := make(map[string]bool)
+	for _, fallback := range []bool{false, true} {
+		for _, a := range triggered {
+			if a.Fallback != fallback {
+				continue
+			}
+			if a.Group != "" && (stopped[a.Group] || (fallback && matchedGroups[a.Group])) {
+				continue
+			}
+			matched := conditionsMatch(a, event.Facts)
+			if !matched && a.Once == "" {
+				continue
+			}
+			candidates = append(candidates, candidate{a, matched})
+			if matched && a.Group != "" {
+				matchedGroups[a.Group] = true
+				if a.Stop {
+					stopped[a.Group] = true
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+func triggers(t models.Trigger, event models.AutomationEvent) bool {
+	return t.Kind == event.Kind &&
+		(t.Event == "" || t.Event == event.Name) &&
+		(t.Subject == "" || t.Subject == event.Subject)
+}
+
+func conditionsMatch(a models.Automation, facts map[string]any) bool {
+	if len(a.Conditions) == 0 {
+		return true
+	}
+	for _, c := range a.Conditions {
+		ok := conditionMatches(c, facts)
+		if a.Match == models.MatchAny && ok {
+			return true
+		}
+		if a.Match != models.MatchAny && !ok {
+			return false
+		}
+	}
+	return a.Match != models.MatchAny
+}
+
+// conditionMatches tests one fact. A missing fact only satisfies ne.
+func conditionMatches(c models.Condition, facts map[string]any) bool {
+	value, ok := facts[c.Field]
+	if !ok {
+		return c.Op == models.OpNotEquals
+	}
+
+	switch c.Op {
+	case models.OpHas:
+		list, _ := value.([]string)
+		return hasLabel(list, c.Value)
+	case models.OpGte, models.OpGt, models.OpLte, models.OpLt:
+		have, err := strconv.ParseFloat(factString(value), 64)
+		if err != nil {
+			return false
+		}
+		want, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return false
+		}
+		switch c.Op {
+		case models.OpGte:
+			return have >= want
+		case models.OpGt:
+			return have > want
+		case models.OpLte:
+			return have <= want
+		}
+		return have < want
+	}
+
+	s := factString(value)
+	switch c.Op {
+	case models.OpEquals:
+		return s == c.Value
+	case models.OpNotEquals:
+		return s != c.Value
+	case models.OpPrefix:
+		return strings.HasPrefix(strings.ToLower(s), strings.ToLower(c.Value))
+	case models.OpContains:
+		return strings.Contains(strings.ToLower(s), strings.ToLower(c.Value))
+	case models.OpRegex:
+		// used as written; prefix the pattern with (?i) to ignore case
+		re, err := regexp.Compile(c.Value)
+		if err != nil {
+			slog.Warn("skipping condition with invalid regex", "field", c.Field, "value", c.Value, "err", err)
+			return false
+		}
+		return re.MatchString(s)
+	}
+	return false
+}
+
+func factString(value any) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"log/slog"
+	"misc/clients/habitica"
+	"misc/internal/models"
+)
+
+type HabitGoalStore interface {
+	ListHabitGoals() ([]models.HabitGoal, error)
+	SaveHabitGoal(models.HabitGoal) error
+}
+
+type HabitLister interface {
+	GetHabits() ([]habitica.Habit, error)
+}
+
+// ImportHabitGoals carries over goals written as "Goal: N" in habit notes,
+// which is how goals were kept before habit_goals. Habits that already have a
+// goal are left alone, so it is safe to run again. The period follows the
+// habit's frequency in Habitica.
+func ImportHabitGoals(db HabitGoalStore, habits HabitLister) ([]models.HabitGoal, error) {
+	imported := make([]models.HabitGoal, 0)
+	existing, err := db.ListHabitGoals()
+	if err != nil {
+		return imported, err
+	}
+	goals := habitGoalsById(existing)
+
+	all, err := habits.GetHabits()
+	if err != nil {
+		return imported, err
+	}
+	for _, h := range all {
+		if _, ok := goals[h.ID]; ok {
+			continue
+		}
+		target, unit, err := h.ParseGoal()
+		if err != nil {
+			slog.Debug("not importing habit goal", "habit", h.ID, "err", err)
+			continue
+		}
+		goal := models.HabitGoal{HabitId: h.ID, Target: target, Period: models.GoalPeriodDaily, Unit: unit}
+		if h.Frequency == models.GoalPeriodWeekly {
+			goal.Period = models.GoalPeriodWeekly
+		}
+		if err := db.SaveHabitGoal(goal); err != nil {
+			return imported, err
+		}
+		imported = append(imported, goal)
+	}
+	return imported, nil
+}
+
+// ImportHabitGoalsIfEmpty imports goals from habit notes while no goal has
+// been set at all, as on the first start after upgrading, when widget metrics
+// have already switched from notes to habit_goals. It returns nil when there
+// were goals already.
+func ImportHabitGoalsIfEmpty(db HabitGoalStore, habits HabitLister) ([]models.HabitGoal, error) {
+	existing, err := db.ListHabitGoals()
+	if err != nil || len(existing) > 0 {
+		return nil, err
+	}
+	return ImportHabitGoals(db, habits)
+}
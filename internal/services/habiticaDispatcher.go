@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"misc/internal/models"
+)
+
+// Habitica task webhook types.
+const (
+	HabiticaTaskScored  = "scored"
+	HabiticaTaskUpdated = "updated"
+	HabiticaTaskDeleted = "deleted"
+)
+
+type HabiticaEventHandler func(models.HabiticaWebhook) error
+
+// HabiticaDispatcher routes Habitica webhook events to the handlers registered
+// for their type.
+type HabiticaDispatcher struct {
+	handlers map[string][]HabiticaEventHandler
+}
+
+func NewHabiticaDispatcher() *HabiticaDispatcher {
+	return &HabiticaDispatcher{handlers: make(map[string][]HabiticaEventHandler)}
+}
+
+// On registers handler for events of type eventType, e.g. HabiticaTaskScored.
+func (d *HabiticaDispatcher) On(eventType string, handler HabiticaEventHandler) {
+	d.handlers[eventType] = append(d.handlers[eventType], handler)
+}
+
+// Dispatch runs every handler registered for the event. It reports whether
+// any handler was registered, and returns the errors from all handlers that
+// failed.
+func (d *HabiticaDispatcher) Dispatch(event models.HabiticaWebhook) (bool, error) {
+	handlers := d.handlers[event.Type]
+	if len(handlers) == 0 {
+		return false, nil
+	}
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(event); err != nil {
+			errs = append(errs, fmt.Errorf("error handling %s: %w", event.Type, err))
+		}
+	}
+	return true, errors.Join(errs...)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"misc/clients/fitbit"
+	"misc/internal/models"
+	"time"
+)
+
+type FitbitActivityReader interface {
+	GetFitbitActivity() (fitbit.ActivityResponse, error)
+}
+
+type FitbitTokenRefresher interface {
+	RefreshToken() error
+}
+
+type CompletedTaskLister interface {
+	GetCompletedTasks(since, until time.Time) ([]json.RawMessage, error)
+}
+
+// ScheduleJob hands the engine a schedule event named after the job, for
+// automations triggered by the schedule.
+func ScheduleJob(name, schedule string, engine AutomationRunner) Job {
+	return Job{
+		Name:     name,
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			_, err := engine.Run(ScheduleAutomationEvent(name, time.Now()))
+			return err
+		},
+	}
+}
+
+// FitbitGoalJob hands the engine today's steps and active minutes along with
+// their goals, for automations triggered by Fitbit.
+func FitbitGoalJob(schedule string, fitbit FitbitActivityReader, engine AutomationRunner) Job {
+	return Job{
+		Name:     "fitbit-goals",
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			activity, err := fitbit.GetFitbitActivity()
+			if err != nil {
+				return err
+			}
+			for _, event := range []models.AutomationEvent{
+				FitbitAutomationEvent("steps", float64(activity.Summary.Steps), float64(activity.Goals.Steps)),
+				FitbitAutomationEvent("active_minutes", float64(activity.Summary.VeryActiveMinutes), float64(activity.Goals.ActiveMinutes)),
+			} {
+				if _, err := engine.Run(event); err != nil {
+					return fmt.Errorf("error running automations for %s: %w", event.Name, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// FitbitTokenJob refreshes the Fitbit token and saves it. The saved refresh
+// token stops working once used, so it is kept current even when nothing
+// else talks to Fitbit.
+func FitbitTokenJob(schedule string, fitbit FitbitTokenRefresher) Job {
+	return Job{
+		Name:     "fitbit-token",
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			return fitbit.RefreshToken()
+		},
+	}
+}
+
+// ReconcileWindow is how far back reconciliation looks for completed tasks.
+// It overlaps the previous night's run so a late run doesn't leave a gap.
+const ReconcileWindow = 26 * time.Hour
+
+// reconcileGrace leaves recent completions alone, since their webhooks may
+// still be on the way.
+const reconcileGrace = 10 * time.Minute
+
+// TodoistReconciler compares the tasks Todoist says were completed with the
+// webhook events journaled for them, so completions whose webhook was lost or
+// failed still score Habitica.
+type TodoistReconciler struct {
+	todoist  CompletedTaskLister
+	events   EventStore
+	webhooks *WebhookService
+}
+
+func NewTodoistReconciler(todoist CompletedTaskLister, events EventStore, webhooks *WebhookService) *TodoistReconciler {
+	return &TodoistReconciler{todoist: todoist, events: events, webhooks: webhooks}
+}
+
+// Job runs the reconciliation on schedule over the last ReconcileWindow.
+func (r *TodoistReconciler) Job(schedule string) Job {
+	return Job{
+		Name:     "todoist-reconcile",
+		Schedule: schedule,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			return r.Reconcile(ctx, now.Add(-ReconcileWindow), now.Add(-reconcileGrace))
+		},
+	}
+}
+
+// Reconcile processes completions between since and until that have no
+// journaled item:completed event, by journaling one as if Todoist had sent
+// it, and replays those whose event failed.
+func (r *TodoistReconciler) Reconcile(ctx context.Context, since, until time.Time) error {
+	completed, err := r.todoist.GetCompletedTasks(since, until)
+	if err != nil {
+		return err
+	}
+
+	var received, replayed int
+	for _, data := range completed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var item models.TodoistItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("error decoding completed todoist task: %w", err)
+		}
+
+		completedAt, err := time.Parse(time.RFC3339, item.CompletedAt)
+		if err != nil {
+			completedAt = since
+		}
+		events, err := r.events.ListEvents(models.EventFilter{
+			Source:    models.TodoistEventSource,
+			EventType: models.TodoistItemCompleted,
+			Subject:   item.Id,
+			// the webhook arrives after the completion, give or take clock skew
+			Since: completedAt.Add(-5 * time.Minute),
+		})
+		if err != nil {
+			return err
+		}
+
+		failed, handled := reconcileStatus(events)
+		switch {
+		case handled:
+			continue
+		case failed != 0:
+			if _, err := r.webhooks.Replay([]int64{failed}); err != nil {
+				return err
+			}
+			replayed++
+		default:
+			if err := r.receive(item, data); err != nil {
+				return err
+			}
+			received++
+		}
+	}
+	slog.Info("reconciled todoist completions", "completed", len(completed), "missing", received, "replayed", replayed)
+	return nil
+}
+
+// reconcileStatus reports whether any of the events for a completion was
+// handled and, if none was, the newest one that failed.
+func reconcileStatus(events []models.Event) (int64, bool) {
+	var failed int64
+	for _, e := range events {
+		switch e.Status {
+		case models.EventStatusProcessed, models.EventStatusSkipped, models.EventStatusPending:
+			return 0, true
+		case models.EventStatusFailed:
+			if failed == 0 {
+				failed = e.Id
+			}
+		}
+	}
+	return failed, false
+}
+
+func (r *TodoistReconciler) receive(item models.TodoistItem, data json.RawMessage) error {
+	payload, err := json.Marshal(models.TodoistWebhook{
+		EventName:   models.TodoistItemCompleted,
+		UserId:      item.UserId,
+		EventData:   data,
+		TriggeredAt: item.CompletedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding reconciled todoist event: %w", err)
+	}
+	slog.Info("processing missed todoist completion", "id", item.Id, "content", item.Content)
+	_, err = r.webhooks.Receive(models.TodoistEventSource, "", payload)
+	return err
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// NewNotifier returns a notifier posting to webhookUrl, or one that only logs
+// notifications when no URL is configured.
+func NewNotifier(webhookUrl string) Notifier {
+	if webhookUrl == "" {
+		return logNotifier{}
+	}
+	return &WebhookNotifier{url: webhookUrl, client: http.DefaultClient}
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(message string) error {
+	slog.Info("notification", "message", message)
+	return nil
+}
+
+// WebhookNotifier posts notifications as {"text": message}, the payload Slack
+// and Mattermost incoming webhooks accept.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending notification: got status code %d", resp.StatusCode)
+	}
+	return nil
+}
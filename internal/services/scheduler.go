@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"misc/internal/models"
+	"sync"
+	"time"
+)
+
+type JobStore interface {
+	ListJobStates() ([]models.JobState, error)
+	GetJobState(string) (models.JobState, error)
+	SaveJobState(models.JobState) error
+}
+
+// Job is work the scheduler runs on a cron schedule.
+type Job struct {
+	Name     string
+	Schedule string
+	Run      func(context.Context) error
+}
+
+// JobTimeout bounds a single run of a job.
+const JobTimeout = 5 * time.Minute
+
+// schedulerPoll is the longest the scheduler sleeps before checking the clock
+// again, so a changed clock or a suspended host doesn't delay runs for long.
+const schedulerPoll = time.Minute
+
+type scheduledJob struct {
+	Job
+	schedule CronSchedule
+	trigger  chan struct{}
+	running  bool
+}
+
+// Scheduler runs jobs in process on cron schedules and keeps their state in
+// the store. A job whose next run was missed while the server was down runs
+// once as soon as the scheduler starts, rather than once per missed run.
+type Scheduler struct {
+	store JobStore
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+}
+
+func NewScheduler(store JobStore) *Scheduler {
+	return &Scheduler{store: store}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) error {
+	schedule, err := ParseCron(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("error adding job %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return fmt.Errorf("error adding job %s: already added", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{Job: job, schedule: schedule, trigger: make(chan struct{}, 1)})
+	return nil
+}
+
+// Start runs every job on its schedule until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+// Trigger runs the named job now, unless it is already running. It returns
+// false if there is no such job.
+func (s *Scheduler) Trigger(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == name {
+			select {
+			case j.trigger <- struct{}{}:
+			default:
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Jobs returns the state of every registered job.
+func (s *Scheduler) Jobs() ([]models.JobState, error) {
+	stored, err := s.store.ListJobStates()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.JobState, len(stored))
+	for _, state := range stored {
+		byName[state.Name] = state
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]models.JobState, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		state, ok := byName[j.Name]
+		if !ok {
+			state = models.JobState{Name: j.Name}
+		}
+		state.Schedule = j.Schedule
+		state.Running = j.running
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *scheduledJob) {
+	next := s.firstRun(j)
+	for {
+		wait := time.Until(next)
+		if next.IsZero() || wait > schedulerPoll {
+			wait = schedulerPoll
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-j.trigger:
+			timer.Stop()
+			next = s.run(ctx, j)
+		case <-timer.C:
+			if !next.IsZero() && !time.Now().Before(next) {
+				next = s.run(ctx, j)
+			}
+		}
+	}
+}
+
+// firstRun works out when a job should first run after starting: now if its
+// stored next run has passed, otherwise at the stored next run. A job that is
+// new or whose schedule changed starts from its schedule.
+func (s *Scheduler) firstRun(j *scheduledJob) time.Time {
+	now := time.Now()
+	state, err := s.store.GetJobState(j.Name)
+	if err == nil && state.Schedule == j.Schedule && state.NextRunAt != nil {
+		if !state.NextRunAt.After(now) {
+			slog.Info("catching up missed job run", "job", j.Name, "missed", state.NextRunAt)
+			return now
+		}
+		return *state.NextRunAt
+	}
+
+	next := j.schedule.Next(now)
+	state.Name = j.Name
+	state.Schedule = j.Schedule
+	state.NextRunAt = utcTime(next)
+	if err := s.store.SaveJobState(state); err != nil {
+		slog.Error("error saving job state", "job", j.Name, "err", err)
+	}
+	return next
+}
+
+// run runs a job once, records the outcome and returns when it runs next.
+func (s *Scheduler) run(ctx context.Context, j *scheduledJob) time.Time {
+	s.setRunning(j, true)
+	defer s.setRunning(j, false)
+
+	slog.Info("running job", "job", j.Name)
+	start := time.Now()
+	err := runJob(ctx, j.Job)
+	finished := time.Now()
+
+	state, loadErr := s.store.GetJobState(j.Name)
+	if loadErr != nil {
+		state = models.JobState{}
+	}
+	state.Name = j.Name
+	state.Schedule = j.Schedule
+	state.LastRunAt = utcTime(start)
+	state.LastDurationMs = finished.Sub(start).Milliseconds()
+	if err != nil {
+		slog.Error("error running job", "job", j.Name, "err", err)
+		state.LastError = err.Error()
+	} else {
+		state.LastSuccessAt = state.LastRunAt
+		state.LastError = ""
+	}
+	next := j.schedule.Next(finished)
+	state.NextRunAt = utcTime(next)
+	if err := s.store.SaveJobState(state); err != nil {
+		slog.Error("error saving job state", "job", j.Name, "err", err)
+	}
+	return next
+}
+
+func (s *Scheduler) setRunning(j *scheduledJob, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = running
+}
+
+// runJob runs a job with a timeout, turning a panic into an error so one bad
+// run doesn't take the server down.
+func runJob(ctx context.Context, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, JobTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// utcTime returns t in UTC for storage, or nil for the zero time, which is
+// what CronSchedule.Next returns for a schedule that never matches.
+func utcTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
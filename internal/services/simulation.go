@@ -0,0 +1,107 @@
+package services
+
+import (
+	"misc/internal/models"
+)
+
+// SimulationStore is what a Simulator reads rules and history from. It is
+// never written to.
+type SimulationStore interface {
+	AutomationStore
+	HasAutomationFired(string, string) (bool, error)
+}
+
+// RecordingUpdater stands in for the Habitica and Todoist clients and the
+// notifier, recording the calls the automations make instead of sending them.
+type RecordingUpdater struct {
+	Calls []models.ActionCall
+}
+
+func (r *RecordingUpdater) ScoreDaily(taskId string) error {
+	r.Calls = append(r.Calls, models.ActionCall{Kind: models.ActionScoreHabitica, TaskId: taskId})
+	return nil
+}
+
+func (r *RecordingUpdater) UnscoreTask(taskId string) error {
+	r.Calls = append(r.Calls, models.ActionCall{Kind: models.ActionUnscoreHabitica, TaskId: taskId})
+	return nil
+}
+
+func (r *RecordingUpdater) CreateTask(content, projectId string) error {
+	r.Calls = append(r.Calls, models.ActionCall{Kind: models.ActionCreateTodoist, Content: content, ProjectId: projectId})
+	return nil
+}
+
+func (r *RecordingUpdater) CloseTask(taskId string) error {
+	r.Calls = append(r.Calls, models.ActionCall{Kind: models.ActionCloseTodoist, TaskId: taskId})
+	return nil
+}
+
+func (r *RecordingUpdater) Notify(message string) error {
+	r.Calls = append(r.Calls, models.ActionCall{Kind: models.ActionNotify, Content: message})
+	return nil
+}
+
+// Actuators carries out every action through the recorder.
+func (r *RecordingUpdater) Actuators() Actuators {
+	return Actuators{Habitica: r, Todoist: r, Notifier: r}
+}
+
+// dryRunStore reads rules and history from the database but discards writes,
+// answering them as the database would have.
+type dryRunStore struct {
+	SimulationStore
+}
+
+func (d dryRunStore) RecordCompletion(string, []string) error {
+	return nil
+}
+
+func (d dryRunStore) MarkCompletionUndone(int64) error {
+	return nil
+}
+
+func (d dryRunStore) MarkAutomationFired(key, day string) (bool, error) {
+	fired, err := d.HasAutomationFired(key, day)
+	return !fired, err
+}
+
+func (d dryRunStore) ClearAutomationFired(key, day string) (bool, error) {
+	return d.HasAutomationFired(key, day)
+}
+
+// Simulator runs webhook payloads through the automations against the stored
+// rules without calling Habitica or Todoist or recording anything.
+type Simulator struct {
+	db SimulationStore
+}
+
+func NewSimulator(db SimulationStore) *Simulator {
+	return &Simulator{db: dryRunStore{db}}
+}
+
+// Simulate reports which automations a webhook payload from source would run
+// and the calls they would make.
+func (s *Simulator) Simulate(source string, payload []byte) (models.Simulation, error) {
+	sim := models.Simulation{
+		Source:      source,
+		EventType:   parseEnvelope(payload).eventType(source),
+		Automations: make([]models.AutomationRun, 0),
+		Calls:       make([]models.ActionCall, 0),
+	}
+	if source != models.HabiticaEventSource && source != models.TodoistEventSource {
+		return sim, models.ValidationError{Field: "source", Message: "must be todoist or habitica"}
+	}
+
+	recorder := &RecordingUpdater{}
+	engine, todoists, habiticas := NewAutomations(s.db, recorder.Actuators(), nil)
+	webhooks := NewWebhookService(nil, nil, engine, todoists, habiticas, nil, nil)
+	status, runs, err := webhooks.handle(models.Event{Source: source, Payload: string(payload)})
+	sim.Status = status
+	if err != nil {
+		sim.Error = err.Error()
+	}
+	sim.Automations = append(sim.Automations, runs...)
+	sim.Calls = append(sim.Calls, recorder.Calls...)
+	return sim, nil
+}
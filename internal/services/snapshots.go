@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"misc/clients/fitbit"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"strconv"
+	"time"
+)
+
+type SnapshotStore interface {
+	SaveSnapshots([]models.Snapshot) error
+	FillSnapshots([]models.Snapshot) (int, error)
+	ListHabitGoals() ([]models.HabitGoal, error)
+}
+
+type TodoistHistoryReader interface {
+	GetStats() (todoist.Stats, error)
+	CompletedTaskLister
+}
+
+type FitbitHistoryReader interface {
+	GetFitbitActivity() (fitbit.ActivityResponse, error)
+	GetActivitySeries(resource string, start, end time.Time) ([]fitbit.SeriesPoint, error)
+	GetWeightLog(start, end time.Time) ([]fitbit.WeightRecord, error)
+	GetSleep(start, end time.Time) ([]fitbit.SleepLog, error)
+}
+
+type WidgetReader interface {
+	GetWidgetResponse(context.Context, string) (models.WidgetResponse, error)
+}
+
+// backfillChunk is how many days each history request covers, within the
+// limits of Fitbit's weight log and Todoist's completed tasks.
+const backfillChunk = 30
+
+// Snapshotter records a value per day for habit counters, dailies, Todoist
+// completions, Fitbit activity, weight and sleep, and the default widget's
+// metrics, so they can be compared over time.
+type Snapshotter struct {
+	db       SnapshotStore
+	habitica HabiticaTaskRepository
+	// todoist and fitbit are nil when they aren't set up
+	todoist TodoistHistoryReader
+	fitbit  FitbitHistoryReader
+	widget  WidgetReader
+}
+
+func NewSnapshotter(
+	db SnapshotStore,
+	habitica HabiticaTaskRepository,
+	todoist TodoistHistoryReader,
+	fitbit FitbitHistoryReader,
+	widget WidgetReader,
+) *Snapshotter {
+	return &Snapshotter{db: db, habitica: habitica, todoist: todoist, fitbit: fitbit, widget: widget}
+}
+
+// Job records today's snapshot on schedule. Each run replaces the last, so
+// the final run of the day leaves the day's closing values.
+func (s *Snapshotter) Job(schedule string) Job {
+	return Job{
+		Name:     "snapshot",
+		Schedule: schedule,
+		Run:      s.Snapshot,
+	}
+}
+
+// snapshotBatch collects snapshots to save together.
+type snapshotBatch struct {
+	recordedAt time.Time
+	snapshots  []models.Snapshot
+}
+
+func (b *snapshotBatch) add(date, metric, subject string, value float64, goal *float64) {
+	b.snapshots = append(b.snapshots, models.Snapshot{
+		Date:       date,
+		Metric:     metric,
+		Subject:    subject,
+		Value:      value,
+		Goal:       goal,
+		RecordedAt: b.recordedAt,
+	})
+}
+
+// goalValue is a goal for a snapshot, nil when there is none.
+func goalValue(goal int) *float64 {
+	if goal <= 0 {
+		return nil
+	}
+	g := float64(goal)
+	return &g
+}
+
+// dailyGoal is the target of a habit's goal if it is a daily one.
+func dailyGoal(goals map[string]models.HabitGoal, habitId string) *float64 {
+	if g, ok := goals[habitId]; ok && g.Period == models.GoalPeriodDaily {
+		return goalValue(g.Target)
+	}
+	return nil
+}
+
+// Snapshot records today's values. A source that fails is left out and its
+// error returned after the rest are saved.
+func (s *Snapshotter) Snapshot(ctx context.Context) error {
+	now := time.Now()
+	day := now.Format(models.SnapshotDateFormat)
+	batch := &snapshotBatch{recordedAt: now}
+
+	var errs []error
+	for _, collect := range []func(context.Context, string, *snapshotBatch) error{
+		s.habiticaToday,
+		s.todoistToday,
+		s.fitbitToday,
+		s.widgetToday,
+	} {
+		if err := collect(ctx, day, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.db.SaveSnapshots(batch.snapshots); err != nil {
+		errs = append(errs, err)
+	}
+	slog.Info("recorded snapshot", "date", day, "values", len(batch.snapshots), "errors", len(errs))
+	return errors.Join(errs...)
+}
+
+func (s *Snapshotter) habiticaToday(ctx context.Context, day string, batch *snapshotBatch) error {
+	goals, err := s.db.ListHabitGoals()
+	if err != nil {
+		return err
+	}
+	byHabit := habitGoalsById(goals)
+
+	habits, err := s.habitica.GetHabits()
+	if err != nil {
+		return fmt.Errorf("error getting habits for snapshot: %w", err)
+	}
+	for _, h := range habits {
+		batch.add(day, models.SnapshotHabitUp, h.ID, float64(h.CounterUp), dailyGoal(byHabit, h.ID))
+		batch.add(day, models.SnapshotHabitDown, h.ID, float64(h.CounterDown), nil)
+	}
+
+	dailies, err := s.habitica.GetDailys()
+	if err != nil {
+		return fmt.Errorf("error getting dailies for snapshot: %w", err)
+	}
+	var due, done int
+	for _, d := range dailies {
+		if d.IsDue {
+			due++
+			if d.Completed {
+				done++
+			}
+		}
+	}
+	batch.add(day, models.SnapshotDailiesDone, "", float64(done), goalValue(due))
+	batch.add(day, models.SnapshotDailiesDue, "", float64(due), nil)
+	return nil
+}
+
+func (s *Snapshotter) todoistToday(ctx context.Context, day string, batch *snapshotBatch) error {
+	if s.todoist == nil {
+		return nil
+	}
+	stats, err := s.todoist.GetStats()
+	if err != nil {
+		return fmt.Errorf("error getting todoist stats for snapshot: %w", err)
+	}
+	var completed int
+	if len(stats.DaysItems) > 0 {
+		completed = stats.DaysItems[0].TotalCompleted
+	}
+	batch.add(day, models.SnapshotTodoistCompleted, "", float64(completed), goalValue(stats.Goals.DailyGoal))
+	return nil
+}
+
+func (s *Snapshotter) fitbitToday(ctx context.Context, day string, batch *snapshotBatch) error {
+	if s.fitbit == nil {
+		return nil
+	}
+	activity, err := s.fitbit.GetFitbitActivity()
+	if err != nil {
+		return fmt.Errorf("error getting fitbit activity for snapshot: %w", err)
+	}
+	batch.add(day, models.SnapshotSteps, "", float64(activity.Summary.Steps), goalValue(activity.Goals.Steps))
+	batch.add(day, models.SnapshotActiveMinutes, "", float64(activity.Summary.VeryActiveMinutes), goalValue(activity.Goals.ActiveMinutes))
+
+	today := startOfDay(batch.recordedAt)
+	return errors.Join(s.fitbitWeight(today, today, batch), s.fitbitSleep(today, today, batch))
+}
+
+func (s *Snapshotter) widgetToday(ctx context.Context, day string, batch *snapshotBatch) error {
+	resp, err := s.widget.GetWidgetResponse(ctx, models.DefaultWidget)
+	if err != nil {
+		return err
+	}
+	for _, m := range resp.Metrics {
+		var goal *float64
+		if m.Goal != nil {
+			g := float64(*m.Goal)
+			goal = &g
+		}
+		batch.add(day, models.SnapshotWidget, resp.Widget+"."+m.Name, float64(m.Value), goal)
+	}
+	return nil
+}
+
+// Backfill records the days from from to to, inclusive, that have no
+// snapshot yet, from the history the APIs keep: Habitica task history, Todoist
+// completed tasks and Fitbit time series. Days up to yesterday are filled;
+// today is left to the snapshot job. It returns how many values it recorded.
+func (s *Snapshotter) Backfill(ctx context.Context, from, to time.Time) (int, error) {
+	from = startOfDay(from)
+	yesterday := startOfDay(time.Now()).AddDate(0, 0, -1)
+	if to = startOfDay(to); to.After(yesterday) {
+		to = yesterday
+	}
+	if to.Before(from) {
+		return 0, nil
+	}
+	batch := &snapshotBatch{recordedAt: time.Now()}
+
+	var errs []error
+	for _, collect := range []func(context.Context, time.Time, time.Time, *snapshotBatch) error{
+		s.habiticaHistory,
+		s.todoistHistory,
+		s.fitbitHistory,
+	} {
+		if err := collect(ctx, from, to, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	filled, err := s.db.FillSnapshots(batch.snapshots)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	slog.Info("backfilled snapshots", "from", from.Format(models.SnapshotDateFormat),
+		"to", to.Format(models.SnapshotDateFormat), "values", filled, "errors", len(errs))
+	return filled, errors.Join(errs...)
+}
+
+// habiticaHistory sums habit scores per day and counts dailies due and done
+// from task history. Habit goals are today's goals, as past ones aren't kept.
+func (s *Snapshotter) habiticaHistory(ctx context.Context, from, to time.Time, batch *snapshotBatch) error {
+	goals, err := s.db.ListHabitGoals()
+	if err != nil {
+		return err
+	}
+	byHabit := habitGoalsById(goals)
+	inRange := func(t time.Time) (string, bool) {
+		day := startOfDay(t)
+		return day.Format(models.SnapshotDateFormat), !day.Before(from) && !day.After(to)
+	}
+
+	habits, err := s.habitica.GetHabits()
+	if err != nil {
+		return fmt.Errorf("error getting habits for backfill: %w", err)
+	}
+	for _, h := range habits {
+		up := make(map[string]int)
+		down := make(map[string]int)
+		for _, entry := range h.History {
+			if day, ok := inRange(entry.Date.Time); ok {
+				up[day] += entry.ScoredUp
+				down[day] += entry.ScoredDown
+			}
+		}
+		for day := range up {
+			batch.add(day, models.SnapshotHabitUp, h.ID, float64(up[day]), dailyGoal(byHabit, h.ID))
+			batch.add(day, models.SnapshotHabitDown, h.ID, float64(down[day]), nil)
+		}
+	}
+
+	dailies, err := s.habitica.GetDailys()
+	if err != nil {
+		return fmt.Errorf("error getting dailies for backfill: %w", err)
+	}
+	due := make(map[string]int)
+	done := make(map[string]int)
+	for _, d := range dailies {
+		for _, entry := range d.History {
+			// cron writes the entry for a day when the next one starts
+			day, ok := inRange(entry.Date.AddDate(0, 0, -1))
+			if !ok || !entry.IsDue {
+				continue
+			}
+			due[day]++
+			if entry.Completed {
+				done[day]++
+			}
+		}
+	}
+	for day := range due {
+		batch.add(day, models.SnapshotDailiesDone, "", float64(done[day]), goalValue(due[day]))
+		batch.add(day, models.SnapshotDailiesDue, "", float64(due[day]), nil)
+	}
+	return nil
+}
+
+// todoistHistory counts completed tasks per day. The daily goal is today's, as
+// past ones aren't kept.
+func (s *Snapshotter) todoistHistory(ctx context.Context, from, to time.Time, batch *snapshotBatch) error {
+	if s.todoist == nil {
+		return nil
+	}
+	stats, err := s.todoist.GetStats()
+	if err != nil {
+		return fmt.Errorf("error getting todoist goal for backfill: %w", err)
+	}
+	goal := goalValue(stats.Goals.DailyGoal)
+
+	completed := make(map[string]int)
+	err = eachChunk(ctx, from, to, func(start, end time.Time) error {
+		items, err := s.todoist.GetCompletedTasks(start, end.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		for _, data := range items {
+			var item models.TodoistItem
+			if err := json.Unmarshal(data, &item); err != nil {
+				return fmt.Errorf("error decoding completed todoist task: %w", err)
+			}
+			completedAt, err := time.Parse(time.RFC3339, item.CompletedAt)
+			if err != nil {
+				continue
+			}
+			completed[completedAt.Local().Format(models.SnapshotDateFormat)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error getting todoist history: %w", err)
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.SnapshotDateFormat)
+		batch.add(date, models.SnapshotTodoistCompleted, "", float64(completed[date]), goal)
+	}
+	return nil
+}
+
+// fitbitHistory reads the steps and very active minutes series, weight log
+// and sleep log. Steps and active minutes get today's goals, as past ones
+// aren't kept.
+func (s *Snapshotter) fitbitHistory(ctx context.Context, from, to time.Time, batch *snapshotBatch) error {
+	if s.fitbit == nil {
+		return nil
+	}
+	activity, err := s.fitbit.GetFitbitActivity()
+	if err != nil {
+		return fmt.Errorf("error getting fitbit goals for backfill: %w", err)
+	}
+	series := []struct {
+		resource, metric string
+		goal             *float64
+	}{
+		{"steps", models.SnapshotSteps, goalValue(activity.Goals.Steps)},
+		{"minutesVeryActive", models.SnapshotActiveMinutes, goalValue(activity.Goals.ActiveMinutes)},
+	}
+
+	err = eachChunk(ctx, from, to, func(start, end time.Time) error {
+		for _, series := range series {
+			points, err := s.fitbit.GetActivitySeries(series.resource, start, end)
+			if err != nil {
+				return err
+			}
+			for _, p := range points {
+				if value, err := strconv.ParseFloat(p.Value, 64); err == nil {
+					batch.add(p.DateTime, series.metric, "", value, series.goal)
+				}
+			}
+		}
+		return errors.Join(s.fitbitWeight(start, end, batch), s.fitbitSleep(start, end, batch))
+	})
+	if err != nil {
+		return fmt.Errorf("error getting fitbit history: %w", err)
+	}
+	return nil
+}
+
+// fitbitWeight adds the last weight logged on each day from start to end.
+func (s *Snapshotter) fitbitWeight(start, end time.Time, batch *snapshotBatch) error {
+	records, err := s.fitbit.GetWeightLog(start, end)
+	if err != nil {
+		return fmt.Errorf("error getting fitbit weight: %w", err)
+	}
+	weight := make(map[string]float64)
+	for _, r := range records {
+		weight[r.Date] = r.Weight
+	}
+	for day, value := range weight {
+		batch.add(day, models.SnapshotWeight, "", value, nil)
+	}
+	return nil
+}
+
+// fitbitSleep adds the minutes asleep each night from start to end, naps
+// included, under the day the sleep ended.
+func (s *Snapshotter) fitbitSleep(start, end time.Time, batch *snapshotBatch) error {
+	logs, err := s.fitbit.GetSleep(start, end)
+	if err != nil {
+		return fmt.Errorf("error getting fitbit sleep: %w", err)
+	}
+	minutes := make(map[string]int)
+	for _, l := range logs {
+		minutes[l.DateOfSleep] += l.MinutesAsleep
+	}
+	for day, value := range minutes {
+		batch.add(day, models.SnapshotSleepMinutes, "", float64(value), nil)
+	}
+	return nil
+}
+
+// eachChunk calls fn for consecutive ranges of at most backfillChunk days
+// covering from to to, inclusive.
+func eachChunk(ctx context.Context, from, to time.Time, fn func(start, end time.Time) error) error {
+	for start := from; !start.After(to); start = start.AddDate(0, 0, backfillChunk) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start.AddDate(0, 0, backfillChunk-1)
+		if end.After(to) {
+			end = to
+		}
+		if err := fn(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"misc/internal/models"
+	"sort"
+	"time"
+)
+
+type StatsStore interface {
+	ListSnapshots(models.SnapshotFilter) ([]models.Snapshot, error)
+}
+
+// StatsService works out averages, goal hit rates, streaks and week over week
+// changes from the daily snapshots.
+type StatsService struct {
+	db StatsStore
+}
+
+func NewStatsService(db StatsStore) *StatsService {
+	return &StatsService{db: db}
+}
+
+// Summary returns the stats over the last days days of every metric and
+// subject that has snapshots.
+func (s *StatsService) Summary(days int) ([]models.MetricStats, error) {
+	snapshots, err := s.db.ListSnapshots(models.SnapshotFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	type seriesKey struct{ metric, subject string }
+	series := make(map[seriesKey][]models.Snapshot)
+	for _, snap := range snapshots {
+		key := seriesKey{snap.Metric, snap.Subject}
+		series[key] = append(series[key], snap)
+	}
+
+	today := time.Now()
+	stats := make([]models.MetricStats, 0, len(series))
+	for key, snaps := range series {
+		st := ComputeStats(snaps, today, days, 0)
+		st.Metric, st.Subject = key.metric, key.subject
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Metric != stats[j].Metric {
+			return stats[i].Metric < stats[j].Metric
+		}
+		return stats[i].Subject < stats[j].Subject
+	})
+	return stats, nil
+}
+
+// Metric returns the stats over the last days days of one metric and subject,
+// with its rolling average over window days.
+func (s *StatsService) Metric(metric, subject string, days, window int) (models.MetricStats, error) {
+	snapshots, err := s.db.ListSnapshots(models.SnapshotFilter{Metric: metric, Subject: subject})
+	if err != nil {
+		return models.MetricStats{}, err
+	}
+	// an empty subject filter matches every subject
+	series := make([]models.Snapshot, 0, len(snapshots))
+	for _, snap := range snapshots {
+		if snap.Subject == subject {
+			series = append(series, snap)
+		}
+	}
+
+	stats := ComputeStats(series, time.Now(), days, window)
+	stats.Metric, stats.Subject = metric, subject
+	return stats, nil
+}
+
+// ComputeStats summarizes the snapshots of one metric and subject, oldest
+// first, as of today. Averages and the hit rate cover the last days days and
+// streaks the whole series. A rolling average over window days is added when
+// window is above 0.
+//
+// A day reaches its goal when its value is at least the goal. Days without a
+// goal neither extend nor break a streak, and days without a snapshot break
+// it. Today only counts once its goal is reached.
+func ComputeStats(snapshots []models.Snapshot, today time.Time, days, window int) models.MetricStats {
+	today = startOfDay(today)
+	from := today.AddDate(0, 0, 1-days)
+	stats := models.MetricStats{
+		From: from.Format(models.SnapshotDateFormat),
+		To:   today.Format(models.SnapshotDateFormat),
+	}
+
+	byDate := make(map[string]models.Snapshot, len(snapshots))
+	for _, snap := range snapshots {
+		byDate[snap.Date] = snap
+	}
+	// reached reports whether a day with a snapshot had a goal and reached it,
+	// and whether the day counts toward goals at all
+	reached := func(day time.Time, snap models.Snapshot) (bool, bool) {
+		if snap.Goal == nil {
+			return false, false
+		}
+		hit := snap.Value >= *snap.Goal
+		return hit, hit || day.Before(today)
+	}
+
+	var sum float64
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		snap, ok := byDate[day.Format(models.SnapshotDateFormat)]
+		if !ok {
+			continue
+		}
+		stats.Days++
+		sum += snap.Value
+		if hit, counts := reached(day, snap); counts {
+			stats.GoalDays++
+			if hit {
+				stats.HitDays++
+			}
+		}
+	}
+	if stats.Days > 0 {
+		stats.Average = sum / float64(stats.Days)
+	}
+	if stats.GoalDays > 0 {
+		rate := float64(stats.HitDays) / float64(stats.GoalDays)
+		stats.HitRate = &rate
+	}
+
+	if window > 0 {
+		stats.RollingAverage = make([]models.StatsPoint, 0, days)
+		for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+			if avg, ok := averageBetween(byDate, day.AddDate(0, 0, 1-window), day); ok {
+				stats.RollingAverage = append(stats.RollingAverage, models.StatsPoint{
+					Date:  day.Format(models.SnapshotDateFormat),
+					Value: avg,
+				})
+			}
+		}
+	}
+
+	if len(snapshots) > 0 {
+		first, err := time.ParseInLocation(models.SnapshotDateFormat, snapshots[0].Date, time.Local)
+		if err == nil {
+			stats.CurrentStreak, stats.LongestStreak = streaks(byDate, first, today, reached)
+		}
+	}
+
+	stats.ThisWeek, _ = averageBetween(byDate, today.AddDate(0, 0, -6), today)
+	lastWeek, ok := averageBetween(byDate, today.AddDate(0, 0, -13), today.AddDate(0, 0, -7))
+	stats.LastWeek = lastWeek
+	if ok && lastWeek != 0 {
+		change := (stats.ThisWeek - lastWeek) / lastWeek
+		stats.WeekOverWeek = &change
+	}
+	return stats
+}
+
+// streaks returns the current and longest runs of days from first to today
+// that reached their goal.
+func streaks(
+	byDate map[string]models.Snapshot,
+	first, today time.Time,
+	reached func(time.Time, models.Snapshot) (bool, bool),
+) (int, int) {
+	var current, longest int
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		snap, ok := byDate[day.Format(models.SnapshotDateFormat)]
+		if !ok {
+			if day.Before(today) {
+				current = 0
+			}
+			continue
+		}
+		hit, counts := reached(day, snap)
+		switch {
+		case hit:
+			current++
+			longest = max(longest, current)
+		case counts:
+			current = 0
+		}
+	}
+	return current, longest
+}
+
+// averageBetween averages the values from start to end, inclusive, and
+// reports false if there are none.
+func averageBetween(byDate map[string]models.Snapshot, start, end time.Time) (float64, bool) {
+	var sum float64
+	var n int
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if snap, ok := byDate[day.Format(models.SnapshotDateFormat)]; ok {
+			sum += snap.Value
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"sync"
+	"time"
+)
+
+type TodoSyncStore interface {
+	ListTodoSyncProjects() ([]models.TodoSyncProject, error)
+	IsTodoSyncProject(string) (bool, error)
+	FindTodoLinkByTodoist(string) (*models.TodoLink, error)
+	FindTodoLinkByHabitica(string) (*models.TodoLink, error)
+	CreateTodoLink(models.TodoLink) (models.TodoLink, error)
+	UpdateTodoLink(models.TodoLink) error
+	DeleteTodoLink(int64) error
+}
+
+type HabiticaTodoWriter interface {
+	TaskUpdater
+	CreateTodo(string) (habitica.Task, error)
+	UpdateTaskText(string, string) error
+	DeleteTask(string) error
+}
+
+type TodoistTaskSyncer interface {
+	CloseTask(string) error
+	ReopenTask(string) error
+	UpdateTaskContent(string, string) error
+	GetProjectTasks(string) ([]todoist.Task, error)
+}
+
+// TodoSync mirrors the tasks of selected Todoist projects as Habitica todos
+// and keeps completion and text in sync both ways. When both sides change,
+// the later change wins. A write the sync makes comes back as a webhook from
+// the side it was written to. The link keeps the write as pending until then,
+// and the webhook is journaled like any other event but not synced back, even
+// if the link has changed again in the meantime.
+//
+// Recurring Todoist tasks aren't mirrored, since a Habitica todo can only be
+// completed once.
+type TodoSync struct {
+	db       TodoSyncStore
+	habitica HabiticaTodoWriter
+	todoist  TodoistTaskSyncer
+
+	// serialises handlers, which read and then update links
+	mu sync.Mutex
+}
+
+func NewTodoSync(db TodoSyncStore, habitica HabiticaTodoWriter, todoist TodoistTaskSyncer) *TodoSync {
+	return &TodoSync{db: db, habitica: habitica, todoist: todoist}
+}
+
+// Register adds the sync's handlers to the dispatchers.
+func (s *TodoSync) Register(todoists *TodoistDispatcher, habiticas *HabiticaDispatcher) {
+	todoists.On(models.TodoistItemAdded, s.HandleItemAdded)
+	todoists.On(models.TodoistItemUpdated, s.HandleItemUpdated)
+	todoists.On(models.TodoistItemCompleted, s.HandleItemCompleted)
+	todoists.On(models.TodoistItemUncompleted, s.HandleItemUncompleted)
+	todoists.On(models.TodoistItemDeleted, s.HandleItemDeleted)
+
+	habiticas.On(HabiticaTaskScored, s.HandleTaskScored)
+	habiticas.On(HabiticaTaskUpdated, s.HandleTaskUpdated)
+	habiticas.On(HabiticaTaskDeleted, s.HandleTaskDeleted)
+}
+
+// Job mirrors the open tasks of the selected projects that aren't linked yet,
+// which covers tasks that existed before their project was selected.
+func (s *TodoSync) Job(schedule string) Job {
+	return Job{
+		Name:     "todo-sync",
+		Schedule: schedule,
+		Run:      s.MirrorProjects,
+	}
+}
+
+func (s *TodoSync) MirrorProjects(ctx context.Context) error {
+	projects, err := s.db.ListTodoSyncProjects()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var mirrored int
+	for _, project := range projects {
+		tasks, err := s.todoist.GetProjectTasks(project.TodoistProjectId)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if task.Due.IsRecurring {
+				continue
+			}
+			link, err := s.db.FindTodoLinkByTodoist(task.ID)
+			if err != nil {
+				return err
+			}
+			if link != nil {
+				continue
+			}
+			if err := s.mirror(task.ID, task.ProjectId, task.Content, time.Now()); err != nil {
+				return err
+			}
+			mirrored++
+		}
+	}
+	slog.Info("mirrored todoist tasks", "projects", len(projects), "mirrored", mirrored)
+	return nil
+}
+
+// HandleItemAdded mirrors a new task in a selected project.
+func (s *TodoSync) HandleItemAdded(event models.TodoistWebhook) error {
+	item, err := event.Item()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByTodoist(item.Id)
+	if err != nil || link != nil {
+		return err
+	}
+	return s.mirrorIfSelected(item, todoistChangedAt(event, item.AddedAt))
+}
+
+// HandleItemUpdated syncs a changed task's content. A task moved into a
+// selected project is mirrored, and one moved out of it is unmirrored.
+func (s *TodoSync) HandleItemUpdated(event models.TodoistWebhook) error {
+	item, err := event.Item()
+	if err != nil {
+		return err
+	}
+	at := todoistChangedAt(event, item.UpdatedAt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByTodoist(item.Id)
+	if err != nil {
+		return err
+	}
+	if link == nil {
+		return s.mirrorIfSelected(item, at)
+	}
+
+	if item.ProjectId != link.TodoistProjectId {
+		selected, err := s.db.IsTodoSyncProject(item.ProjectId)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			slog.Info("task moved out of synced project, removing habitica todo", "todoistTaskId", item.Id)
+			return s.unmirror(*link)
+		}
+		link.TodoistProjectId = item.ProjectId
+	}
+
+	if s.echo(link, models.TodoistEventSource, models.TodoChangeContent, item.Content) ||
+		item.Content == link.Content || s.stale(*link, at, models.TodoistEventSource) {
+		return s.db.UpdateTodoLink(*link)
+	}
+	if err := s.habitica.UpdateTaskText(link.HabiticaTaskId, item.Content); err != nil {
+		return fmt.Errorf("error updating habitica todo: %w", err)
+	}
+	s.wrote(link, at, models.TodoistEventSource, models.TodoChangeContent, item.Content)
+	link.Content = item.Content
+	return s.db.UpdateTodoLink(*link)
+}
+
+// HandleItemCompleted completes the task's Habitica todo.
+func (s *TodoSync) HandleItemCompleted(event models.TodoistWebhook) error {
+	return s.handleTodoistCompletion(event, true)
+}
+
+// HandleItemUncompleted uncompletes the task's Habitica todo.
+func (s *TodoSync) HandleItemUncompleted(event models.TodoistWebhook) error {
+	return s.handleTodoistCompletion(event, false)
+}
+
+func (s *TodoSync) handleTodoistCompletion(event models.TodoistWebhook, completed bool) error {
+	item, err := event.Item()
+	if err != nil {
+		return err
+	}
+	changedAt := item.UpdatedAt
+	if completed && item.CompletedAt != "" {
+		changedAt = item.CompletedAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByTodoist(item.Id)
+	if err != nil || link == nil {
+		return err
+	}
+	return s.syncCompletion(*link, completed, todoistChangedAt(event, changedAt), models.TodoistEventSource)
+}
+
+// HandleItemDeleted deletes the task's Habitica todo.
+func (s *TodoSync) HandleItemDeleted(event models.TodoistWebhook) error {
+	item, err := event.Item()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByTodoist(item.Id)
+	if err != nil || link == nil {
+		return err
+	}
+	return s.unmirror(*link)
+}
+
+// HandleTaskScored completes or reopens the Todoist task of a scored todo.
+func (s *TodoSync) HandleTaskScored(event models.HabiticaWebhook) error {
+	if event.Task.Type != "todo" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByHabitica(event.Task.Id)
+	if err != nil || link == nil {
+		return err
+	}
+	completed := event.Direction == "up"
+	return s.syncCompletion(*link, completed, habiticaChangedAt(event), models.HabiticaEventSource)
+}
+
+// HandleTaskUpdated syncs a changed todo's text to its Todoist task.
+func (s *TodoSync) HandleTaskUpdated(event models.HabiticaWebhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByHabitica(event.Task.Id)
+	if err != nil || link == nil {
+		return err
+	}
+
+	if s.echo(link, models.HabiticaEventSource, models.TodoChangeContent, event.Task.Text) {
+		return s.db.UpdateTodoLink(*link)
+	}
+	at := habiticaChangedAt(event)
+	if event.Task.Text == link.Content || s.stale(*link, at, models.HabiticaEventSource) {
+		return nil
+	}
+	if err := s.todoist.UpdateTaskContent(link.TodoistTaskId, event.Task.Text); err != nil {
+		return fmt.Errorf("error updating todoist task: %w", err)
+	}
+	s.wrote(link, at, models.HabiticaEventSource, models.TodoChangeContent, event.Task.Text)
+	link.Content = event.Task.Text
+	return s.db.UpdateTodoLink(*link)
+}
+
+// HandleTaskDeleted unlinks a deleted todo. The Todoist task is kept, since
+// Todoist is where mirrored tasks come from.
+func (s *TodoSync) HandleTaskDeleted(event models.HabiticaWebhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, err := s.db.FindTodoLinkByHabitica(event.Task.Id)
+	if err != nil || link == nil {
+		return err
+	}
+	slog.Info("habitica todo deleted, unlinking todoist task", "todoistTaskId", link.TodoistTaskId)
+	return s.db.DeleteTodoLink(link.Id)
+}
+
+// syncCompletion applies a completion change from source to the other side,
+// unless it is the echo of the sync's own write, the link already has that
+// state or a later change already won.
+func (s *TodoSync) syncCompletion(link models.TodoLink, completed bool, at time.Time, source string) error {
+	change := models.CompletionChange(completed)
+	if s.echo(&link, source, change, "") {
+		return s.db.UpdateTodoLink(link)
+	}
+	if link.Completed == completed || s.stale(link, at, source) {
+		return nil
+	}
+
+	var err error
+	switch {
+	case source == models.TodoistEventSource && completed:
+		err = s.habitica.ScoreDaily(link.HabiticaTaskId)
+	case source == models.TodoistEventSource:
+		err = s.habitica.UnscoreTask(link.HabiticaTaskId)
+	case completed:
+		err = s.todoist.CloseTask(link.TodoistTaskId)
+	default:
+		err = s.todoist.ReopenTask(link.TodoistTaskId)
+	}
+	if err != nil {
+		return fmt.Errorf("error syncing completion of todoist task %s: %w", link.TodoistTaskId, err)
+	}
+
+	slog.Info("synced todo completion", "from", source, "todoistTaskId", link.TodoistTaskId, "completed", completed)
+	s.wrote(&link, at, source, change, "")
+	link.Completed = completed
+	return s.db.UpdateTodoLink(link)
+}
+
+// stale reports whether a change made at at loses to the link's last write.
+func (s *TodoSync) stale(link models.TodoLink, at time.Time, source string) bool {
+	if !at.Before(link.UpdatedAt) {
+		return false
+	}
+	slog.Info("ignoring older todo change", "from", source, "todoistTaskId", link.TodoistTaskId,
+		"changedAt", at, "lastWrite", link.UpdatedAt, "lastWriteBy", link.UpdatedBy)
+	return true
+}
+
+// echo reports whether a change from source is the webhook of one of the
+// sync's own writes, and forgets the write if so.
+func (s *TodoSync) echo(link *models.TodoLink, source, change, content string) bool {
+	if !link.TakeEcho(source, change, content, time.Now()) {
+		return false
+	}
+	slog.Info("ignoring echo of synced todo change", "from", source, "change", change, "todoistTaskId", link.TodoistTaskId)
+	return true
+}
+
+// wrote records that a change from source was written to the other side, and
+// that its webhook will come back from there.
+func (s *TodoSync) wrote(link *models.TodoLink, at time.Time, source, change, content string) {
+	link.UpdatedAt = at
+	link.UpdatedBy = source
+	to := models.HabiticaEventSource
+	if source == models.HabiticaEventSource {
+		to = models.TodoistEventSource
+	}
+	link.Expect(models.TodoEcho{Source: to, Change: change, Content: content, At: time.Now()})
+}
+
+func (s *TodoSync) mirrorIfSelected(item models.TodoistItem, at time.Time) error {
+	if item.Checked || (item.Due != nil && item.Due.IsRecurring) {
+		return nil
+	}
+	selected, err := s.db.IsTodoSyncProject(item.ProjectId)
+	if err != nil || !selected {
+		return err
+	}
+	return s.mirror(item.Id, item.ProjectId, item.Content, at)
+}
+
+func (s *TodoSync) mirror(taskId, projectId, content string, at time.Time) error {
+	todo, err := s.habitica.CreateTodo(content)
+	if err != nil {
+		return fmt.Errorf("error creating habitica todo: %w", err)
+	}
+	_, err = s.db.CreateTodoLink(models.TodoLink{
+		TodoistTaskId:    taskId,
+		HabiticaTaskId:   todo.ID,
+		TodoistProjectId: projectId,
+		Content:          content,
+		UpdatedAt:        at,
+		UpdatedBy:        models.TodoistEventSource,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("mirrored todoist task as habitica todo", "todoistTaskId", taskId, "habiticaTaskId", todo.ID)
+	return nil
+}
+
+func (s *TodoSync) unmirror(link models.TodoLink) error {
+	if err := s.habitica.DeleteTask(link.HabiticaTaskId); err != nil {
+		return fmt.Errorf("error deleting habitica todo: %w", err)
+	}
+	return s.db.DeleteTodoLink(link.Id)
+}
+
+// todoistChangedAt is when a Todoist change happened: the item's own
+// timestamp, else when the webhook was triggered, else now.
+func todoistChangedAt(event models.TodoistWebhook, itemTime string) time.Time {
+	for _, s := range []string{itemTime, event.TriggeredAt} {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+func habiticaChangedAt(event models.HabiticaWebhook) time.Time {
+	if t, err := time.Parse(time.RFC3339, event.Task.UpdatedAt); err == nil {
+		return t
+	}
+	return time.Now()
+}
@@ -1,11 +1,13 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"misc/clients/todoist"
 	"net/http"
+	"time"
 
 	"github.com/google/go-querystring/query"
 )
@@ -70,3 +72,194 @@ func (t *TodoistService) GetStats() (todoist.Stats, error) {
 
 	return stats, nil
 }
+
+func (t *TodoistService) GetProjects() ([]todoist.Project, error) {
+	req, err := t.restClient.NewTodoistRequest(http.MethodGet, "projects", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create todoist projects request: %w", err)
+	}
+
+	q := req.URL.Query()
+	q.Add("limit", "200")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling todoist for projects: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+
+	var projectResp todoist.ProjectResp
+	err = json.NewDecoder(resp.Body).Decode(&projectResp)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding todoist projects resp: %w", err)
+	}
+
+	return projectResp.Projects, nil
+}
+
+// CreateTask adds a task with the given content, to the inbox when projectId
+// is empty.
+func (t *TodoistService) CreateTask(content, projectId string) error {
+	body, err := json.Marshal(todoist.NewTask{Content: content, ProjectId: projectId})
+	if err != nil {
+		return fmt.Errorf("unable to encode todoist task: %w", err)
+	}
+	req, err := t.restClient.NewTodoistRequest(http.MethodPost, "tasks", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create todoist create task request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling todoist to create task: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CloseTask completes a task.
+func (t *TodoistService) CloseTask(taskId string) error {
+	req, err := t.restClient.NewTodoistRequest(http.MethodPost, fmt.Sprintf("tasks/%s/close", taskId), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create todoist close task request: %w", err)
+	}
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling todoist to close task: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetCompletedTasks returns the tasks completed between since and until.
+func (t *TodoistService) GetCompletedTasks(since, until time.Time) ([]json.RawMessage, error) {
+	items := make([]json.RawMessage, 0)
+	cursor := ""
+	for {
+		req, err := t.restClient.NewTodoistRequest(http.MethodGet, "tasks/completed/by_completion_date", nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create todoist completed tasks request: %w", err)
+		}
+		q := req.URL.Query()
+		q.Add("since", since.UTC().Format(time.RFC3339))
+		q.Add("until", until.UTC().Format(time.RFC3339))
+		q.Add("limit", "200")
+		if cursor != "" {
+			q.Add("cursor", cursor)
+		}
+		req.URL.RawQuery = q.Encode()
+
+		page, err := t.getCompletedTasksPage(req)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.NextCursor == nil || *page.NextCursor == "" {
+			return items, nil
+		}
+		cursor = *page.NextCursor
+	}
+}
+
+func (t *TodoistService) getCompletedTasksPage(req *http.Request) (todoist.CompletedTasksResp, error) {
+	var page todoist.CompletedTasksResp
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return page, fmt.Errorf("error calling todoist for completed tasks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return page, fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return page, fmt.Errorf("error decoding todoist completed tasks resp: %w", err)
+	}
+	return page, nil
+}
+
+// ReopenTask uncompletes a task.
+func (t *TodoistService) ReopenTask(taskId string) error {
+	req, err := t.restClient.NewTodoistRequest(http.MethodPost, fmt.Sprintf("tasks/%s/reopen", taskId), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create todoist reopen task request: %w", err)
+	}
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling todoist to reopen task: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// UpdateTaskContent changes the content of a task.
+func (t *TodoistService) UpdateTaskContent(taskId, content string) error {
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return fmt.Errorf("unable to encode todoist task: %w", err)
+	}
+	req, err := t.restClient.NewTodoistRequest(http.MethodPost, fmt.Sprintf("tasks/%s", taskId), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create todoist update task request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling todoist to update task: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetProjectTasks returns the open tasks of a project.
+func (t *TodoistService) GetProjectTasks(projectId string) ([]todoist.Task, error) {
+	req, err := t.restClient.NewTodoistRequest(http.MethodGet, "tasks", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create todoist project tasks request: %w", err)
+	}
+	q := req.URL.Query()
+	q.Add("project_id", projectId)
+	q.Add("limit", "200")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := t.restClient.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling todoist for project tasks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error from todoist api: %d", resp.StatusCode)
+	}
+
+	var taskResp todoist.TaskResp
+	if err := json.NewDecoder(resp.Body).Decode(&taskResp); err != nil {
+		return nil, fmt.Errorf("error decoding todoist project tasks resp: %w", err)
+	}
+	return taskResp.Tasks, nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"misc/internal/models"
+)
+
+type TodoistEventHandler func(models.TodoistWebhook) error
+
+// TodoistDispatcher routes Todoist webhook events to the handlers registered
+// for their event name.
+type TodoistDispatcher struct {
+	handlers map[string][]TodoistEventHandler
+}
+
+func NewTodoistDispatcher() *TodoistDispatcher {
+	return &TodoistDispatcher{handlers: make(map[string][]TodoistEventHandler)}
+}
+
+// On registers handler for events named eventName, e.g. models.TodoistItemCompleted.
+func (d *TodoistDispatcher) On(eventName string, handler TodoistEventHandler) {
+	d.handlers[eventName] = append(d.handlers[eventName], handler)
+}
+
+// Dispatch runs every handler registered for the event. It reports whether
+// any handler was registered, and returns the errors from all handlers that
+// failed.
+func (d *TodoistDispatcher) Dispatch(event models.TodoistWebhook) (bool, error) {
+	handlers := d.handlers[event.EventName]
+	if len(handlers) == 0 {
+		slog.Info("no handlers for todoist event", "event", event.EventName)
+		return false, nil
+	}
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(event); err != nil {
+			errs = append(errs, fmt.Errorf("error handling %s: %w", event.EventName, err))
+		}
+	}
+	return true, errors.Join(errs...)
+}
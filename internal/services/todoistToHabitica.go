@@ -4,53 +4,53 @@ import (
 	"fmt"
 	"log/slog"
 	"misc/internal/models"
-	"strings"
 )
 
 type TodoistHabiticaRuleStore interface {
-	GetTodoistHabiticaTextRules() ([]models.TodoistHabiticaTextRule, error)
-	GetTodoistHabiticaProjectRule(string) (models.TodoistHabiticaProjectRule, error)
+	GetLatestCompletions(string) ([]models.TodoistCompletion, error)
+	MarkCompletionUndone(int64) error
 }
 
+// TodoistHabiticaService undoes the Habitica scores the engine recorded for a
+// Todoist completion when the item is uncompleted.
 type TodoistHabiticaService struct {
 	db      TodoistHabiticaRuleStore
-	updater DailyUpdater
+	updater TaskUpdater
 }
 
-func NewTodoistHabiticaService(db TodoistHabiticaRuleStore, updater DailyUpdater) TodoistHabiticaService {
+func NewTodoistHabiticaService(db TodoistHabiticaRuleStore, updater TaskUpdater) TodoistHabiticaService {
 	return TodoistHabiticaService{
 		db:      db,
 		updater: updater,
 	}
 }
 
-func (s *TodoistHabiticaService) ScoreTask(taskStr, projectId string) error {
-	// check text rules first, if we hit one score the task and return
-	rules, err := s.db.GetTodoistHabiticaTextRules()
-
+// HandleItemUncompleted undoes the Habitica score recorded for the most recent
+// completion of the Todoist item, scoring the habit down or unchecking the
+// daily.
+func (s *TodoistHabiticaService) HandleItemUncompleted(event models.TodoistWebhook) error {
+	item, err := event.Item()
 	if err != nil {
-		return fmt.Errorf("error getting text rules: %w", err)
+		return err
 	}
 
-	slog.Info("got text rules", "rules", rules, "taskStr", taskStr)
-	for _, rule := range rules {
-		if strings.HasPrefix(strings.ToLower(taskStr), rule.Rule) {
-			if err := s.updater.ScoreDaily(rule.HabitId); err != nil {
-				return fmt.Errorf("error scoring habit: %w", err)
-			}
-			return nil
-		}
-	}
-
-	// check project rule
-	rule, err := s.db.GetTodoistHabiticaProjectRule(projectId)
-	slog.Info("got project rule", "rule", rule)
+	completions, err := s.db.GetLatestCompletions(item.Id)
 	if err != nil {
-		return fmt.Errorf("error getting project rule: %w", err)
+		return fmt.Errorf("error getting completion links: %w", err)
 	}
-	err = s.updater.ScoreDaily(rule.HabitId)
-	if err != nil {
-		return fmt.Errorf("error scoring habit: %w", err)
+	if len(completions) == 0 {
+		slog.Info("no scored completion to undo", "taskId", item.Id, "taskName", item.Content)
+		return nil
+	}
+
+	for _, c := range completions {
+		slog.Info("undoing habitica score", "taskName", item.Content, "habiticaTaskId", c.HabiticaTaskId)
+		if err := s.updater.UnscoreTask(c.HabiticaTaskId); err != nil {
+			return fmt.Errorf("error unscoring habitica task: %w", err)
+		}
+		if err := s.db.MarkCompletionUndone(c.Id); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"misc/internal/models"
+	"strconv"
+	"time"
+)
+
+type EventStore interface {
+	CreateEvent(models.Event) (models.Event, error)
+	GetEvent(int64) (models.Event, error)
+	ListEvents(models.EventFilter) ([]models.Event, error)
+	UpdateEventResult(models.Event) error
+}
+
+type DeliveryStore interface {
+	ClaimDelivery(string, string, int64, time.Duration) (bool, error)
+}
+
+type AutomationRunner interface {
+	Run(models.AutomationEvent) ([]models.AutomationRun, error)
+}
+
+type TodoistEventDispatcher interface {
+	Dispatch(models.TodoistWebhook) (bool, error)
+}
+
+type HabiticaEventDispatcher interface {
+	Dispatch(models.HabiticaWebhook) (bool, error)
+}
+
+// AutomationStore is what the automations read rules and history from.
+type AutomationStore interface {
+	EngineStore
+	TodoistHabiticaRuleStore
+}
+
+// NewAutomations wires the automation engine and the Todoist and Habitica
+// event handlers to db and actuators, returning them for a WebhookService to
+// hand events to. More handlers can be registered on the dispatchers. The
+// engine publishes the automations it runs to activity, if set.
+func NewAutomations(db AutomationStore, actuators Actuators, activity ActivityPublisher) (*Engine, *TodoistDispatcher, *HabiticaDispatcher) {
+	engine := NewEngine(db, actuators, activity)
+	todoHabService := NewTodoistHabiticaService(db, actuators.Habitica)
+
+	todoistEvents := NewTodoistDispatcher()
+	todoistEvents.On(models.TodoistItemUncompleted, todoHabService.HandleItemUncompleted)
+
+	return engine, todoistEvents, NewHabiticaDispatcher()
+}
+
+// DeliveryTTL is how long a processed delivery is remembered. Todoist and
+// Habitica both give up retrying well within a day.
+const DeliveryTTL = 48 * time.Hour
+
+// WebhookService journals every inbound webhook before handing it to the
+// automation services, so failed events can be replayed later. Retried
+// deliveries are journaled but not processed a second time.
+type WebhookService struct {
+	events      EventStore
+	deliveries  DeliveryStore
+	automations AutomationRunner
+	todoists    TodoistEventDispatcher
+	habiticas   HabiticaEventDispatcher
+	cache       CacheInvalidator
+	activity    ActivityPublisher
+}
+
+func NewWebhookService(
+	events EventStore,
+	deliveries DeliveryStore,
+	automations AutomationRunner,
+	todoists TodoistEventDispatcher,
+	habiticas HabiticaEventDispatcher,
+	cache CacheInvalidator,
+	activity ActivityPublisher,
+) *WebhookService {
+	return &WebhookService{
+		events:      events,
+		deliveries:  deliveries,
+		automations: automations,
+		todoists:    todoists,
+		habiticas:   habiticas,
+		cache:       cache,
+		activity:    activity,
+	}
+}
+
+// Receive records a webhook payload from source and processes it. deliveryId
+// is the sender's delivery identifier, if it sends one. The returned event
+// reflects the outcome; a processing failure is recorded on the event rather
+// than returned, so the caller can still acknowledge delivery.
+func (w *WebhookService) Receive(source, deliveryId string, payload []byte) (models.Event, error) {
+	envelope := parseEnvelope(payload)
+	event, err := w.events.CreateEvent(models.Event{
+		Source:      source,
+		DeliveryKey: envelope.deliveryKey(source, deliveryId, payload),
+		EventType:   envelope.eventType(source),
+		Payload:     string(payload),
+	})
+	if err != nil {
+		return event, fmt.Errorf("error journaling %s event: %w", source, err)
+	}
+
+	claimed, err := w.deliveries.ClaimDelivery(event.DeliveryKey, source, event.Id, DeliveryTTL)
+	if err != nil {
+		return event, fmt.Errorf("error checking for duplicate delivery: %w", err)
+	}
+	if !claimed {
+		slog.Info("ignoring duplicate delivery", "id", event.Id, "key", event.DeliveryKey)
+		return w.recordResult(event, models.EventStatusDuplicate, nil)
+	}
+	return w.process(event)
+}
+
+// Replay reprocesses the journaled events with the given ids.
+func (w *WebhookService) Replay(ids []int64) ([]models.Event, error) {
+	events := make([]models.Event, 0, len(ids))
+	for _, id := range ids {
+		event, err := w.events.GetEvent(id)
+		if err != nil {
+			return events, err
+		}
+		event, err = w.process(event)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ReplayFailed reprocesses every journaled event that failed, oldest first.
+func (w *WebhookService) ReplayFailed() ([]models.Event, error) {
+	failed, err := w.events.ListEvents(models.EventFilter{Status: models.EventStatusFailed})
+	if err != nil {
+		return nil, fmt.Errorf("error listing failed events: %w", err)
+	}
+
+	ids := make([]int64, 0, len(failed))
+	for i := len(failed) - 1; i >= 0; i-- {
+		ids = append(ids, failed[i].Id)
+	}
+	return w.Replay(ids)
+}
+
+func (w *WebhookService) process(event models.Event) (models.Event, error) {
+	status, _, err := w.handle(event)
+	w.invalidate(event.Source)
+	return w.recordResult(event, status, err)
+}
+
+// invalidate drops what the cache holds from the API an event came from,
+// since the event means something there changed. Changes the automations
+// make in the other API come back as webhooks of their own.
+func (w *WebhookService) invalidate(source string) {
+	if w.cache == nil {
+		return
+	}
+	switch source {
+	case models.HabiticaEventSource:
+		w.cache.Invalidate(CacheHabitica)
+	case models.TodoistEventSource:
+		w.cache.Invalidate(CacheTodoist)
+	}
+}
+
+// handle runs the automations for an event and returns its resulting status
+// and what the automations did.
+func (w *WebhookService) handle(event models.Event) (string, []models.AutomationRun, error) {
+	switch event.Source {
+	case models.HabiticaEventSource:
+		return w.processHabitica(event)
+	case models.TodoistEventSource:
+		return w.processTodoist(event)
+	}
+	return models.EventStatusFailed, nil, fmt.Errorf("unknown event source %q", event.Source)
+}
+
+func (w *WebhookService) recordResult(event models.Event, status string, err error) (models.Event, error) {
+	event.Status = status
+	event.Error = ""
+	if err != nil {
+		event.Error = err.Error()
+		slog.Error("error processing event", "id", event.Id, "source", event.Source, "err", err)
+	}
+	event.Attempts++
+	processedAt := time.Now().UTC()
+	event.ProcessedAt = &processedAt
+
+	if err := w.events.UpdateEventResult(event); err != nil {
+		return event, fmt.Errorf("error recording event result: %w", err)
+	}
+	publish(w.activity, models.Activity{
+		Kind:    models.ActivityWebhook,
+		Source:  event.Source,
+		Name:    event.EventType,
+		Subject: strconv.FormatInt(event.Id, 10),
+		Outcome: event.Status,
+		Error:   event.Error,
+	})
+	return event, nil
+}
+
+func (w *WebhookService) processHabitica(event models.Event) (string, []models.AutomationRun, error) {
+	var req models.HabiticaWebhook
+	if err := json.Unmarshal([]byte(event.Payload), &req); err != nil {
+		return models.EventStatusFailed, nil, fmt.Errorf("error decoding habitica event: %w", err)
+	}
+
+	slog.Info("got habitica event", "type", req.Type, "id", req.Task.Id, "name", req.Task.Text)
+	runs, runErr := w.automations.Run(HabiticaAutomationEvent(req))
+	handled, err := w.habiticas.Dispatch(req)
+	if err := errors.Join(runErr, err); err != nil {
+		return models.EventStatusFailed, runs, err
+	}
+	if !handled && len(runs) == 0 {
+		return models.EventStatusSkipped, runs, nil
+	}
+	return models.EventStatusProcessed, runs, nil
+}
+
+func (w *WebhookService) processTodoist(event models.Event) (string, []models.AutomationRun, error) {
+	var req models.TodoistWebhook
+	if err := json.Unmarshal([]byte(event.Payload), &req); err != nil {
+		return models.EventStatusFailed, nil, fmt.Errorf("error decoding todoist event: %w", err)
+	}
+
+	slog.Info("got todoist event", "event", req.EventName)
+	runs, runErr := w.automations.Run(TodoistAutomationEvent(req))
+	handled, err := w.todoists.Dispatch(req)
+	if err := errors.Join(runErr, err); err != nil {
+		return models.EventStatusFailed, runs, err
+	}
+	if !handled && len(runs) == 0 {
+		return models.EventStatusSkipped, runs, nil
+	}
+	return models.EventStatusProcessed, runs, nil
+}
+
+// webhookEnvelope holds the fields of either webhook payload that identify
+// the event, decoded best effort so malformed payloads can still be journaled.
+type webhookEnvelope struct {
+	// todoist
+	EventName   string `json:"event_name"`
+	TriggeredAt string `json:"triggered_at"`
+	EventData   struct {
+		Id string `json:"id"`
+	} `json:"event_data"`
+
+	// habitica
+	Type      string `json:"type"`
+	Direction string `json:"direction"`
+	Task      struct {
+		Id        string `json:"id"`
+		UpdatedAt string `json:"updatedAt"`
+	} `json:"task"`
+}
+
+func parseEnvelope(payload []byte) webhookEnvelope {
+	var envelope webhookEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		slog.Info("unable to decode webhook envelope", "err", err)
+	}
+	return envelope
+}
+
+func (e webhookEnvelope) eventType(source string) string {
+	if source == models.TodoistEventSource {
+		return e.EventName
+	}
+	return e.Type
+}
+
+// deliveryKey identifies a delivery so retries of it can be recognised. The
+// sender's delivery id is used when there is one, then the event's id and
+// timestamp, and finally a hash of the payload since a resend is identical.
+func (e webhookEnvelope) deliveryKey(source, deliveryId string, payload []byte) string {
+	if deliveryId != "" {
+		return fmt.Sprintf("%s:delivery:%s", source, deliveryId)
+	}
+
+	switch source {
+	case models.TodoistEventSource:
+		if e.EventData.Id != "" && e.TriggeredAt != "" {
+			return fmt.Sprintf("%s:%s:%s:%s", source, e.EventName, e.EventData.Id, e.TriggeredAt)
+		}
+	case models.HabiticaEventSource:
+		if e.Task.Id != "" && e.Task.UpdatedAt != "" {
+			return fmt.Sprintf("%s:%s:%s:%s:%s", source, e.Type, e.Direction, e.Task.Id, e.Task.UpdatedAt)
+		}
+	}
+
+	sum := sha256.Sum256(payload)
+	return fmt.Sprintf("%s:sha256:%s", source, hex.EncodeToString(sum[:]))
+}
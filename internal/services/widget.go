@@ -1,18 +1,30 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"misc/clients/habitica"
 	"misc/clients/todoist"
 	"misc/internal/models"
-	"sync"
+	"slices"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type widgetService struct {
+	db          WidgetMetricStore
 	habTaskRepo HabiticaTaskRepository
 	tdTaskRepo  TodoistTaskRepository
 }
 
+type WidgetMetricStore interface {
+	ListWidgetMetrics(string) ([]models.WidgetMetric, error)
+	ListHabitGoals() ([]models.HabitGoal, error)
+}
+
 type HabiticaTaskRepository interface {
 	GetHabits() ([]habitica.Habit, error)
 	GetDailys() ([]habitica.Daily, error)
@@ -23,90 +35,326 @@ type TodoistTaskRepository interface {
 	GetStats() (todoist.Stats, error)
 }
 
-func NewWidgetService(habRepo HabiticaTaskRepository, tdRepo TodoistTaskRepository) *widgetService {
-	return &widgetService{habRepo, tdRepo}
+func NewWidgetService(db WidgetMetricStore, habRepo HabiticaTaskRepository, tdRepo TodoistTaskRepository) *widgetService {
+	return &widgetService{db, habRepo, tdRepo}
 }
 
-func (w *widgetService) GetWidgetResponse() models.WidgetResponse {
-	widgetResp := models.WidgetResponse{}
-	ch := make(chan error, 4)
-	wg := sync.WaitGroup{}
-	wg.Add(4)
+// defaultTaskFilter is the Todoist filter for todoist.tasks metrics without a
+// selector.
+const defaultTaskFilter = "today | od"
 
-	go func() {
-		habits, err := w.habTaskRepo.GetHabits()
+// widgetData is what was fetched for a widget's metrics. A source that wasn't
+// needed or failed to load is left nil.
+type widgetData struct {
+	habits  []habitica.Habit
+	dailies []habitica.Daily
+	tasks   map[string][]todoist.Task
+	stats   *todoist.Stats
+	goals   map[string]models.HabitGoal
+}
+
+// widgetSourceTimeout bounds how long each source a widget reads from may
+// take, so one slow API doesn't hold up the rest.
+const widgetSourceTimeout = 5 * time.Second
+
+// GetWidgetResponse evaluates the metrics configured for a widget. Sources
+// that fail or time out are reported in Sources and the metrics reading from
+// them left out. An error is only returned if the metrics can't be loaded.
+func (w *widgetService) GetWidgetResponse(ctx context.Context, widget string) (models.WidgetResponse, error) {
+	widgetResp := models.WidgetResponse{
+		Widget:  widget,
+		Metrics: make([]models.WidgetMetricValue, 0),
+		Sources: make([]models.WidgetSourceStatus, 0),
+	}
+	metrics, err := w.db.ListWidgetMetrics(widget)
+	if err != nil {
+		return widgetResp, err
+	}
+
+	data, sources := w.fetch(ctx, metrics)
+	widgetResp.Sources = append(widgetResp.Sources, sources...)
+
+	for _, m := range metrics {
+		value, ok, err := evaluateMetric(m, data)
 		if err != nil {
-			slog.Error("error getting habits")
+			widgetResp.Errors = append(widgetResp.Errors, err.Error())
+		}
+		if ok {
+			widgetResp.Metrics = append(widgetResp.Metrics, value)
 		}
-		ch <- err
+	}
+	return widgetResp, nil
+}
+
+// widgetSource is one upstream read a widget needs. load stores what it read
+// in a field of widgetData no other source writes to.
+type widgetSource struct {
+	name string
+	load func(context.Context) error
+}
 
-		for _, h := range habits {
-			if h.Text == "Water" {
-				widgetResp.HabiticaWaterValue = h.CounterUp - h.CounterDown
-				widgetResp.HabiticaWaterGoal, _ = h.ParseGoal()
+// fetch loads the sources the metrics read from, concurrently, and reports
+// how each went. A source that fails leaves its data nil.
+func (w *widgetService) fetch(ctx context.Context, metrics []models.WidgetMetric) (widgetData, []models.WidgetSourceStatus) {
+	var needHabits, needDailies, needStats, needGoals bool
+	var queries []string
+	for _, m := range metrics {
+		needGoals = needGoals || m.GoalSource == models.GoalSourceHabitGoal
+		switch m.Source {
+		case models.MetricSourceHabit:
+			needHabits = true
+		case models.MetricSourceDailies:
+			needDailies = true
+		case models.MetricSourceTodoistStats:
+			needStats = true
+		case models.MetricSourceTodoistTasks:
+			if q := taskFilter(m); !slices.Contains(queries, q) {
+				queries = append(queries, q)
 			}
+		}
+	}
 
-			if h.Text == "Reading" {
-				widgetResp.HabiticaReadValue = h.CounterUp
-				widgetResp.HabiticaReadGoal, _ = h.ParseGoal()
+	var data widgetData
+	var sources []widgetSource
+	if needHabits {
+		sources = append(sources, widgetSource{"habits", func(ctx context.Context) error {
+			habits, err := callWithContext(ctx, w.habTaskRepo.GetHabits)
+			if err == nil {
+				data.habits = nonNil(habits)
+			}
+			return err
+		}})
+	}
+	if needGoals {
+		sources = append(sources, widgetSource{"habit goals", func(ctx context.Context) error {
+			goals, err := callWithContext(ctx, w.db.ListHabitGoals)
+			if err == nil {
+				data.goals = habitGoalsById(goals)
 			}
+			return err
+		}})
+	}
+	if needDailies {
+		sources = append(sources, widgetSource{"dailies", func(ctx context.Context) error {
+			dailies, err := callWithContext(ctx, w.habTaskRepo.GetDailys)
+			if err == nil {
+				data.dailies = nonNil(dailies)
+			}
+			return err
+		}})
+	}
+	if needStats {
+		sources = append(sources, widgetSource{"todoist stats", func(ctx context.Context) error {
+			stats, err := callWithContext(ctx, w.tdTaskRepo.GetStats)
+			if err == nil {
+				data.stats = &stats
+			}
+			return err
+		}})
+	}
+	tasks := make([][]todoist.Task, len(queries))
+	for i, q := range queries {
+		sources = append(sources, widgetSource{"todoist tasks " + q, func(ctx context.Context) error {
+			result, err := callWithContext(ctx, func() ([]todoist.Task, error) {
+				return w.tdTaskRepo.GetTasks(&todoist.TaskFilterOptions{Query: q, Limit: 200})
+			})
+			if err == nil {
+				tasks[i] = nonNil(result)
+			}
+			return err
+		}})
+	}
+
+	statuses := loadSources(ctx, sources)
+
+	data.tasks = make(map[string][]todoist.Task, len(queries))
+	for i, q := range queries {
+		if tasks[i] != nil {
+			data.tasks[q] = tasks[i]
 		}
-		wg.Done()
-	}()
+	}
+	return data, statuses
+}
+
+// loadSources runs the sources' loads concurrently and returns their
+// statuses in the same order.
+func loadSources(ctx context.Context, sources []widgetSource) []models.WidgetSourceStatus {
+	statuses := make([]models.WidgetSourceStatus, len(sources))
+	var g errgroup.Group
+	for i, source := range sources {
+		g.Go(func() error {
+			statuses[i] = loadSource(ctx, source)
+			return nil
+		})
+	}
+	// sources report failures in their status, so Wait has no error to return
+	_ = g.Wait()
+	return statuses
+}
+
+// loadSource runs a source's load within widgetSourceTimeout and reports how
+// it went.
+func loadSource(ctx context.Context, source widgetSource) models.WidgetSourceStatus {
+	ctx, cancel := context.WithTimeout(ctx, widgetSourceTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := source.load(ctx)
+	status := models.WidgetSourceStatus{
+		Source:     source.name,
+		Status:     models.WidgetSourceOK,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err == nil {
+		return status
+	}
 
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status.Status = models.WidgetSourceTimeout
+	case errors.Is(err, context.Canceled):
+		status.Status = models.WidgetSourceCanceled
+	default:
+		status.Status = models.WidgetSourceError
+	}
+	status.Error = fmt.Sprintf("error getting %s: %s", source.name, err)
+	slog.Error("error getting widget data", "source", source.name, "status", status.Status, "err", err)
+	return status
+}
+
+// callWithContext runs call and waits for it until ctx is done. The
+// repositories don't take a context, so a call given up on carries on in the
+// background and its result is dropped.
+func callWithContext[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
 	go func() {
-		dailys, err := w.habTaskRepo.GetDailys()
-		if err != nil {
-			slog.Error("error getting dailys")
+		value, err := call()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// nonNil returns an empty slice for nil, since nil data means the source
+// didn't load.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// evaluateMetric works out a metric's value and goal from the fetched data.
+// It reports false if the data it needs wasn't loaded, and an error if it
+// selects a habit that doesn't exist.
+func evaluateMetric(m models.WidgetMetric, data widgetData) (models.WidgetMetricValue, bool, error) {
+	value := models.WidgetMetricValue{Name: m.Name, Label: m.Label}
+	goal := -1
+	if m.GoalSource == models.GoalSourceFixed {
+		goal = m.Goal
+	}
+
+	switch m.Source {
+	case models.MetricSourceHabit:
+		if data.habits == nil {
+			return value, false, nil
+		}
+		habit, ok := findHabit(data.habits, m.Selector)
+		if !ok {
+			return value, false, fmt.Errorf("metric %s: no habit matching %q", m.Name, m.Selector)
+		}
+		switch m.Value {
+		case "up":
+			value.Value = habit.CounterUp
+		case "down":
+			value.Value = habit.CounterDown
+		case "net":
+			value.Value = habit.CounterUp - habit.CounterDown
+		}
+		if g, ok := data.goals[habit.ID]; ok && m.GoalSource == models.GoalSourceHabitGoal {
+			goal = g.Target
 		}
-		ch <- err
 
-		for _, d := range dailys {
+	case models.MetricSourceDailies:
+		if data.dailies == nil {
+			return value, false, nil
+		}
+		var due, done int
+		for _, d := range data.dailies {
 			if d.IsDue {
-				widgetResp.HabiticaDailysDue += 1
+				due++
 			}
 			if d.IsDue && d.Completed {
-				widgetResp.HabiticaDailysDone += 1
+				done++
 			}
 		}
-		wg.Done()
-	}()
-
-	go func() {
-		filter := &todoist.TaskFilterOptions{
-			Query: "today | od",
-			Limit: 200,
+		switch m.Value {
+		case "done":
+			value.Value = done
+		case "due":
+			value.Value = due
+		case "remaining":
+			value.Value = due - done
 		}
-		tasks, err := w.tdTaskRepo.GetTasks(filter)
-		if err != nil {
-			slog.Error("error getting todoist tasks", "err", err)
+		if m.GoalSource == models.GoalSourceDailiesDue {
+			goal = due
 		}
-		ch <- err
-
-		widgetResp.TodoistTasksDue = len(tasks)
-		wg.Done()
-	}()
 
-	go func() {
-		stats, err := w.tdTaskRepo.GetStats()
-		if err != nil {
-			slog.Error("error getting todoist stats", "err", err)
+	case models.MetricSourceTodoistTasks:
+		tasks, ok := data.tasks[taskFilter(m)]
+		if !ok {
+			return value, false, nil
 		}
-		ch <- err
+		value.Value = len(tasks)
 
-		widgetResp.TodoistTasksDone = stats.DaysItems[0].TotalCompleted
-		widgetResp.TodoistTasksGoal = stats.Goals.DailyGoal
-		wg.Done()
-	}()
+	case models.MetricSourceTodoistStats:
+		if data.stats == nil {
+			return value, false, nil
+		}
+		if len(data.stats.DaysItems) > 0 {
+			value.Value = data.stats.DaysItems[0].TotalCompleted
+		}
+		if m.GoalSource == models.GoalSourceTodoistDailyGoal {
+			goal = data.stats.Goals.DailyGoal
+		}
+	}
 
-	wg.Wait()
-	close(ch)
+	if goal >= 0 {
+		value.Goal = &goal
+	}
+	return value, true, nil
+}
 
-	for e := range ch {
-		if e != nil {
-			widgetResp.Errors = append(widgetResp.Errors, e.Error())
+func findHabit(habits []habitica.Habit, selector string) (habitica.Habit, bool) {
+	for _, h := range habits {
+		if h.Text == selector || h.ID == selector {
+			return h, true
 		}
 	}
+	return habitica.Habit{}, false
+}
 
-	return widgetResp
+func habitGoalsById(goals []models.HabitGoal) map[string]models.HabitGoal {
+	byId := make(map[string]models.HabitGoal, len(goals))
+	for _, g := range goals {
+		byId[g.HabitId] = g
+	}
+	return byId
+}
+
+func taskFilter(m models.WidgetMetric) string {
+	if m.Selector == "" {
+		return defaultTaskFilter
+	}
+	return m.Selector
 }
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"errors"
+	"misc/clients/habitica"
+	"misc/internal/models"
+	"misc/internal/services"
+	"testing"
+)
+
+// fakeTodoWriter adds the todo writes to fakeChecker's scoring.
+type fakeTodoWriter struct {
+	*fakeChecker
+}
+
+func (f *fakeTodoWriter) CreateTodo(text string) (habitica.Task, error) {
+	return habitica.Task{Text: text}, f.err
+}
+
+func (f *fakeTodoWriter) UpdateTaskText(string, string) error {
+	return f.err
+}
+
+func (f *fakeTodoWriter) DeleteTask(string) error {
+	return f.err
+}
+
+func TestActivityHub(t *testing.T) {
+	hub := services.NewActivityHub()
+	first, unsubscribeFirst := hub.Subscribe()
+	second, unsubscribeSecond := hub.Subscribe()
+	defer unsubscribeSecond()
+
+	hub.Publish(models.Activity{Kind: models.ActivityWebhook})
+	unsubscribeFirst()
+	hub.Publish(models.Activity{Kind: models.ActivityRule})
+
+	if a := <-first; a.Id != 1 || a.Kind != models.ActivityWebhook {
+		t.Errorf("got %+v, want the webhook as 1", a)
+	}
+	if a, ok := <-first; ok {
+		t.Errorf("got %+v after unsubscribing", a)
+	}
+	for _, want := range []string{models.ActivityWebhook, models.ActivityRule} {
+		if a := <-second; a.Kind != want || a.Time.IsZero() {
+			t.Errorf("got %+v, want a timestamped %s", a, want)
+		}
+	}
+}
+
+func TestActivityHubDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	hub := services.NewActivityHub()
+	_, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	// nothing reads, so all but the buffered activity is dropped
+	for range 1000 {
+		hub.Publish(models.Activity{Kind: models.ActivityCall})
+	}
+}
+
+func TestPublishingClients(t *testing.T) {
+	hub := services.NewActivityHub()
+	activity, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	checker := &fakeChecker{}
+	writer := services.NewPublishingHabitica(&fakeTodoWriter{checker}, hub)
+	if err := writer.ScoreDaily("stretch"); err != nil {
+		t.Fatal(err)
+	}
+	checker.err = errors.New("habitica is down")
+	if err := writer.UnscoreTask("stretch"); err == nil {
+		t.Fatal("expected the error from habitica")
+	}
+
+	want := []models.Activity{
+		{Kind: models.ActivityCall, Source: models.HabiticaEventSource, Name: models.ActionScoreHabitica, Subject: "stretch", Outcome: models.ActivityOK},
+		{Kind: models.ActivityCall, Source: models.HabiticaEventSource, Name: models.ActionUnscoreHabitica, Subject: "stretch", Outcome: models.ActivityError, Error: "habitica is down"},
+	}
+	for _, w := range want {
+		a := <-activity
+		a.Id, a.Time, a.DurationMs = 0, w.Time, 0
+		if a != w {
+			t.Errorf("got %+v, want %+v", a, w)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"misc/cmd/web"
+	"misc/internal/models"
+	"misc/internal/server"
+	"misc/internal/services"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeAuthStore keeps tokens and sessions by hash.
+type fakeAuthStore struct {
+	tokens   map[string]models.APIToken
+	sessions map[string]models.Session
+	touched  int
+}
+
+func newFakeAuthStore() *fakeAuthStore {
+	return &fakeAuthStore{tokens: map[string]models.APIToken{}, sessions: map[string]models.Session{}}
+}
+
+func (f *fakeAuthStore) CreateAPIToken(t models.APIToken, hash string) (models.APIToken, error) {
+	t.Id = int64(len(f.tokens) + 1)
+	f.tokens[hash] = t
+	return t, nil
+}
+
+func (f *fakeAuthStore) FindAPIToken(hash string) (models.APIToken, error) {
+	t, ok := f.tokens[hash]
+	if !ok {
+		return t, errNotFound
+	}
+	return t, nil
+}
+
+func (f *fakeAuthStore) TouchAPIToken(id int64, usedAt time.Time) error {
+	f.touched++
+	for hash, t := range f.tokens {
+		if t.Id == id {
+			t.LastUsedAt = &usedAt
+			f.tokens[hash] = t
+		}
+	}
+	return nil
+}
+
+func (f *fakeAuthStore) CreateSession(hash string, s models.Session) error {
+	f.sessions[hash] = s
+	return nil
+}
+
+func (f *fakeAuthStore) FindSession(hash string) (models.Session, error) {
+	s, ok := f.sessions[hash]
+	if !ok {
+		return s, errNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeAuthStore) DeleteSession(hash string) error {
+	delete(f.sessions, hash)
+	return nil
+}
+
+func TestAuthTokens(t *testing.T) {
+	store := newFakeAuthStore()
+	auth := services.NewAuthService(store, "")
+
+	if _, _, err := auth.CreateToken("phone", []string{"read"}); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+	created, secret, err := auth.CreateToken("phone", []string{models.ScopeWidget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash := range store.tokens {
+		if strings.Contains(hash, secret) || strings.Contains(secret, hash) {
+			t.Error("the token's secret is stored")
+		}
+	}
+
+	for range 2 {
+		token, err := auth.Token(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Id != created.Id {
+			t.Errorf("got token %d, want %d", token.Id, created.Id)
+		}
+	}
+	if store.touched != 1 {
+		t.Errorf("last use recorded %d times, want once for two uses in a row", store.touched)
+	}
+	if _, err := auth.Token(secret + "x"); !errors.Is(err, errNotFound) {
+		t.Errorf("got %v for a wrong secret, want not found", err)
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	auth := services.NewAuthService(newFakeAuthStore(), "hunter2")
+
+	if _, _, err := auth.Login("hunter"); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("got %v for a wrong password, want invalid credentials", err)
+	}
+	secret, _, err := auth.Login("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := auth.Session(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !models.HasScope(session.Scopes, models.ScopeWidget) {
+		t.Errorf("session scopes %v don't grant widget", session.Scopes)
+	}
+	if err := auth.Logout(secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Session(secret); err == nil {
+		t.Error("session still valid after logging out")
+	}
+
+	disabled := services.NewAuthService(newFakeAuthStore(), "")
+	if _, _, err := disabled.Login(""); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("got %v logging in without a password set, want invalid credentials", err)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	widget := []string{models.ScopeWidget}
+	if models.HasScope(widget, models.ScopeAdmin) {
+		t.Error("widget scope grants admin")
+	}
+	if !models.HasScope([]string{models.ScopeAdmin}, models.ScopeWidget) {
+		t.Error("admin scope doesn't grant widget")
+	}
+}
+
+// TestWebPageScopes checks that a token able to load the dashboard can load
+// everything the page requests after it: its charts, refreshes and scripts,
+// and the activity stream those scripts open.
+func TestWebPageScopes(t *testing.T) {
+	s := &server.Server{}
+	scope := func(url string) string {
+		return s.RouteScope(httptest.NewRequest("GET", url, nil))
+	}
+
+	for _, url := range []string{"/web?token=x", web.DashboardURL + "?token=x"} {
+		if got := scope(url); got != models.ScopeAdmin {
+			t.Errorf("%s needs %q, want admin", url, got)
+		}
+	}
+	for _, name := range web.DashboardCharts {
+		url := "/charts/" + name + ".svg?token=x"
+		if got := scope(url); got != models.ScopeWidget {
+			t.Errorf("%s needs %q, want widget so it can be embedded", url, got)
+		}
+	}
+
+	var page strings.Builder
+	if err := web.DashboardPage(models.Dashboard{}).Render(context.Background(), &page); err != nil {
+		t.Fatalf("error rendering dashboard: %v", err)
+	}
+	urls := regexp.MustCompile(`(?:src|hx-get)="([^"]+)"`).FindAllStringSubmatch(page.String(), -1)
+	if len(urls) < len(web.DashboardCharts)+1 {
+		t.Fatalf("found %d urls on the page, want the charts and the refresh", len(urls))
+	}
+	for _, script := range regexp.MustCompile(`<script src="/([^"]+)"`).FindAllStringSubmatch(page.String(), -1) {
+		data, err := fs.ReadFile(web.Files, script[1])
+		if err != nil {
+			t.Fatalf("error reading %s: %v", script[1], err)
+		}
+		urls = append(urls, regexp.MustCompile(`EventSource\("([^"]+)"`).FindAllStringSubmatch(string(data), -1)...)
+	}
+	for _, url := range urls {
+		if got := scope(url[1]); !models.HasScope([]string{models.ScopeAdmin}, got) {
+			t.Errorf("%s, requested by the dashboard, needs %q beyond the page's admin scope", url[1], got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"misc/clients/fitbit"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"misc/internal/services"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeFillStore keeps snapshots by day, metric and subject, and like the
+// database only fills the ones not recorded yet.
+type fakeFillStore struct {
+	t         *testing.T
+	snapshots map[string]models.Snapshot
+	goals     []models.HabitGoal
+}
+
+func snapshotKey(date, metric, subject string) string {
+	return date + " " + metric + " " + subject
+}
+
+func (f *fakeFillStore) SaveSnapshots([]models.Snapshot) error {
+	f.t.Error("backfill replaced snapshots instead of filling them")
+	return nil
+}
+
+func (f *fakeFillStore) FillSnapshots(snapshots []models.Snapshot) (int, error) {
+	var filled int
+	for _, snap := range snapshots {
+		key := snapshotKey(snap.Date, snap.Metric, snap.Subject)
+		if _, ok := f.snapshots[key]; !ok {
+			f.snapshots[key] = snap
+			filled++
+		}
+	}
+	return filled, nil
+}
+
+func (f *fakeFillStore) ListHabitGoals() ([]models.HabitGoal, error) {
+	return f.goals, nil
+}
+
+type fakeHabiticaHistory struct {
+	habits  []habitica.Habit
+	dailies []habitica.Daily
+}
+
+func (f fakeHabiticaHistory) GetHabits() ([]habitica.Habit, error) { return f.habits, nil }
+
+func (f fakeHabiticaHistory) GetDailys() ([]habitica.Daily, error) { return f.dailies, nil }
+
+// fakeTodoistHistory returns every completed task whatever the range, and
+// records the ranges asked for.
+type fakeTodoistHistory struct {
+	completed []time.Time
+	ranges    [][2]time.Time
+}
+
+func (f *fakeTodoistHistory) GetStats() (todoist.Stats, error) {
+	return todoist.Stats{Goals: todoist.Goals{DailyGoal: 3}}, nil
+}
+
+func (f *fakeTodoistHistory) GetCompletedTasks(since, until time.Time) ([]json.RawMessage, error) {
+	f.ranges = append(f.ranges, [2]time.Time{since, until})
+	items := make([]json.RawMessage, 0, len(f.completed))
+	for i, at := range f.completed {
+		data, err := json.Marshal(models.TodoistItem{Id: string(rune('a' + i)), CompletedAt: at.UTC().Format(time.RFC3339)})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, data)
+	}
+	return items, nil
+}
+
+// fakeFitbitHistory returns the same series, weight and sleep whatever the
+// range, and records the series ranges asked for.
+type fakeFitbitHistory struct {
+	series map[string][]fitbit.SeriesPoint
+	weight []fitbit.WeightRecord
+	sleep  []fitbit.SleepLog
+	ranges []string
+}
+
+func (f *fakeFitbitHistory) GetFitbitActivity() (fitbit.ActivityResponse, error) {
+	return fitbit.ActivityResponse{Goals: fitbit.FitbitGoals{Steps: 2500, ActiveMinutes: 30}}, nil
+}
+
+func (f *fakeFitbitHistory) GetActivitySeries(resource string, start, end time.Time) ([]fitbit.SeriesPoint, error) {
+	f.ranges = append(f.ranges, resource+" "+start.Format(models.SnapshotDateFormat)+" "+end.Format(models.SnapshotDateFormat))
+	return f.series[resource], nil
+}
+
+func (f *fakeFitbitHistory) GetWeightLog(start, end time.Time) ([]fitbit.WeightRecord, error) {
+	return f.weight, nil
+}
+
+func (f *fakeFitbitHistory) GetSleep(start, end time.Time) ([]fitbit.SleepLog, error) {
+	return f.sleep, nil
+}
+
+func TestBackfill(t *testing.T) {
+	year, month, day := time.Now().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	daysAgo := func(n int) time.Time { return today.AddDate(0, 0, -n) }
+	at := func(n int, hour, minute int) habitica.HistoryTime {
+		return habitica.HistoryTime{Time: daysAgo(n).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)}
+	}
+	date := func(n int) string { return daysAgo(n).Format(models.SnapshotDateFormat) }
+
+	store := &fakeFillStore{
+		t: t,
+		snapshots: map[string]models.Snapshot{
+			// recorded by the snapshot job, which backfill must not overwrite
+			snapshotKey(date(2), models.SnapshotTodoistCompleted, ""): {
+				Date: date(2), Metric: models.SnapshotTodoistCompleted, Value: 9,
+			},
+		},
+		goals: []models.HabitGoal{{HabitId: "h1", Target: 5, Period: models.GoalPeriodDaily}},
+	}
+	habits := fakeHabiticaHistory{
+		habits: []habitica.Habit{{
+			Task: habitica.Task{ID: "h1"},
+			History: []habitica.HabitHistory{
+				{Date: at(4, 12, 0), ScoredUp: 7},
+				{Date: at(2, 10, 0), ScoredUp: 1},
+				{Date: at(2, 20, 0), ScoredUp: 2, ScoredDown: 1},
+				{Date: at(1, 9, 0), ScoredUp: 1},
+				{Date: at(0, 8, 0), ScoredUp: 4},
+			},
+		}},
+		// each entry is written by the cron that starts the next day
+		dailies: []habitica.Daily{
+			{
+				Task: habitica.Task{ID: "d1"},
+				History: []habitica.DailyHistory{
+					{Date: at(3, 0, 5), IsDue: true, Completed: true},
+					{Date: at(2, 0, 5), IsDue: true, Completed: true},
+					{Date: at(1, 0, 5), IsDue: true},
+					{Date: at(0, 0, 5), IsDue: true, Completed: true},
+				},
+			},
+			{
+				Task: habitica.Task{ID: "d2"},
+				History: []habitica.DailyHistory{
+					{Date: at(1, 0, 5), IsDue: true, Completed: true},
+					{Date: at(0, 0, 5), Completed: true},
+				},
+			},
+		},
+	}
+	tasks := &fakeTodoistHistory{completed: []time.Time{
+		daysAgo(3).Add(15 * time.Hour),
+		daysAgo(2).Add(12 * time.Hour),
+		daysAgo(1).Add(8 * time.Hour),
+		daysAgo(1).Add(22 * time.Hour),
+	}}
+	activity := &fakeFitbitHistory{
+		series: map[string][]fitbit.SeriesPoint{
+			"steps":             {{DateTime: date(3), Value: "1000"}, {DateTime: date(2), Value: "2000"}, {DateTime: date(1), Value: "3000"}},
+			"minutesVeryActive": {{DateTime: date(2), Value: "15"}},
+		},
+		weight: []fitbit.WeightRecord{{Date: date(2), Weight: 80}, {Date: date(2), Weight: 79.5}},
+		sleep:  []fitbit.SleepLog{{DateOfSleep: date(1), MinutesAsleep: 400, IsMainSleep: true}, {DateOfSleep: date(1), MinutesAsleep: 30}},
+	}
+
+	snapshotter := services.NewSnapshotter(store, habits, tasks, activity, nil)
+	// the range runs into today, which is left to the snapshot job
+	filled, err := snapshotter.Backfill(context.Background(), daysAgo(3), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]struct {
+		value float64
+		goal  *float64
+	}{
+		snapshotKey(date(2), models.SnapshotHabitUp, "h1"):        {3, ptr(5.0)},
+		snapshotKey(date(2), models.SnapshotHabitDown, "h1"):      {1, nil},
+		snapshotKey(date(1), models.SnapshotHabitUp, "h1"):        {1, ptr(5.0)},
+		snapshotKey(date(1), models.SnapshotHabitDown, "h1"):      {0, nil},
+		snapshotKey(date(3), models.SnapshotDailiesDone, ""):      {1, ptr(1.0)},
+		snapshotKey(date(3), models.SnapshotDailiesDue, ""):       {1, nil},
+		snapshotKey(date(2), models.SnapshotDailiesDone, ""):      {1, ptr(2.0)},
+		snapshotKey(date(2), models.SnapshotDailiesDue, ""):       {2, nil},
+		snapshotKey(date(1), models.SnapshotDailiesDone, ""):      {1, ptr(1.0)},
+		snapshotKey(date(1), models.SnapshotDailiesDue, ""):       {1, nil},
+		snapshotKey(date(3), models.SnapshotTodoistCompleted, ""): {1, ptr(3.0)},
+		snapshotKey(date(2), models.SnapshotTodoistCompleted, ""): {9, nil},
+		snapshotKey(date(1), models.SnapshotTodoistCompleted, ""): {2, ptr(3.0)},
+		snapshotKey(date(3), models.SnapshotSteps, ""):            {1000, ptr(2500.0)},
+		snapshotKey(date(2), models.SnapshotSteps, ""):            {2000, ptr(2500.0)},
+		snapshotKey(date(1), models.SnapshotSteps, ""):            {3000, ptr(2500.0)},
+		snapshotKey(date(2), models.SnapshotActiveMinutes, ""):    {15, ptr(30.0)},
+		snapshotKey(date(2), models.SnapshotWeight, ""):           {79.5, nil},
+		snapshotKey(date(1), models.SnapshotSleepMinutes, ""):     {430, nil},
+	}
+	if filled != len(want)-1 {
+		t.Errorf("filled = %d, want %d", filled, len(want)-1)
+	}
+	for key, w := range want {
+		snap, ok := store.snapshots[key]
+		if !ok {
+			t.Errorf("%s: missing", key)
+			continue
+		}
+		if snap.Value != w.value {
+			t.Errorf("%s: value = %v, want %v", key, snap.Value, w.value)
+		}
+		if (snap.Goal == nil) != (w.goal == nil) || snap.Goal != nil && *snap.Goal != *w.goal {
+			t.Errorf("%s: goal = %v, want %v", key, snap.Goal, w.goal)
+		}
+	}
+	for key := range store.snapshots {
+		if _, ok := want[key]; !ok {
+			t.Errorf("%s: unexpected snapshot", key)
+		}
+	}
+
+	if want := [][2]time.Time{{daysAgo(3), today}}; !slices.Equal(tasks.ranges, want) {
+		t.Errorf("todoist ranges = %v, want %v", tasks.ranges, want)
+	}
+	slices.Sort(activity.ranges)
+	wantRanges := []string{
+		"minutesVeryActive " + date(3) + " " + date(1),
+		"steps " + date(3) + " " + date(1),
+	}
+	if !slices.Equal(activity.ranges, wantRanges) {
+		t.Errorf("fitbit ranges = %q, want %q", activity.ranges, wantRanges)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
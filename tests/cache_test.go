@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"misc/internal/services"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheCoalescesConcurrentReads(t *testing.T) {
+	hab := &fakeHabitica{habits: fakeRepo{delay: 50 * time.Millisecond}}
+	cached := services.NewCachedHabitica(hab, services.NewCache(), time.Minute)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			habits, err := cached.GetHabits()
+			if err != nil || len(habits) != 2 {
+				t.Errorf("got %d habits, err %v", len(habits), err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := cached.GetHabits(); err != nil {
+		t.Fatal(err)
+	}
+	if hab.habits.calls != 1 {
+		t.Errorf("habits fetched %d times, want once", hab.habits.calls)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	hab := &fakeHabitica{}
+	cached := services.NewCachedHabitica(hab, services.NewCache(), 20*time.Millisecond)
+
+	cached.GetDailys()
+	cached.GetDailys()
+	time.Sleep(30 * time.Millisecond)
+	cached.GetDailys()
+	if hab.dailies.calls != 2 {
+		t.Errorf("dailies fetched %d times, want 2", hab.dailies.calls)
+	}
+}
+
+func TestCacheDoesNotKeepErrors(t *testing.T) {
+	td := &fakeTodoist{stats: fakeRepo{err: errors.New("rate limited")}}
+	cached := services.NewCachedTodoist(td, services.NewCache(), time.Minute)
+
+	for range 2 {
+		if _, err := cached.GetStats(); err == nil {
+			t.Error("expected the error from todoist")
+		}
+	}
+	if td.stats.calls != 2 {
+		t.Errorf("stats fetched %d times, want 2", td.stats.calls)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	cache := services.NewCache()
+	hab, td := &fakeHabitica{}, &fakeTodoist{}
+	cachedHab := services.NewCachedHabitica(hab, cache, time.Minute)
+	cachedTd := services.NewCachedTodoist(td, cache, time.Minute)
+
+	cachedHab.GetHabits()
+	cachedTd.GetStats()
+	cache.Invalidate(services.CacheTodoist)
+	cachedHab.GetHabits()
+	cachedTd.GetStats()
+
+	if hab.habits.calls != 1 {
+		t.Errorf("habits fetched %d times, want once", hab.habits.calls)
+	}
+	if td.stats.calls != 2 {
+		t.Errorf("stats fetched %d times, want 2 after invalidating todoist", td.stats.calls)
+	}
+}
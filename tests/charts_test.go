@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"misc/clients/fitbit"
+	"misc/internal/models"
+	"misc/internal/services"
+	"testing"
+	"time"
+)
+
+// fakeSnapshotStore filters snapshots by metric and subject like the
+// database, ignoring dates.
+type fakeSnapshotStore []models.Snapshot
+
+func (f fakeSnapshotStore) ListSnapshots(filter models.SnapshotFilter) ([]models.Snapshot, error) {
+	snapshots := make([]models.Snapshot, 0)
+	for _, snap := range f {
+		if snap.Metric == filter.Metric && (filter.Subject == "" || snap.Subject == filter.Subject) {
+			snapshots = append(snapshots, snap)
+		}
+	}
+	return snapshots, nil
+}
+
+type fakeWeight []fitbit.WeightRecord
+
+func (f fakeWeight) GetFitbitWeight() (fitbit.WeightResponse, error) {
+	return fitbit.WeightResponse{WeightRecords: f}, nil
+}
+
+// withMetric sets the metric and subject of snapshots.
+func withMetric(snapshots []models.Snapshot, metric, subject string) []models.Snapshot {
+	for i := range snapshots {
+		snapshots[i].Metric, snapshots[i].Subject = metric, subject
+	}
+	return snapshots
+}
+
+func TestWaterChartUsesWaterHabit(t *testing.T) {
+	today := time.Now()
+	store := fakeSnapshotStore(append(
+		withMetric(series(today, 8, 6, 8, 3), models.SnapshotHabitUp, "water"),
+		withMetric(series(today, 1, 1, 1, 1), models.SnapshotHabitUp, "reading")...,
+	))
+	charts := services.NewChartService(store, &fakeHabitica{}, nil, services.DefaultWaterHabit)
+
+	chart, err := charts.Chart(services.ChartWater, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart.Title != "Water" || chart.Type != models.ChartBar {
+		t.Errorf("got %q as %s, want Water as a bar chart", chart.Title, chart.Type)
+	}
+	if len(chart.Points) != 3 {
+		t.Fatalf("got %d points, want the water habit's 3", len(chart.Points))
+	}
+	if last := chart.Points[2]; last.Value != 3 || *last.Goal != 8 {
+		t.Errorf("got %v of %v today, want 3 of 8", last.Value, *last.Goal)
+	}
+
+	charts = services.NewChartService(store, &fakeHabitica{}, nil, "Coffee")
+	if _, err := charts.Chart(services.ChartWater, 14); err == nil {
+		t.Error("expected an error without a matching habit")
+	}
+}
+
+func TestWeightChartAddsFitbitLog(t *testing.T) {
+	today := time.Now()
+	day := func(daysAgo int) string {
+		return today.AddDate(0, 0, -daysAgo).Format(models.SnapshotDateFormat)
+	}
+	store := fakeSnapshotStore(withMetric(series(today, 0, 81, 80.5, -1), models.SnapshotWeight, ""))
+	weight := fakeWeight{
+		{Date: day(30), Weight: 90},
+		{Date: day(1), Weight: 80.2},
+		{Date: day(0), Weight: 79.9},
+	}
+	charts := services.NewChartService(store, &fakeHabitica{}, weight, services.DefaultWaterHabit)
+
+	chart, err := charts.Chart(services.ChartWeight, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ChartPoint{{Date: day(2), Value: 81}, {Date: day(1), Value: 80.2}, {Date: day(0), Value: 79.9}}
+	if len(chart.Points) != len(want) {
+		t.Fatalf("got %v, want %v", chart.Points, want)
+	}
+	for i, w := range want {
+		if p := chart.Points[i]; p.Date != w.Date || p.Value != w.Value {
+			t.Errorf("point %d: got %s %v, want %s %v", i, p.Date, p.Value, w.Date, w.Value)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"misc/internal/services"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2026, time.October, 14, 10, 7, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", at(time.October, 14, 10, 8)},
+		{"*/15 * * * *", at(time.October, 14, 10, 15)},
+		{"5-20/4 * * * *", at(time.October, 14, 10, 9)},
+		{"50/5 * * * *", at(time.October, 14, 10, 50)},
+		{"0 9,17 * * *", at(time.October, 14, 17, 0)},
+		{"0 1-3 * * *", at(time.October, 15, 1, 0)},
+		{"0 0 * * 0", at(time.October, 18, 0, 0)},
+		{"0 0 * * 7", at(time.October, 18, 0, 0)},
+		{"0 0 * * 1-5", at(time.October, 15, 0, 0)},
+		{"0 0 * 12 *", at(time.December, 1, 0, 0)},
+		// with both day fields restricted either one matches: the 20th or a Friday
+		{"0 0 20 * 5", at(time.October, 16, 0, 0)},
+		// a day field starting with * isn't restricted, so both must match:
+		// an odd day that is a Friday
+		{"0 0 */2 * 5", at(time.October, 23, 0, 0)},
+		// Sunday, Wednesday and Saturday
+		{"0 0 * * */3", at(time.October, 17, 0, 0)},
+		{"@hourly", at(time.October, 14, 11, 0)},
+		{"@daily", at(time.October, 15, 0, 0)},
+		{"@midnight", at(time.October, 15, 0, 0)},
+		{"@weekly", at(time.October, 18, 0, 0)},
+		{"@monthly", at(time.November, 1, 0, 0)},
+		{"@yearly", time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{" 30 10 * * * ", at(time.October, 14, 10, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := services.ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronRejectsBadInput(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"-1 * * * *",
+	} {
+		if _, err := services.ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronNextAcrossBoundaries(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"next day", "0 0 * * *", date(2026, time.October, 14, 23, 59), date(2026, time.October, 15, 0, 0)},
+		{"next month", "0 0 1 * *", date(2026, time.January, 31, 23, 59), date(2026, time.February, 1, 0, 0)},
+		{"skips short months", "0 12 31 * *", date(2026, time.April, 1, 0, 0), date(2026, time.May, 31, 12, 0)},
+		{"next year", "0 0 * * *", date(2026, time.December, 31, 23, 30), date(2027, time.January, 1, 0, 0)},
+		{"same day next year", "30 23 31 12 *", date(2026, time.December, 31, 23, 45), date(2027, time.December, 31, 23, 30)},
+		{"leap day", "0 0 29 2 *", date(2026, time.March, 1, 0, 0), date(2028, time.February, 29, 0, 0)},
+		{"never", "0 0 31 2 *", date(2026, time.January, 1, 0, 0), time.Time{}},
+		{"seconds are dropped", "* * * * *", date(2026, time.October, 14, 10, 7).Add(30 * time.Second), date(2026, time.October, 14, 10, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := services.ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"misc/internal/models"
+	"misc/internal/services"
+	"testing"
+	"time"
+)
+
+// fakeChecker records the dailies and tasks checked off from the dashboard.
+type fakeChecker struct {
+	scored, unscored, closed []string
+	err                      error
+}
+
+func (f *fakeChecker) ScoreDaily(id string) error {
+	f.scored = append(f.scored, id)
+	return f.err
+}
+
+func (f *fakeChecker) UnscoreTask(id string) error {
+	f.unscored = append(f.unscored, id)
+	return f.err
+}
+
+func (f *fakeChecker) CloseTask(id string) error {
+	f.closed = append(f.closed, id)
+	return f.err
+}
+
+func TestDashboard(t *testing.T) {
+	hab := &fakeHabitica{}
+	td := &fakeTodoist{stats: fakeRepo{err: errors.New("unused")}}
+	dashboard := services.NewDashboardService(widgetStore(), hab, td, nil, services.NewCache(), &fakeChecker{}, &fakeChecker{})
+
+	dash := dashboard.GetDashboard(context.Background())
+	if len(dash.Habits) != 2 {
+		t.Fatalf("got %d habits, want 2", len(dash.Habits))
+	}
+	for _, h := range dash.Habits {
+		if (h.Goal != nil) != (h.Id == "water") {
+			t.Errorf("%s: got goal %v, want one only for water", h.Id, h.Goal)
+		}
+	}
+	if len(dash.Dailies) != 2 {
+		t.Errorf("got %d dailies, want the 2 due", len(dash.Dailies))
+	}
+	if len(dash.Chores) != 2 || len(dash.Hygiene) != 2 {
+		t.Errorf("got %d chores and %d hygiene tasks, want 2 each", len(dash.Chores), len(dash.Hygiene))
+	}
+	if dash.Fitbit != nil {
+		t.Error("fitbit section without a fitbit reader")
+	}
+	for _, s := range dash.Sources {
+		if s.Status != models.WidgetSourceOK {
+			t.Errorf("source %s: got %s, want ok", s.Source, s.Status)
+		}
+	}
+}
+
+func TestDashboardCheckInvalidatesCache(t *testing.T) {
+	cache := services.NewCache()
+	hab, td := &fakeHabitica{}, &fakeTodoist{}
+	checker := &fakeChecker{}
+	dashboard := services.NewDashboardService(
+		widgetStore(),
+		services.NewCachedHabitica(hab, cache, time.Minute),
+		services.NewCachedTodoist(td, cache, time.Minute),
+		nil,
+		cache,
+		checker,
+		checker,
+	)
+
+	dashboard.GetDashboard(context.Background())
+	if err := dashboard.CheckDaily("stretch", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := dashboard.CheckDaily("floss", false); err != nil {
+		t.Fatal(err)
+	}
+	dashboard.GetDashboard(context.Background())
+
+	if len(checker.scored) != 1 || len(checker.unscored) != 1 {
+		t.Errorf("scored %v and unscored %v, want one each", checker.scored, checker.unscored)
+	}
+	if hab.dailies.calls != 2 {
+		t.Errorf("dailies fetched %d times, want 2 after checking one", hab.dailies.calls)
+	}
+	if td.tasks.calls != 2 {
+		t.Errorf("tasks fetched %d times, want once per filter", td.tasks.calls)
+	}
+}
@@ -0,0 +1,430 @@
+package tests
+
+import (
+	"misc/internal/models"
+	"misc/internal/services"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeEngineStore keeps automations and firings in memory.
+type fakeEngineStore struct {
+	automations []models.Automation
+	goals       []models.HabitGoal
+	// firings by automation key and day
+	fired       map[string]bool
+	completions map[string][]string
+}
+
+func newFakeEngineStore(automations ...models.Automation) *fakeEngineStore {
+	return &fakeEngineStore{
+		automations: automations,
+		fired:       map[string]bool{},
+		completions: map[string][]string{},
+	}
+}
+
+func (f *fakeEngineStore) ListAutomations() ([]models.Automation, error) {
+	return f.automations, nil
+}
+
+func (f *fakeEngineStore) ListHabitGoals() ([]models.HabitGoal, error) {
+	return f.goals, nil
+}
+
+func (f *fakeEngineStore) MarkAutomationFired(key, day string) (bool, error) {
+	if f.fired[key+" "+day] {
+		return false, nil
+	}
+	f.fired[key+" "+day] = true
+	return true, nil
+}
+
+func (f *fakeEngineStore) ClearAutomationFired(key, day string) (bool, error) {
+	if !f.fired[key+" "+day] {
+		return false, nil
+	}
+	delete(f.fired, key+" "+day)
+	return true, nil
+}
+
+func (f *fakeEngineStore) RecordCompletion(itemId string, taskIds []string) error {
+	f.completions[itemId] = taskIds
+	return nil
+}
+
+func automation(id int64, edit func(*models.Automation)) models.Automation {
+	a := models.Automation{Id: id, Enabled: true, Trigger: models.Trigger{Kind: models.TriggerTodoist}}
+	edit(&a)
+	a.SetDefaults()
+	return a
+}
+
+func completed(itemId, content, projectId string) models.AutomationEvent {
+	return models.AutomationEvent{
+		Kind:    models.TriggerTodoist,
+		Name:    models.TodoistItemCompleted,
+		Subject: itemId,
+		Facts: map[string]any{
+			"item.id":         itemId,
+			"item.content":    content,
+			"item.project_id": projectId,
+			"item.labels":     []string{},
+			"item.section_id": "",
+		},
+	}
+}
+
+func score(taskId string) models.ActionCall {
+	return models.ActionCall{Kind: models.ActionScoreHabitica, TaskId: taskId}
+}
+
+func unscore(taskId string) models.ActionCall {
+	return models.ActionCall{Kind: models.ActionUnscoreHabitica, TaskId: taskId}
+}
+
+// outcomes describes runs as "key outcome", e.g. "text:1 fired".
+func outcomes(runs []models.AutomationRun) []string {
+	out := make([]string, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, run.Key+" "+run.Outcome)
+	}
+	return out
+}
+
+func TestEngineRun(t *testing.T) {
+	scoreAction := func(taskId string) []models.Action {
+		return []models.Action{{Kind: models.ActionScoreHabitica, TaskId: taskId}}
+	}
+	contentHas := func(value string) []models.Condition {
+		return []models.Condition{{Field: "item.content", Op: models.OpContains, Value: value}}
+	}
+	steps := func(reached bool) models.AutomationEvent {
+		goal := 10000.0
+		if !reached {
+			goal = 20000
+		}
+		return services.FitbitAutomationEvent("steps", 12000, goal)
+	}
+
+	tests := []struct {
+		name        string
+		automations []models.Automation
+		events      []models.AutomationEvent
+		want        [][]string
+		wantCalls   []models.ActionCall
+	}{
+		{
+			name: "priority orders runs and stop ends the group",
+			automations: []models.Automation{
+				automation(1, func(a *models.Automation) {
+					a.Group, a.Priority, a.Stop, a.Actions = "g", 1, true, scoreAction("low")
+				}),
+				automation(2, func(a *models.Automation) {
+					a.Group, a.Priority, a.Actions = "g", 5, scoreAction("high")
+				}),
+				automation(3, func(a *models.Automation) {
+					a.Group, a.Actions = "g", scoreAction("stopped")
+				}),
+				automation(4, func(a *models.Automation) {
+					a.Actions = scoreAction("ungrouped")
+				}),
+			},
+			events:    []models.AutomationEvent{completed("i1", "anything", "p")},
+			want:      [][]string{{"automation:2 fired", "automation:1 fired", "automation:4 fired"}},
+			wantCalls: []models.ActionCall{score("high"), score("low"), score("ungrouped")},
+		},
+		{
+			name: "conditions that don't match skip the automation",
+			automations: []models.Automation{
+				automation(1, func(a *models.Automation) {
+					a.Conditions, a.Actions = contentHas("run"), scoreAction("run")
+				}),
+			},
+			events:    []models.AutomationEvent{completed("i1", "read a book", "p")},
+			want:      [][]string{{}},
+			wantCalls: []models.ActionCall{},
+		},
+		{
+			name: "fallback runs when nothing else in the group matched",
+			automations: []models.Automation{
+				automation(1, func(a *models.Automation) {
+					a.Group, a.Conditions, a.Actions = "g", contentHas("run"), scoreAction("run")
+				}),
+				automation(2, func(a *models.Automation) {
+					a.Group, a.Fallback, a.Actions = "g", true, scoreAction("fallback")
+				}),
+			},
+			events:    []models.AutomationEvent{completed("i1", "read a book", "p")},
+			want:      [][]string{{"automation:2 fired"}},
+			wantCalls: []models.ActionCall{score("fallback")},
+		},
+		{
+			name: "fallback is skipped when the group matched",
+			automations: []models.Automation{
+				automation(1, func(a *models.Automation) {
+					a.Group, a.Conditions, a.Actions = "g", contentHas("run"), scoreAction("run")
+				}),
+				automation(2, func(a *models.Automation) {
+					a.Group, a.Fallback, a.Actions = "g", true, scoreAction("fallback")
+				}),
+			},
+			events:    []models.AutomationEvent{completed("i1", "go for a run", "p")},
+			want:      [][]string{{"automation:1 fired"}},
+			wantCalls: []models.ActionCall{score("run")},
+		},
+		{
+			name: "once daily fires, holds, then reverts",
+			automations: []models.Automation{
+				automation(1, func(a *models.Automation) {
+					a.Trigger = models.Trigger{Kind: models.TriggerFitbit, Event: "steps"}
+					a.Conditions = []models.Condition{{Field: "reached", Op: models.OpEquals, Value: "true"}}
+					a.Actions = scoreAction("walk")
+					a.Once = models.OnceDaily
+				}),
+			},
+			events: []models.AutomationEvent{steps(true), steps(true), steps(false), steps(false)},
+			want: [][]string{
+				{"automation:1 fired"},
+				{"automation:1 " + models.AutomationAlreadyFired},
+				{"automation:1 reverted"},
+				{},
+			},
+			wantCalls: []models.ActionCall{score("walk"), unscore("walk")},
+		},
+		{
+			name: "text rule wins over the project rule",
+			automations: []models.Automation{
+				models.TodoistHabiticaTextRule{Id: 1, Rule: "go for", HabitId: "run"}.Automation(),
+				models.TodoistHabiticaProjectRule{Id: 2, ProjectId: "p1", HabitId: "project"}.Automation(),
+			},
+			events:    []models.AutomationEvent{completed("i1", "Go for a run", "p1")},
+			want:      [][]string{{"text:1 fired"}},
+			wantCalls: []models.ActionCall{score("run")},
+		},
+		{
+			name: "project rule applies when no text rule matches",
+			automations: []models.Automation{
+				models.TodoistHabiticaTextRule{Id: 1, Rule: "go for", HabitId: "run"}.Automation(),
+				models.TodoistHabiticaProjectRule{Id: 2, ProjectId: "p1", HabitId: "project"}.Automation(),
+			},
+			events:    []models.AutomationEvent{completed("i1", "Read a book", "p1")},
+			want:      [][]string{{"project:2 fired"}},
+			wantCalls: []models.ActionCall{score("project")},
+		},
+		{
+			name: "text rules stop at the first match unless they continue",
+			automations: []models.Automation{
+				models.TodoistHabiticaTextRule{Id: 1, Rule: "run", MatchType: models.TextMatchContains, HabitId: "run", Priority: 2, Continue: true}.Automation(),
+				models.TodoistHabiticaTextRule{Id: 2, Rule: "go", HabitId: "outside", Priority: 1}.Automation(),
+				models.TodoistHabiticaTextRule{Id: 3, Rule: "go for", HabitId: "unreached"}.Automation(),
+			},
+			events:    []models.AutomationEvent{completed("i1", "Go for a run", "p1")},
+			want:      [][]string{{"text:1 fired", "text:2 fired"}},
+			wantCalls: []models.ActionCall{score("run"), score("outside")},
+		},
+		{
+			name: "min habit rule scores its daily",
+			automations: []models.Automation{
+				models.HabiticaHabitRule{Id: 1, HabitId: "water", DailyId: "hydrate", MinScore: 2}.Automation(),
+			},
+			events: []models.AutomationEvent{
+				services.HabiticaAutomationEvent(models.HabiticaWebhook{
+					Type: "scored",
+					Task: models.HabiticaWebhookTask{Id: "water", Up: 2},
+				}),
+			},
+			want:      [][]string{{"habit:1 fired"}},
+			wantCalls: []models.ActionCall{score("hydrate")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &services.RecordingUpdater{}
+			engine := services.NewEngine(newFakeEngineStore(tt.automations...), rec.Actuators(), nil)
+			for i, event := range tt.events {
+				runs, err := engine.Run(event)
+				if err != nil {
+					t.Fatalf("event %d: unexpected error: %v", i, err)
+				}
+				if got := outcomes(runs); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("event %d: runs = %v, want %v", i, got, tt.want[i])
+				}
+			}
+			calls := rec.Calls
+			if calls == nil {
+				calls = []models.ActionCall{}
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestEngineRecordsCompletion(t *testing.T) {
+	store := newFakeEngineStore(
+		models.TodoistHabiticaTextRule{Id: 1, Rule: "go for", HabitId: "run"}.Automation(),
+	)
+	rec := &services.RecordingUpdater{}
+	if _, err := services.NewEngine(store, rec.Actuators(), nil).Run(completed("i1", "Go for a run", "p1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.completions["i1"]; !slices.Equal(got, []string{"run"}) {
+		t.Errorf("recorded %v for the item, want [run]", got)
+	}
+}
+
+func TestRulesReadBackFromAutomations(t *testing.T) {
+	habit := models.HabiticaHabitRule{Id: 1, Name: "water", HabitId: "h", DailyId: "d", MinScore: 3, Counter: models.HabitCounterNet}
+	if got := habit.Automation().HabitRule(); got != habit {
+		t.Errorf("habit rule read back as %+v, want %+v", got, habit)
+	}
+
+	text := models.TodoistHabiticaTextRule{
+		Id: 2, Name: "gym", Rule: "^gym", MatchType: models.TextMatchRegex, Labels: []string{"fit", "gym"},
+		SectionId: "s", Operator: models.RuleOperatorOr, Priority: 4, Continue: true, HabitId: "h",
+	}
+	if got := text.Automation().TextRule(); !reflect.DeepEqual(got, text) {
+		t.Errorf("text rule read back as %+v, want %+v", got, text)
+	}
+
+	project := models.TodoistHabiticaProjectRule{Id: 3, Name: "work", ProjectId: "p", HabitId: "h"}
+	if got := project.Automation().ProjectRule(); got != project {
+		t.Errorf("project rule read back as %+v, want %+v", got, project)
+	}
+}
+
+func TestEngineMinHabitRules(t *testing.T) {
+	scored := func(up, down int) models.AutomationEvent {
+		return services.HabiticaAutomationEvent(models.HabiticaWebhook{
+			Type: "scored",
+			Task: models.HabiticaWebhookTask{Id: "water", Up: up, Down: down},
+		})
+	}
+	rule := func(minScore int, counter string) models.HabiticaHabitRule {
+		return models.HabiticaHabitRule{Id: 1, HabitId: "water", DailyId: "hydrate", MinScore: minScore, Counter: counter}
+	}
+
+	tests := []struct {
+		name      string
+		rule      models.HabiticaHabitRule
+		goals     []models.HabitGoal
+		events    []models.AutomationEvent
+		want      [][]string
+		wantCalls []models.ActionCall
+	}{
+		{
+			name:      "below the threshold",
+			rule:      rule(3, models.HabitCounterUp),
+			events:    []models.AutomationEvent{scored(2, 0)},
+			want:      [][]string{{}},
+			wantCalls: []models.ActionCall{},
+		},
+		{
+			name:      "reaching the threshold scores",
+			rule:      rule(3, models.HabitCounterUp),
+			events:    []models.AutomationEvent{scored(3, 0)},
+			want:      [][]string{{"habit:1 fired"}},
+			wantCalls: []models.ActionCall{score("hydrate")},
+		},
+		{
+			name:   "scores once a day",
+			rule:   rule(3, models.HabitCounterUp),
+			events: []models.AutomationEvent{scored(3, 0), scored(4, 0), scored(5, 0)},
+			want: [][]string{
+				{"habit:1 fired"},
+				{"habit:1 " + models.AutomationAlreadyFired},
+				{"habit:1 " + models.AutomationAlreadyFired},
+			},
+			wantCalls: []models.ActionCall{score("hydrate")},
+		},
+		{
+			name:   "dropping below unscores the daily",
+			rule:   rule(3, models.HabitCounterNet),
+			events: []models.AutomationEvent{scored(3, 0), scored(3, 1), scored(3, 2)},
+			want: [][]string{
+				{"habit:1 fired"},
+				{"habit:1 reverted"},
+				{},
+			},
+			wantCalls: []models.ActionCall{score("hydrate"), unscore("hydrate")},
+		},
+		{
+			name:      "net counter subtracts down scores",
+			rule:      rule(3, models.HabitCounterNet),
+			events:    []models.AutomationEvent{scored(4, 2)},
+			want:      [][]string{{}},
+			wantCalls: []models.ActionCall{},
+		},
+		{
+			name:      "no threshold follows the habit's goal",
+			rule:      rule(0, models.HabitCounterUp),
+			goals:     []models.HabitGoal{{HabitId: "water", Target: 2}},
+			events:    []models.AutomationEvent{scored(1, 0), scored(2, 0)},
+			want:      [][]string{{}, {"habit:1 fired"}},
+			wantCalls: []models.ActionCall{score("hydrate")},
+		},
+		{
+			name:      "no threshold and no goal is skipped",
+			rule:      rule(0, models.HabitCounterUp),
+			events:    []models.AutomationEvent{scored(5, 0)},
+			want:      [][]string{{}},
+			wantCalls: []models.ActionCall{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeEngineStore(tt.rule.Automation())
+			store.goals = tt.goals
+			rec := &services.RecordingUpdater{}
+			engine := services.NewEngine(store, rec.Actuators(), nil)
+			for i, event := range tt.events {
+				runs, err := engine.Run(event)
+				if err != nil {
+					t.Fatalf("event %d: unexpected error: %v", i, err)
+				}
+				if got := outcomes(runs); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("event %d: runs = %v, want %v", i, got, tt.want[i])
+				}
+			}
+			calls := rec.Calls
+			if calls == nil {
+				calls = []models.ActionCall{}
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestEngineMinHabitRuleFiresPerLocalDay(t *testing.T) {
+	rule := models.HabiticaHabitRule{Id: 1, HabitId: "water", DailyId: "hydrate", MinScore: 1}
+	store := newFakeEngineStore(rule.Automation())
+	rec := &services.RecordingUpdater{}
+	event := services.HabiticaAutomationEvent(models.HabiticaWebhook{
+		Type: "scored",
+		Task: models.HabiticaWebhookTask{Id: "water", Up: 1},
+	})
+	if _, err := services.NewEngine(store, rec.Actuators(), nil).Run(event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	if !store.fired["habit:1 "+today] {
+		t.Errorf("firings = %v, want one for habit:1 on %s", store.fired, today)
+	}
+
+	// a firing left from yesterday doesn't stop today's
+	store.fired = map[string]bool{"habit:1 " + time.Now().AddDate(0, 0, -1).Format(time.DateOnly): true}
+	runs, err := services.NewEngine(store, rec.Actuators(), nil).Run(event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := outcomes(runs); !slices.Equal(got, []string{"habit:1 fired"}) {
+		t.Errorf("runs = %v, want habit:1 fired", got)
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"misc/clients/habitica"
+	"misc/internal/models"
+	"misc/internal/services"
+	"testing"
+)
+
+type fakeGoalStore struct {
+	goals []models.HabitGoal
+}
+
+func (f *fakeGoalStore) ListHabitGoals() ([]models.HabitGoal, error) {
+	return f.goals, nil
+}
+
+func (f *fakeGoalStore) SaveHabitGoal(goal models.HabitGoal) error {
+	f.goals = append(f.goals, goal)
+	return nil
+}
+
+// notedHabits lists habits with goals in their notes.
+type notedHabits struct {
+	calls int
+}
+
+func (n *notedHabits) GetHabits() ([]habitica.Habit, error) {
+	n.calls++
+	return []habitica.Habit{
+		{Task: habitica.Task{ID: "water", Text: "Water", Notes: "Goal: 8 glasses"}},
+		{Task: habitica.Task{ID: "reading", Text: "Reading", Notes: "no goal here"}},
+	}, nil
+}
+
+func TestImportHabitGoalsIfEmpty(t *testing.T) {
+	store := &fakeGoalStore{}
+	habits := &notedHabits{}
+	imported, err := services.ImportHabitGoalsIfEmpty(store, habits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := models.HabitGoal{HabitId: "water", Target: 8, Period: models.GoalPeriodDaily, Unit: "glasses"}
+	if len(imported) != 1 || imported[0] != want {
+		t.Fatalf("imported %+v, want %+v", imported, want)
+	}
+
+	// once there are goals, startup leaves them alone
+	imported, err = services.ImportHabitGoalsIfEmpty(store, habits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if imported != nil || habits.calls != 1 {
+		t.Errorf("imported %+v with %d habitica calls, want nothing on the second start", imported, habits.calls)
+	}
+}
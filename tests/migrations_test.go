@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"misc/internal/database"
+	"misc/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+// migrateTo rolls db back until version is the last applied migration.
+func migrateTo(t *testing.T, db database.Service, version int) int {
+	t.Helper()
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("error reading migration status: %v", err)
+	}
+	var steps int
+	for _, m := range statuses {
+		if m.Applied && m.Version > version {
+			steps++
+		}
+	}
+	if _, err := db.MigrateDown(steps); err != nil {
+		t.Fatalf("error migrating down to %d: %v", version, err)
+	}
+	return steps
+}
+
+func TestMigratedTextRuleStaysUnique(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "misc.db")
+	db := database.OpenURL(path)
+	defer db.Close()
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("error migrating up: %v", err)
+	}
+	steps := migrateTo(t, db, 8)
+
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer raw.Close()
+	_, err = raw.Exec(`INSERT INTO TodoistHabitTextRule (name, rule, habitId, matchType, labels)
+		VALUES ('laundry', 'laundry & fold', 'h1', 'contains', '["<home>"]')`)
+	if err != nil {
+		t.Fatalf("error inserting text rule: %v", err)
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("error migrating up: %v", err)
+	}
+
+	rule := models.TodoistHabiticaTextRule{
+		Rule:      "laundry & fold",
+		MatchType: models.TextMatchContains,
+		Labels:    []string{"<home>"},
+		HabitId:   "h1",
+	}
+	if _, err := db.CreateTodoistHabiticaTextRule(rule); !errors.Is(err, database.ErrDuplicate) {
+		t.Fatalf("creating the migrated rule again: err = %v, want ErrDuplicate", err)
+	}
+
+	if _, err := db.MigrateDown(steps); err != nil {
+		t.Fatalf("error migrating down: %v", err)
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatalf("error migrating up: %v", err)
+	}
+	rules, err := db.GetTodoistHabiticaTextRules()
+	if err != nil {
+		t.Fatalf("error listing text rules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Rule != rule.Rule || len(rules[0].Labels) != 1 || rules[0].Labels[0] != "<home>" {
+		t.Errorf("rules after round trip = %+v, want only %q with label <home>", rules, rule.Rule)
+	}
+}
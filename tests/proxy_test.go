@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"misc/internal/server"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddr(t *testing.T) {
+	proxies, err := server.ParseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatalf("error parsing trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    server.TrustedProxies
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"no proxies ignores header", nil, "203.0.113.9:1234", "1.2.3.4", "203.0.113.9:1234"},
+		{"untrusted peer ignores header", proxies, "203.0.113.9:1234", "1.2.3.4", "203.0.113.9:1234"},
+		{"trusted peer without header", proxies, "10.0.0.1:1234", "", "10.0.0.1:1234"},
+		{"trusted peer", proxies, "10.0.0.1:1234", "1.2.3.4", "1.2.3.4"},
+		{"spoofed entries before the proxy's", proxies, "10.0.0.1:1234", "6.6.6.6, 1.2.3.4", "1.2.3.4"},
+		{"chain of trusted proxies", proxies, "10.0.0.1:1234", "1.2.3.4, 192.168.1.7", "1.2.3.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/habiticaEvent", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := tt.proxies.ClientAddr(r); got != tt.want {
+				t.Errorf("ClientAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsGarbage(t *testing.T) {
+	if _, err := server.ParseTrustedProxies("10.0.0.1,proxy.local"); err == nil {
+		t.Error("expected an error for a hostname")
+	}
+}
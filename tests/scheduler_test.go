@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"misc/internal/models"
+	"misc/internal/services"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJobStore keeps job states in memory.
+type fakeJobStore struct {
+	mu     sync.Mutex
+	states map[string]models.JobState
+}
+
+func (f *fakeJobStore) ListJobStates() ([]models.JobState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	states := make([]models.JobState, 0, len(f.states))
+	for _, state := range f.states {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (f *fakeJobStore) GetJobState(name string) (models.JobState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, ok := f.states[name]
+	if !ok {
+		return state, errNotFound
+	}
+	return state, nil
+}
+
+func (f *fakeJobStore) SaveJobState(state models.JobState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[state.Name] = state
+	return nil
+}
+
+// startJob runs a yearly job whose stored next run is nextRun and returns a
+// channel that receives each run.
+func startJob(t *testing.T, store *fakeJobStore, nextRun time.Time, err error) <-chan struct{} {
+	const schedule = "0 0 1 1 *"
+	store.states = map[string]models.JobState{
+		"sync": {Name: "sync", Schedule: schedule, NextRunAt: &nextRun},
+	}
+	runs := make(chan struct{}, 10)
+	scheduler := services.NewScheduler(store)
+	job := services.Job{Name: "sync", Schedule: schedule, Run: func(context.Context) error {
+		runs <- struct{}{}
+		return err
+	}}
+	if err := scheduler.Add(job); err != nil {
+		t.Fatalf("error adding job: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	scheduler.Start(ctx)
+	return runs
+}
+
+func TestSchedulerRunsMissedJobOnce(t *testing.T) {
+	store := &fakeJobStore{}
+	// missed several times over while the server was down
+	runs := startJob(t, store, time.Now().AddDate(-3, 0, 0), nil)
+
+	select {
+	case <-runs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("missed run did not run at startup")
+	}
+	select {
+	case <-runs:
+		t.Fatal("missed run ran more than once")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	state, err := store.GetJobState("sync")
+	if err != nil {
+		t.Fatalf("error loading job state: %v", err)
+	}
+	if state.LastSuccessAt == nil || state.LastError != "" {
+		t.Errorf("state = %+v, want a successful run", state)
+	}
+	if state.NextRunAt == nil || !state.NextRunAt.After(time.Now()) {
+		t.Errorf("next run %v is not in the future", state.NextRunAt)
+	}
+}
+
+func TestSchedulerWaitsForNextRun(t *testing.T) {
+	runs := startJob(t, &fakeJobStore{}, time.Now().Add(time.Hour), nil)
+	select {
+	case <-runs:
+		t.Fatal("job ran before its next run")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSchedulerRecordsFailure(t *testing.T) {
+	store := &fakeJobStore{}
+	runs := startJob(t, store, time.Now().Add(-time.Minute), errors.New("todoist is down"))
+	select {
+	case <-runs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("missed run did not run at startup")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		state, _ := store.GetJobState("sync")
+		if state.LastError == "todoist is down" && state.LastSuccessAt == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %+v, want the failure recorded", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"misc/internal/models"
+	"misc/internal/services"
+	"testing"
+	"time"
+)
+
+// series builds daily snapshots ending today from values, oldest first; a
+// negative value leaves that day out.
+func series(today time.Time, goal float64, values ...float64) []models.Snapshot {
+	snapshots := make([]models.Snapshot, 0, len(values))
+	for i, v := range values {
+		if v < 0 {
+			continue
+		}
+		day := today.AddDate(0, 0, i+1-len(values))
+		snapshots = append(snapshots, models.Snapshot{
+			Date:  day.Format(models.SnapshotDateFormat),
+			Value: v,
+			Goal:  &goal,
+		})
+	}
+	return snapshots
+}
+
+func TestComputeStatsStreaks(t *testing.T) {
+	today := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name             string
+		values           []float64
+		current, longest int
+	}{
+		{"all reached", []float64{8, 9, 8}, 3, 3},
+		{"today not reached yet", []float64{8, 8, 2}, 2, 2},
+		{"today reached", []float64{8, 8, 8}, 3, 3},
+		{"broken yesterday", []float64{8, 8, 8, 1, 3}, 0, 3},
+		{"missing day breaks", []float64{8, 8, -1, 8, 8}, 2, 2},
+		{"missing today does not break", []float64{8, 8, -1}, 2, 2},
+		{"longest earlier", []float64{8, 8, 8, 8, 0, 8}, 1, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := services.ComputeStats(series(today, 8, tt.values...), today, 30, 0)
+			if stats.CurrentStreak != tt.current || stats.LongestStreak != tt.longest {
+				t.Errorf("got streaks %d/%d, want %d/%d", stats.CurrentStreak, stats.LongestStreak, tt.current, tt.longest)
+			}
+		})
+	}
+}
+
+func TestComputeStatsDaysWithoutGoal(t *testing.T) {
+	today := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	snapshots := series(today, 8, 8, 0, 8)
+	// the middle day had no goal, so it neither counts nor breaks the streak
+	snapshots[1].Goal = nil
+
+	stats := services.ComputeStats(snapshots, today, 30, 0)
+	if stats.CurrentStreak != 2 {
+		t.Errorf("got current streak %d, want 2", stats.CurrentStreak)
+	}
+	if stats.GoalDays != 2 || stats.HitDays != 2 {
+		t.Errorf("got %d of %d goal days, want 2 of 2", stats.HitDays, stats.GoalDays)
+	}
+}
+
+func TestComputeStatsAverages(t *testing.T) {
+	today := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	// two weeks: 4 a day, then 6 a day, today not reached
+	values := []float64{4, 4, 4, 4, 4, 4, 4, 6, 6, 6, 6, 6, 6, 6}
+	stats := services.ComputeStats(series(today, 5, values...), today, 14, 7)
+
+	if stats.Days != 14 || stats.Average != 5 {
+		t.Errorf("got %d days averaging %v, want 14 averaging 5", stats.Days, stats.Average)
+	}
+	if stats.ThisWeek != 6 || stats.LastWeek != 4 {
+		t.Errorf("got weeks %v and %v, want 6 and 4", stats.ThisWeek, stats.LastWeek)
+	}
+	if stats.WeekOverWeek == nil || *stats.WeekOverWeek != 0.5 {
+		t.Errorf("got week over week %v, want 0.5", stats.WeekOverWeek)
+	}
+	if stats.HitRate == nil || stats.HitDays != 7 || stats.GoalDays != 14 {
+		t.Errorf("got %d of %d goal days, want 7 of 14", stats.HitDays, stats.GoalDays)
+	}
+	if len(stats.RollingAverage) != 14 {
+		t.Fatalf("got %d rolling average points, want 14", len(stats.RollingAverage))
+	}
+	if last := stats.RollingAverage[13]; last.Value != 6 || last.Date != "2026-03-15" {
+		t.Errorf("got last rolling average %+v, want 6 on 2026-03-15", last)
+	}
+	if mid := stats.RollingAverage[9].Value; mid != (4*4+6*3)/7.0 {
+		t.Errorf("got rolling average %v on day 10", mid)
+	}
+}
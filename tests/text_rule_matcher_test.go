@@ -0,0 +1,275 @@
+package tests
+
+import (
+	"misc/internal/models"
+	"misc/internal/services"
+	"slices"
+	"testing"
+)
+
+// TestEngineTextRules runs text rules through the engine and checks which
+// habits a completed item scores.
+func TestEngineTextRules(t *testing.T) {
+	rule := func(id int64, habitId string, edit func(*models.TodoistHabiticaTextRule)) models.TodoistHabiticaTextRule {
+		r := models.TodoistHabiticaTextRule{Id: id, HabitId: habitId}
+		edit(&r)
+		r.SetDefaults()
+		return r
+	}
+
+	tests := []struct {
+		name  string
+		rules []models.TodoistHabiticaTextRule
+		item  models.TodoistItem
+		want  []string
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			item:  models.TodoistItem{Content: "Go for a run"},
+			want:  []string{},
+		},
+		{
+			name: "prefix ignores case on both sides",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) { r.Rule = "Go For" }),
+			},
+			item: models.TodoistItem{Content: "go for a run"},
+			want: []string{"run"},
+		},
+		{
+			name: "prefix does not match later in the text",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) { r.Rule = "run" }),
+			},
+			item: models.TodoistItem{Content: "Go for a run"},
+			want: []string{},
+		},
+		{
+			name: "contains",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "RUN"
+					r.MatchType = models.TextMatchContains
+				}),
+			},
+			item: models.TodoistItem{Content: "Go for a run"},
+			want: []string{"run"},
+		},
+		{
+			name: "regex",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "read", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = `^Read \d+ pages$`
+					r.MatchType = models.TextMatchRegex
+				}),
+			},
+			item: models.TodoistItem{Content: "Read 20 pages"},
+			want: []string{"read"},
+		},
+		{
+			name: "regex is case sensitive unless flagged",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "read", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = `^read`
+					r.MatchType = models.TextMatchRegex
+				}),
+				rule(2, "read-i", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = `(?i)^read`
+					r.MatchType = models.TextMatchRegex
+				}),
+			},
+			item: models.TodoistItem{Content: "Read 20 pages"},
+			want: []string{"read-i"},
+		},
+		{
+			name: "invalid regex never matches",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "bad", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = `(`
+					r.MatchType = models.TextMatchRegex
+				}),
+			},
+			item: models.TodoistItem{Content: "("},
+			want: []string{},
+		},
+		{
+			name: "label only",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "health", func(r *models.TodoistHabiticaTextRule) { r.Labels = []string{"Health"} }),
+			},
+			item: models.TodoistItem{Content: "Stretch", Labels: []string{"health"}},
+			want: []string{"health"},
+		},
+		{
+			name: "section only",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "chores", func(r *models.TodoistHabiticaTextRule) { r.SectionId = "s1" }),
+			},
+			item: models.TodoistItem{Content: "Dishes", SectionId: "s1"},
+			want: []string{"chores"},
+		},
+		{
+			name: "and requires every condition",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Labels = []string{"health"}
+					r.SectionId = "s1"
+				}),
+			},
+			item: models.TodoistItem{Content: "Run 5k", Labels: []string{"health"}, SectionId: "s2"},
+			want: []string{},
+		},
+		{
+			name: "and with every condition met",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Labels = []string{"health", "outside"}
+				}),
+			},
+			item: models.TodoistItem{Content: "Run 5k", Labels: []string{"outside", "health"}},
+			want: []string{"run"},
+		},
+		{
+			name: "or needs any condition",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Labels = []string{"health"}
+					r.Operator = models.RuleOperatorOr
+				}),
+			},
+			item: models.TodoistItem{Content: "Swim", Labels: []string{"health"}},
+			want: []string{"run"},
+		},
+		{
+			name: "or with no condition met",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.SectionId = "s1"
+					r.Operator = models.RuleOperatorOr
+				}),
+			},
+			item: models.TodoistItem{Content: "Swim", SectionId: "s2"},
+			want: []string{},
+		},
+		{
+			name: "rule with no conditions never matches",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "empty", func(r *models.TodoistHabiticaTextRule) {}),
+			},
+			item: models.TodoistItem{Content: "Anything"},
+			want: []string{},
+		},
+		{
+			name: "first match by id when priorities are equal",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(2, "second", func(r *models.TodoistHabiticaTextRule) { r.Rule = "run" }),
+				rule(1, "first", func(r *models.TodoistHabiticaTextRule) { r.Rule = "run" }),
+			},
+			item: models.TodoistItem{Content: "Run 5k"},
+			want: []string{"first"},
+		},
+		{
+			name: "higher priority wins",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "low", func(r *models.TodoistHabiticaTextRule) { r.Rule = "run" }),
+				rule(2, "high", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Priority = 10
+				}),
+			},
+			item: models.TodoistItem{Content: "Run 5k"},
+			want: []string{"high"},
+		},
+		{
+			name: "continue scores several targets in priority order",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "low", func(r *models.TodoistHabiticaTextRule) { r.Rule = "run" }),
+				rule(2, "lowest", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Priority = -1
+				}),
+				rule(3, "high", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Priority = 10
+					r.Continue = true
+				}),
+			},
+			item: models.TodoistItem{Content: "Run 5k"},
+			want: []string{"high", "low"},
+		},
+		{
+			name: "continue skips rules that don't match",
+			rules: []models.TodoistHabiticaTextRule{
+				rule(1, "run", func(r *models.TodoistHabiticaTextRule) {
+					r.Rule = "run"
+					r.Continue = true
+				}),
+				rule(2, "swim", func(r *models.TodoistHabiticaTextRule) { r.Rule = "swim" }),
+				rule(3, "health", func(r *models.TodoistHabiticaTextRule) { r.Labels = []string{"health"} }),
+			},
+			item: models.TodoistItem{Content: "Run 5k", Labels: []string{"health"}},
+			want: []string{"run", "health"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			automations := make([]models.Automation, 0, len(tt.rules))
+			for _, r := range tt.rules {
+				automations = append(automations, r.Automation())
+			}
+			rec := &services.RecordingUpdater{}
+			engine := services.NewEngine(newFakeEngineStore(automations...), rec.Actuators(), nil)
+			event := services.TodoistAutomationEvent(todoistItemEvent(t, models.TodoistItemCompleted, tt.item))
+			if _, err := engine.Run(event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make([]string, 0, len(rec.Calls))
+			for _, call := range rec.Calls {
+				got = append(got, call.TaskId)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTextRuleValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      models.TodoistHabiticaTextRule
+		wantField string
+	}{
+		{"prefix", models.TodoistHabiticaTextRule{Rule: "run", HabitId: "h"}, ""},
+		{"label only", models.TodoistHabiticaTextRule{Labels: []string{"health"}, HabitId: "h"}, ""},
+		{"no conditions", models.TodoistHabiticaTextRule{HabitId: "h"}, "rule"},
+		{"bad regex", models.TodoistHabiticaTextRule{Rule: "(", MatchType: models.TextMatchRegex, HabitId: "h"}, "rule"},
+		{"unknown match type", models.TodoistHabiticaTextRule{Rule: "run", MatchType: "suffix", HabitId: "h"}, "match_type"},
+		{"unknown operator", models.TodoistHabiticaTextRule{Rule: "run", Operator: "xor", HabitId: "h"}, "operator"},
+		{"empty label", models.TodoistHabiticaTextRule{Labels: []string{""}, HabitId: "h"}, "labels"},
+		{"no habit", models.TodoistHabiticaTextRule{Rule: "run"}, "habit_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.SetDefaults()
+			err := tt.rule.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("expected no error; got %v", err)
+				}
+				return
+			}
+			validationErr, ok := err.(models.ValidationError)
+			if !ok || validationErr.Field != tt.wantField {
+				t.Errorf("expected validation error on %s; got %v", tt.wantField, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package tests
+
+import (
+	"encoding/json"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"misc/internal/services"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeLinkStore keeps a single todo link, copied in and out as the database
+// would.
+type fakeLinkStore struct {
+	link *models.TodoLink
+}
+
+func (f *fakeLinkStore) ListTodoSyncProjects() ([]models.TodoSyncProject, error) {
+	return []models.TodoSyncProject{}, nil
+}
+
+func (f *fakeLinkStore) IsTodoSyncProject(projectId string) (bool, error) {
+	return projectId == "p1", nil
+}
+
+func (f *fakeLinkStore) find(match func(models.TodoLink) bool) (*models.TodoLink, error) {
+	if f.link == nil || !match(*f.link) {
+		return nil, nil
+	}
+	link := *f.link
+	link.Pending = slices.Clone(link.Pending)
+	return &link, nil
+}
+
+func (f *fakeLinkStore) FindTodoLinkByTodoist(taskId string) (*models.TodoLink, error) {
+	return f.find(func(l models.TodoLink) bool { return l.TodoistTaskId == taskId })
+}
+
+func (f *fakeLinkStore) FindTodoLinkByHabitica(taskId string) (*models.TodoLink, error) {
+	return f.find(func(l models.TodoLink) bool { return l.HabiticaTaskId == taskId })
+}
+
+func (f *fakeLinkStore) CreateTodoLink(link models.TodoLink) (models.TodoLink, error) {
+	link.Id = 1
+	f.link = &link
+	return link, nil
+}
+
+func (f *fakeLinkStore) UpdateTodoLink(link models.TodoLink) error {
+	link.Pending = slices.Clone(link.Pending)
+	f.link = &link
+	return nil
+}
+
+func (f *fakeLinkStore) DeleteTodoLink(int64) error {
+	f.link = nil
+	return nil
+}
+
+// fakeTodoSides records the writes the sync makes to Habitica and Todoist.
+type fakeTodoSides struct {
+	calls []string
+}
+
+func (f *fakeTodoSides) ScoreDaily(id string) error {
+	f.calls = append(f.calls, "habitica score "+id)
+	return nil
+}
+
+func (f *fakeTodoSides) UnscoreTask(id string) error {
+	f.calls = append(f.calls, "habitica unscore "+id)
+	return nil
+}
+
+func (f *fakeTodoSides) CreateTodo(text string) (habitica.Task, error) {
+	f.calls = append(f.calls, "habitica create "+text)
+	return habitica.Task{ID: "h1", Text: text}, nil
+}
+
+func (f *fakeTodoSides) UpdateTaskText(id, text string) error {
+	f.calls = append(f.calls, "habitica text "+text)
+	return nil
+}
+
+func (f *fakeTodoSides) DeleteTask(id string) error {
+	f.calls = append(f.calls, "habitica delete "+id)
+	return nil
+}
+
+func (f *fakeTodoSides) CloseTask(id string) error {
+	f.calls = append(f.calls, "todoist close "+id)
+	return nil
+}
+
+func (f *fakeTodoSides) ReopenTask(id string) error {
+	f.calls = append(f.calls, "todoist reopen "+id)
+	return nil
+}
+
+func (f *fakeTodoSides) UpdateTaskContent(id, content string) error {
+	f.calls = append(f.calls, "todoist content "+content)
+	return nil
+}
+
+func (f *fakeTodoSides) GetProjectTasks(string) ([]todoist.Task, error) {
+	return []todoist.Task{}, nil
+}
+
+func todoistItemEvent(t *testing.T, name string, item models.TodoistItem) models.TodoistWebhook {
+	item.Id, item.ProjectId = "t1", "p1"
+	data, err := json.Marshal(item)
+	if err != nil {
+		t.Fatalf("error encoding item: %v", err)
+	}
+	return models.TodoistWebhook{EventName: name, EventData: data}
+}
+
+func habiticaTodoEvent(eventType, direction, text string, at time.Time) models.HabiticaWebhook {
+	return models.HabiticaWebhook{
+		Type:      eventType,
+		Direction: direction,
+		Task:      models.HabiticaWebhookTask{Id: "h1", Type: "todo", Text: text, UpdatedAt: at.Format(time.RFC3339)},
+	}
+}
+
+func TestTodoSync(t *testing.T) {
+	// Todoist changes are an hour old by the time they arrive; the sync's own
+	// writes to Habitica are stamped when they happen, i.e. now.
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	now := time.Now().Truncate(time.Second)
+	rfc := func(at time.Time) string { return at.Format(time.RFC3339) }
+
+	type step func(t *testing.T, sync *services.TodoSync) error
+	todoistCompleted := func(completed bool, at time.Time) step {
+		return func(t *testing.T, sync *services.TodoSync) error {
+			if completed {
+				return sync.HandleItemCompleted(todoistItemEvent(t, models.TodoistItemCompleted,
+					models.TodoistItem{Content: "Buy milk", Checked: true, CompletedAt: rfc(at)}))
+			}
+			return sync.HandleItemUncompleted(todoistItemEvent(t, models.TodoistItemUncompleted,
+				models.TodoistItem{Content: "Buy milk", UpdatedAt: rfc(at)}))
+		}
+	}
+	todoistContent := func(content string, at time.Time) step {
+		return func(t *testing.T, sync *services.TodoSync) error {
+			return sync.HandleItemUpdated(todoistItemEvent(t, models.TodoistItemUpdated,
+				models.TodoistItem{Content: content, UpdatedAt: rfc(at)}))
+		}
+	}
+	habiticaScored := func(direction string, at time.Time) step {
+		return func(t *testing.T, sync *services.TodoSync) error {
+			return sync.HandleTaskScored(habiticaTodoEvent(services.HabiticaTaskScored, direction, "Buy milk", at))
+		}
+	}
+	habiticaText := func(text string, at time.Time) step {
+		return func(t *testing.T, sync *services.TodoSync) error {
+			return sync.HandleTaskUpdated(habiticaTodoEvent(services.HabiticaTaskUpdated, "", text, at))
+		}
+	}
+
+	tests := []struct {
+		name          string
+		pending       []models.TodoEcho
+		updatedAt     time.Time
+		steps         []step
+		wantCalls     []string
+		wantCompleted bool
+		wantContent   string
+	}{
+		{
+			name:          "completion syncs to habitica",
+			steps:         []step{todoistCompleted(true, base.Add(time.Minute))},
+			wantCalls:     []string{"habitica score h1"},
+			wantCompleted: true,
+			wantContent:   "Buy milk",
+		},
+		{
+			name: "echoes of back-to-back completions are skipped",
+			steps: []step{
+				todoistCompleted(true, base.Add(time.Minute)),
+				todoistCompleted(false, base.Add(2*time.Minute)),
+				habiticaScored("up", now),
+				habiticaScored("down", now),
+			},
+			wantCalls:   []string{"habitica score h1", "habitica unscore h1"},
+			wantContent: "Buy milk",
+		},
+		{
+			name: "echoes of back-to-back renames are skipped",
+			steps: []step{
+				habiticaText("Buy oat milk", base.Add(time.Minute)),
+				habiticaText("Buy soy milk", base.Add(2*time.Minute)),
+				todoistContent("Buy oat milk", now),
+				todoistContent("Buy soy milk", now),
+			},
+			wantCalls:   []string{"todoist content Buy oat milk", "todoist content Buy soy milk"},
+			wantContent: "Buy soy milk",
+		},
+		{
+			name: "a real change after the echo still syncs",
+			steps: []step{
+				todoistCompleted(true, base.Add(time.Minute)),
+				habiticaScored("up", now),
+				habiticaScored("down", now.Add(time.Second)),
+			},
+			wantCalls:   []string{"habitica score h1", "todoist reopen t1"},
+			wantContent: "Buy milk",
+		},
+		{
+			name: "an expired pending write doesn't swallow a change",
+			pending: []models.TodoEcho{{
+				Source: models.TodoistEventSource,
+				Change: models.TodoChangeCompleted,
+				At:     time.Now().Add(-models.TodoEchoTTL - time.Minute),
+			}},
+			steps:         []step{todoistCompleted(true, base.Add(time.Minute))},
+			wantCalls:     []string{"habitica score h1"},
+			wantCompleted: true,
+			wantContent:   "Buy milk",
+		},
+		{
+			name:        "an older change loses to the last write",
+			updatedAt:   base.Add(5 * time.Minute),
+			steps:       []step{todoistCompleted(true, base.Add(time.Minute)), habiticaText("Buy bread", base.Add(2*time.Minute))},
+			wantCalls:   []string{},
+			wantContent: "Buy milk",
+		},
+		{
+			name:      "a newer change wins over the last write",
+			updatedAt: base.Add(5 * time.Minute),
+			steps: []step{
+				todoistCompleted(true, base.Add(6*time.Minute)),
+				habiticaText("Buy bread", base.Add(7*time.Minute)),
+			},
+			wantCalls:     []string{"habitica score h1", "todoist content Buy bread"},
+			wantCompleted: true,
+			wantContent:   "Buy bread",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updatedAt := tt.updatedAt
+			if updatedAt.IsZero() {
+				updatedAt = base
+			}
+			store := &fakeLinkStore{link: &models.TodoLink{
+				Id:               1,
+				TodoistTaskId:    "t1",
+				HabiticaTaskId:   "h1",
+				TodoistProjectId: "p1",
+				Content:          "Buy milk",
+				UpdatedAt:        updatedAt,
+				UpdatedBy:        models.TodoistEventSource,
+				Pending:          tt.pending,
+			}}
+			sides := &fakeTodoSides{calls: []string{}}
+			sync := services.NewTodoSync(store, sides, sides)
+
+			for i, step := range tt.steps {
+				if err := step(t, sync); err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+			}
+			if !slices.Equal(sides.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", sides.calls, tt.wantCalls)
+			}
+			if store.link.Completed != tt.wantCompleted {
+				t.Errorf("completed = %v, want %v", store.link.Completed, tt.wantCompleted)
+			}
+			if store.link.Content != tt.wantContent {
+				t.Errorf("content = %q, want %q", store.link.Content, tt.wantContent)
+			}
+		})
+	}
+}
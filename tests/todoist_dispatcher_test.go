@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"errors"
+	"misc/internal/models"
+	"misc/internal/services"
+	"slices"
+	"testing"
+)
+
+func TestTodoistDispatcher(t *testing.T) {
+	errScore := errors.New("score failed")
+	errTodo := errors.New("todo failed")
+
+	tests := []struct {
+		name        string
+		event       string
+		wantCalls   []string
+		wantHandled bool
+		wantErrs    []error
+	}{
+		{
+			name:        "completed runs its handlers in order",
+			event:       models.TodoistItemCompleted,
+			wantCalls:   []string{"score", "log"},
+			wantHandled: true,
+		},
+		{
+			name:        "uncompleted goes to its own handler",
+			event:       models.TodoistItemUncompleted,
+			wantCalls:   []string{"unscore"},
+			wantHandled: true,
+		},
+		{
+			name:        "errors from every handler are joined",
+			event:       models.TodoistItemAdded,
+			wantCalls:   []string{"create todo", "notify"},
+			wantHandled: true,
+			wantErrs:    []error{errScore, errTodo},
+		},
+		{
+			name:        "unhandled event",
+			event:       models.TodoistItemDeleted,
+			wantCalls:   []string{},
+			wantHandled: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+			handler := func(name string, err error) services.TodoistEventHandler {
+				return func(models.TodoistWebhook) error {
+					calls = append(calls, name)
+					return err
+				}
+			}
+			d := services.NewTodoistDispatcher()
+			d.On(models.TodoistItemCompleted, handler("score", nil))
+			d.On(models.TodoistItemCompleted, handler("log", nil))
+			d.On(models.TodoistItemUncompleted, handler("unscore", nil))
+			d.On(models.TodoistItemAdded, handler("create todo", errScore))
+			d.On(models.TodoistItemAdded, handler("notify", errTodo))
+
+			handled, err := d.Dispatch(models.TodoistWebhook{EventName: tt.event})
+			if handled != tt.wantHandled {
+				t.Errorf("handled = %v, want %v", handled, tt.wantHandled)
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("error %v does not wrap %v", err, want)
+				}
+			}
+		})
+	}
+}
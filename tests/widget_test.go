@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"misc/clients/habitica"
+	"misc/clients/todoist"
+	"misc/internal/models"
+	"misc/internal/services"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWidgetStore serves the default widget's metrics and habit goals.
+type fakeWidgetStore struct {
+	metrics []models.WidgetMetric
+	goals   []models.HabitGoal
+}
+
+func (f *fakeWidgetStore) ListWidgetMetrics(string) ([]models.WidgetMetric, error) {
+	return f.metrics, nil
+}
+
+func (f *fakeWidgetStore) ListHabitGoals() ([]models.HabitGoal, error) {
+	return f.goals, nil
+}
+
+// fakeRepo answers for Habitica and Todoist after delay, or with err.
+type fakeRepo struct {
+	delay time.Duration
+	err   error
+	calls int
+	mu    sync.Mutex
+}
+
+func (f *fakeRepo) wait() error {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+	return f.err
+}
+
+type fakeHabitica struct {
+	habits, dailies fakeRepo
+}
+
+func (f *fakeHabitica) GetHabits() ([]habitica.Habit, error) {
+	if err := f.habits.wait(); err != nil {
+		return nil, err
+	}
+	return []habitica.Habit{
+		{CounterUp: 5, CounterDown: 1, Task: habitica.Task{ID: "water", Text: "Water"}},
+		{CounterUp: 2, Task: habitica.Task{ID: "reading", Text: "Reading"}},
+	}, nil
+}
+
+func (f *fakeHabitica) GetDailys() ([]habitica.Daily, error) {
+	if err := f.dailies.wait(); err != nil {
+		return nil, err
+	}
+	return []habitica.Daily{
+		{IsDue: true, Completed: true},
+		{IsDue: true},
+		{Completed: true},
+	}, nil
+}
+
+type fakeTodoist struct {
+	stats, tasks fakeRepo
+}
+
+func (f *fakeTodoist) GetStats() (todoist.Stats, error) {
+	if err := f.stats.wait(); err != nil {
+		return todoist.Stats{}, err
+	}
+	return todoist.Stats{
+		DaysItems: []todoist.DayItem{{TotalCompleted: 3}},
+		Goals:     todoist.Goals{DailyGoal: 5},
+	}, nil
+}
+
+func (f *fakeTodoist) GetTasks(*todoist.TaskFilterOptions) ([]todoist.Task, error) {
+	if err := f.tasks.wait(); err != nil {
+		return nil, err
+	}
+	return []todoist.Task{{ID: "1"}, {ID: "2"}}, nil
+}
+
+func widgetStore() *fakeWidgetStore {
+	return &fakeWidgetStore{
+		metrics: []models.WidgetMetric{
+			{Name: "water", Source: models.MetricSourceHabit, Selector: "Water", Value: "net", GoalSource: models.GoalSourceHabitGoal},
+			{Name: "reading", Source: models.MetricSourceHabit, Selector: "reading", Value: "up", GoalSource: models.GoalSourceFixed, Goal: 1},
+			{Name: "dailies", Source: models.MetricSourceDailies, Value: "done", GoalSource: models.GoalSourceDailiesDue},
+			{Name: "todoist_done", Source: models.MetricSourceTodoistStats, Value: "completed_today", GoalSource: models.GoalSourceTodoistDailyGoal},
+			{Name: "todoist_due", Source: models.MetricSourceTodoistTasks, Value: "count", GoalSource: models.GoalSourceNone},
+		},
+		goals: []models.HabitGoal{{HabitId: "water", Target: 8, Period: models.GoalPeriodDaily}},
+	}
+}
+
+// metricValues flattens the metrics to name: value/goal, -1 for no goal.
+func metricValues(resp models.WidgetResponse) map[string][2]int {
+	values := make(map[string][2]int, len(resp.Metrics))
+	for _, m := range resp.Metrics {
+		goal := -1
+		if m.Goal != nil {
+			goal = *m.Goal
+		}
+		values[m.Name] = [2]int{m.Value, goal}
+	}
+	return values
+}
+
+func sourceStatuses(resp models.WidgetResponse) map[string]string {
+	statuses := make(map[string]string, len(resp.Sources))
+	for _, s := range resp.Sources {
+		statuses[s.Source] = s.Status
+	}
+	return statuses
+}
+
+func TestWidgetAllSources(t *testing.T) {
+	hab, td := &fakeHabitica{}, &fakeTodoist{}
+	resp, err := services.NewWidgetService(widgetStore(), hab, td).GetWidgetResponse(context.Background(), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][2]int{
+		"water":        {4, 8},
+		"reading":      {2, 1},
+		"dailies":      {1, 2},
+		"todoist_done": {3, 5},
+		"todoist_due":  {2, -1},
+	}
+	got := metricValues(resp)
+	if len(got) != len(want) {
+		t.Errorf("got metrics %v, want %v", got, want)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: got %v, want %v", name, got[name], w)
+		}
+	}
+	for source, status := range sourceStatuses(resp) {
+		if status != models.WidgetSourceOK {
+			t.Errorf("source %s: got %s, want ok", source, status)
+		}
+	}
+	if len(resp.Sources) != 5 {
+		t.Errorf("got %d sources, want 5", len(resp.Sources))
+	}
+	if hab.habits.calls != 1 {
+		t.Errorf("habits fetched %d times, want once for two metrics", hab.habits.calls)
+	}
+}
+
+func TestWidgetPartialFailure(t *testing.T) {
+	hab := &fakeHabitica{}
+	td := &fakeTodoist{stats: fakeRepo{err: errors.New("todoist is down")}}
+	resp, err := services.NewWidgetService(widgetStore(), hab, td).GetWidgetResponse(context.Background(), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := metricValues(resp)
+	if _, ok := got["todoist_done"]; ok {
+		t.Errorf("todoist_done should be left out when stats fail, got %v", got["todoist_done"])
+	}
+	for _, name := range []string{"water", "reading", "dailies", "todoist_due"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("%s missing from partial response", name)
+		}
+	}
+	statuses := sourceStatuses(resp)
+	if statuses["todoist stats"] != models.WidgetSourceError {
+		t.Errorf("todoist stats: got %q, want error", statuses["todoist stats"])
+	}
+	if statuses["habits"] != models.WidgetSourceOK {
+		t.Errorf("habits: got %q, want ok", statuses["habits"])
+	}
+}
+
+func TestWidgetSlowSourceTimesOut(t *testing.T) {
+	hab := &fakeHabitica{dailies: fakeRepo{delay: time.Second}}
+	td := &fakeTodoist{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := services.NewWidgetService(widgetStore(), hab, td).GetWidgetResponse(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waited %s for a source that should have timed out", elapsed)
+	}
+
+	statuses := sourceStatuses(resp)
+	if statuses["dailies"] != models.WidgetSourceTimeout {
+		t.Errorf("dailies: got %q, want timeout", statuses["dailies"])
+	}
+	got := metricValues(resp)
+	if _, ok := got["dailies"]; ok {
+		t.Error("dailies should be left out when they time out")
+	}
+	if got["water"] != [2]int{4, 8} {
+		t.Errorf("water: got %v, want [4 8]", got["water"])
+	}
+}
+
+func TestWidgetCanceled(t *testing.T) {
+	hab := &fakeHabitica{habits: fakeRepo{delay: time.Second}, dailies: fakeRepo{delay: time.Second}}
+	td := &fakeTodoist{stats: fakeRepo{delay: time.Second}, tasks: fakeRepo{delay: time.Second}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := services.NewWidgetService(widgetStore(), hab, td).GetWidgetResponse(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Metrics) != 0 {
+		t.Errorf("got metrics %v from a canceled request", metricValues(resp))
+	}
+	for _, s := range resp.Sources {
+		if s.Source == "habit goals" {
+			continue
+		}
+		if s.Status != models.WidgetSourceCanceled {
+			t.Errorf("%s: got %q, want canceled", s.Source, s.Status)
+		}
+	}
+}
+
+// TestWidgetConcurrentRequests is meant for -race: many widget requests share
+// one service and repositories.
+func TestWidgetConcurrentRequests(t *testing.T) {
+	hab := &fakeHabitica{habits: fakeRepo{delay: time.Millisecond}}
+	td := &fakeTodoist{stats: fakeRepo{err: errors.New("rate limited")}}
+	widget := services.NewWidgetService(widgetStore(), hab, td)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := widget.GetWidgetResponse(context.Background(), "default")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(resp.Metrics) != 4 {
+				t.Errorf("got %d metrics, want 4", len(resp.Metrics))
+			}
+		}()
+	}
+	wg.Wait()
+}